@@ -0,0 +1,12 @@
+// Package api holds the documents describing the formats read by the library, so they are available to programs
+// regardless of their working directory.
+package api
+
+import (
+	_ "embed"
+)
+
+// ObligationsSchema is the json schema obligations are validated against.
+//
+//go:embed obligations.json
+var ObligationsSchema []byte
@@ -8,6 +8,8 @@ require (
 	github.com/neo4j/neo4j-go-driver/v4 v4.4.4
 	github.com/spf13/viper v1.12.0
 	github.com/xeipuuv/gojsonschema v1.2.0
+	go.etcd.io/bbolt v1.3.6
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -28,5 +30,4 @@ require (
 	golang.org/x/text v0.3.7 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
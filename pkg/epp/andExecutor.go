@@ -0,0 +1,44 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// AndExecutor is true when all its arguments are.
+type AndExecutor struct{}
+
+func (f *AndExecutor) Name() string {
+	return "and"
+}
+
+func (f *AndExecutor) NumParams() int {
+	return 1
+}
+
+// any number of booleans
+func (f *AndExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{BOOLEAN}, Variadic: true, Returns: BOOLEAN}
+}
+
+func (f *AndExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// the arguments are evaluated until one is false
+	for i := 0; i < args.len(); i++ {
+		b, err := args.bool(i)
+		if err != nil {
+			return nil, err
+		}
+		if !b {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,166 @@
+package epp
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/internal/set"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// args evaluates the arguments of a function, a literal value or the result of a nested function.
+type args struct {
+	g                 graph.Graph
+	p                 prohibitions.Prohibitions
+	o                 obligations.Obligations
+	eventCtx          EventContext
+	functionEvaluator *FunctionEvaluator
+	executor          FunctionExecutor
+	function          *obligations.Function
+}
+
+// newArgs checks that the function got the number of arguments the executor expects.
+func newArgs(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, eventCtx EventContext,
+	function *obligations.Function, functionEvaluator *FunctionEvaluator, executor FunctionExecutor) (*args, error) {
+	if min, max := signature(executor).ParamRange(); len(function.Args) < min || (max >= 0 && len(function.Args) > max) {
+		return nil, fmt.Errorf("%s expects %s but got %d", executor.Name(), arity(min, max), len(function.Args))
+	}
+
+	return &args{g, p, o, eventCtx, functionEvaluator, executor, function}, nil
+}
+
+func (a *args) len() int {
+	return len(a.function.Args)
+}
+
+func (a *args) value(i int) (interface{}, error) {
+	arg := a.function.Args[i]
+	if arg.Function == nil {
+		return arg.Value, nil
+	}
+
+	return a.functionEvaluator.Eval(a.g, a.p, a.o, a.eventCtx, arg.Function)
+}
+
+// string returns the argument as a string, a node is given by its name.
+func (a *args) string(i int) (string, error) {
+	v, err := a.value(i)
+	if err != nil {
+		return "", err
+	}
+
+	return toString(v), nil
+}
+
+// as returns the argument coerced to the type.
+func (a *args) as(i int, t ValueType) (interface{}, error) {
+	v, err := a.value(i)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := coerce(a.g, v, t)
+	if err != nil {
+		return nil, fmt.Errorf("%s expected argument %d to be a %s but got %v", a.executor.Name(), i+1, t, v)
+	}
+
+	return c, nil
+}
+
+func (a *args) bool(i int) (bool, error) {
+	v, err := a.as(i, BOOLEAN)
+	if err != nil {
+		return false, err
+	}
+
+	return v.(bool), nil
+}
+
+// node returns the node returned by the argument, or the node it names.
+func (a *args) node(i int) (*graph.Node, error) {
+	v, err := a.as(i, NODE)
+	if err != nil {
+		return nil, err
+	}
+
+	n := v.(*graph.Node)
+	if n == nil {
+		return nil, fmt.Errorf("%s expected argument %d to be a node but got nil", a.executor.Name(), i+1)
+	}
+
+	return n, nil
+}
+
+// strings returns the elements of a set as strings.
+func (a *args) strings(i int) ([]string, error) {
+	v, err := a.as(i, SET)
+	if err != nil {
+		return nil, err
+	}
+
+	s := v.(set.Set)
+	list := make([]string, 0, s.Len())
+	for e := range s.Iter() {
+		list = append(list, toString(e))
+	}
+
+	return list, nil
+}
+
+// properties returns the properties returned by the argument, none when it's omitted.
+func (a *args) properties(i int) (graph.PropertyMap, error) {
+	if i >= a.len() {
+		return graph.NewPropertyMap(), nil
+	}
+
+	v, err := a.as(i, PROPERTIES)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(graph.PropertyMap), nil
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case *graph.Node:
+		if s == nil {
+			return ""
+		}
+		return s.Name
+	case nil:
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
+
+// ancestors returns the names of every node the node is contained in.
+func ancestors(g graph.Graph, name string) set.Set {
+	nodes := set.NewSet()
+	for parent := range g.Parents(name).Iter() {
+		if nodes.Contains(parent) {
+			continue
+		}
+		nodes.Add(parent)
+		nodes.AddFrom(ancestors(g, parent.(string)))
+	}
+
+	return nodes
+}
+
+// descendants returns the names of every node contained in the node.
+func descendants(g graph.Graph, name string) set.Set {
+	nodes := set.NewSet()
+	for child := range g.Children(name).Iter() {
+		if nodes.Contains(child) {
+			continue
+		}
+		nodes.Add(child)
+		nodes.AddFrom(descendants(g, child.(string)))
+	}
+
+	return nodes
+}
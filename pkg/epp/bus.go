@@ -0,0 +1,216 @@
+package epp
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull = errors.New("the event queue is full")
+	ErrBusClosed = errors.New("the event bus is closed")
+)
+
+const default_queue_size = 100
+
+// Handler processes an event delivered by the bus.
+type Handler func(eventCtx EventContext) error
+
+type BusOptions struct {
+	// Number of workers processing events. Zero processes every event synchronously in the publishing call, which
+	// returns the first error.
+	Workers int
+	// Number of deliveries each worker can hold, defaults to 100. Publishing to a full queue fails with ErrQueueFull.
+	QueueSize int
+	// Number of times a failed delivery is retried.
+	MaxRetries int
+	// Delay before the first retry, doubled for each following one.
+	RetryDelay time.Duration
+	// Called when a delivery failed after its retries. The error is logged when nil.
+	OnError func(key string, eventCtx EventContext, err error)
+}
+
+type delivery struct {
+	key      string
+	eventCtx EventContext
+	handler  Handler
+}
+
+type subscriber struct {
+	key     string
+	handler Handler
+}
+
+// Bus delivers events to handlers. Deliveries sharing a key are processed one at a time in the order they were
+// published, every delivery is attempted at least once and retried on failure.
+type Bus struct {
+	opts   BusOptions
+	queues []chan *delivery
+	wg     sync.WaitGroup
+
+	sync.Mutex
+	cond        *sync.Cond
+	pending     int // deliveries accepted and not finished yet
+	closed      bool
+	subscribers []*subscriber
+}
+
+func NewBus(opts *BusOptions) *Bus {
+	b := new(Bus)
+	if opts != nil {
+		b.opts = *opts
+	}
+	if b.opts.QueueSize <= 0 {
+		b.opts.QueueSize = default_queue_size
+	}
+	b.cond = sync.NewCond(&b.Mutex)
+
+	b.queues = make([]chan *delivery, b.opts.Workers)
+	for i := range b.queues {
+		b.queues[i] = make(chan *delivery, b.opts.QueueSize)
+		b.wg.Add(1)
+		go b.work(b.queues[i])
+	}
+
+	return b
+}
+
+// Synchronous returns true if deliveries are processed in the publishing call.
+func (b *Bus) Synchronous() bool {
+	return len(b.queues) == 0
+}
+
+// Subscribe registers a handler receiving every published event, in order. The returned function removes it.
+func (b *Bus) Subscribe(name string, handler Handler) (unsubscribe func()) {
+	s := &subscriber{"subscriber:" + name, handler}
+	b.Lock()
+	b.subscribers = append(b.subscribers, s)
+	b.Unlock()
+
+	return func() {
+		b.Lock()
+		defer b.Unlock()
+		for i, sub := range b.subscribers {
+			if sub == s {
+				b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers the event to every subscriber.
+func (b *Bus) Publish(eventCtx EventContext) error {
+	b.Lock()
+	subscribers := append([]*subscriber{}, b.subscribers...)
+	b.Unlock()
+
+	for _, s := range subscribers {
+		if err := b.Deliver(s.key, eventCtx, s.handler); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliver the event to a single handler. Deliveries with the same key are processed in order.
+func (b *Bus) Deliver(key string, eventCtx EventContext, handler Handler) error {
+	d := &delivery{key, eventCtx, handler}
+	if b.Synchronous() {
+		b.Lock()
+		closed := b.closed
+		b.Unlock()
+		if closed {
+			return ErrBusClosed
+		}
+
+		return b.process(d)
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	select {
+	case b.queues[b.partition(key)] <- d:
+		b.pending++
+		return nil
+	default:
+		return fmt.Errorf("%w, dropped event %s for %s", ErrQueueFull, eventCtx.Event(), key)
+	}
+}
+
+func (b *Bus) partition(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(b.queues)))
+}
+
+func (b *Bus) work(queue chan *delivery) {
+	defer b.wg.Done()
+	for d := range queue {
+		if err := b.process(d); err != nil {
+			b.fail(d, err)
+		}
+
+		b.Lock()
+		b.pending--
+		if b.pending == 0 {
+			b.cond.Broadcast()
+		}
+		b.Unlock()
+	}
+}
+
+// process runs the handler, retrying it on failure.
+func (b *Bus) process(d *delivery) (err error) {
+	delay := b.opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		if err = d.handler(d.eventCtx); err == nil || attempt >= b.opts.MaxRetries {
+			return
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (b *Bus) fail(d *delivery, err error) {
+	if b.opts.OnError != nil {
+		b.opts.OnError(d.key, d.eventCtx, err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("failed to process event %s for %s: %s", d.eventCtx.Event(), d.key, err))
+}
+
+// Wait blocks until every accepted delivery has been processed, including those published while waiting.
+func (b *Bus) Wait() {
+	b.Lock()
+	for b.pending > 0 {
+		b.cond.Wait()
+	}
+	b.Unlock()
+}
+
+// Close stops accepting events and waits for the queued ones to be processed.
+func (b *Bus) Close() {
+	b.Lock()
+	if b.closed {
+		b.Unlock()
+		return
+	}
+	b.closed = true
+	for _, queue := range b.queues {
+		close(queue)
+	}
+	b.Unlock()
+
+	b.wg.Wait()
+}
@@ -0,0 +1,173 @@
+package epp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jtejido/ngac/pkg/context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testEvent(name string) EventContext {
+	userCtx, _ := context.NewUserContext("u1")
+	return NewEventContext(userCtx, name, nil)
+}
+
+func TestSynchronousBus(t *testing.T) {
+	bus := NewBus(nil)
+	defer bus.Close()
+
+	if !bus.Synchronous() {
+		t.Fatalf("a bus without workers should be synchronous")
+	}
+
+	var handled bool
+	if err := bus.Deliver("k", testEvent("e"), func(EventContext) error { handled = true; return nil }); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !handled {
+		t.Errorf("the event should be handled before Deliver returns")
+	}
+
+	expected := errors.New("failed")
+	if err := bus.Deliver("k", testEvent("e"), func(EventContext) error { return expected }); err != expected {
+		t.Errorf("expected the handler error, got %v", err)
+	}
+}
+
+func TestOrderingPerKey(t *testing.T) {
+	bus := NewBus(&BusOptions{Workers: 4, QueueSize: 1000})
+	defer bus.Close()
+
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	for i := 0; i < 100; i++ {
+		for k := 0; k < 5; k++ {
+			key := fmt.Sprintf("key%d", k)
+			err := bus.Deliver(key, testEvent(fmt.Sprintf("%d", i)), func(eventCtx EventContext) error {
+				mu.Lock()
+				received[key] = append(received[key], eventCtx.Event())
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+		}
+	}
+	bus.Wait()
+
+	for k := 0; k < 5; k++ {
+		events := received[fmt.Sprintf("key%d", k)]
+		if len(events) != 100 {
+			t.Fatalf("expected 100 events for key%d, got %d", k, len(events))
+		}
+		for i, e := range events {
+			if e != fmt.Sprintf("%d", i) {
+				t.Fatalf("events of key%d are out of order: %v", k, events)
+			}
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var mu sync.Mutex
+	var failed []string
+	bus := NewBus(&BusOptions{
+		Workers:    2,
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+		OnError: func(key string, eventCtx EventContext, err error) {
+			mu.Lock()
+			failed = append(failed, key)
+			mu.Unlock()
+		},
+	})
+	defer bus.Close()
+
+	attempts := 0
+	bus.Deliver("flaky", testEvent("e"), func(EventContext) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+
+	broken := 0
+	bus.Deliver("broken", testEvent("e"), func(EventContext) error {
+		broken++
+		return errors.New("always")
+	})
+	bus.Wait()
+
+	if attempts != 3 {
+		t.Errorf("expected the flaky handler to succeed on its third attempt, got %d attempts", attempts)
+	}
+	if broken != 3 {
+		t.Errorf("expected the broken handler to be attempted 3 times, got %d", broken)
+	}
+	if len(failed) != 1 || failed[0] != "broken" {
+		t.Errorf("expected only the broken delivery to fail, got %v", failed)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	bus := NewBus(&BusOptions{Workers: 1, QueueSize: 1})
+	defer bus.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocking := func(EventContext) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+
+	if err := bus.Deliver("k", testEvent("1"), blocking); err != nil {
+		t.Fatalf("%s", err)
+	}
+	<-started
+
+	if err := bus.Deliver("k", testEvent("2"), func(EventContext) error { return nil }); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := bus.Deliver("k", testEvent("3"), func(EventContext) error { return nil }); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected the queue to be full, got %v", err)
+	}
+
+	close(release)
+	bus.Wait()
+}
+
+func TestSubscribe(t *testing.T) {
+	bus := NewBus(&BusOptions{Workers: 2})
+
+	var mu sync.Mutex
+	var events []string
+	unsubscribe := bus.Subscribe("test", func(eventCtx EventContext) error {
+		mu.Lock()
+		events = append(events, eventCtx.Event())
+		mu.Unlock()
+		return nil
+	})
+
+	bus.Publish(testEvent("1"))
+	bus.Publish(testEvent("2"))
+	bus.Wait()
+	unsubscribe()
+	bus.Publish(testEvent("3"))
+	bus.Close()
+
+	if len(events) != 2 || events[0] != "1" || events[1] != "2" {
+		t.Errorf("unexpected events %v", events)
+	}
+
+	if err := bus.Publish(testEvent("4")); err != nil {
+		t.Errorf("publishing without subscribers should not fail, got %v", err)
+	}
+	if err := bus.Deliver("k", testEvent("5"), func(EventContext) error { return nil }); err != ErrBusClosed {
+		t.Errorf("expected the bus to be closed, got %v", err)
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 var coaInvalidEventContext = errors.New("Invalid event context for function child_of_assign. Valid event contexts are AssignTo, Assign, DeassignFrom, and Deassign")
 
-var _ FunctionExecutor = &ChildOfAssignExecutor{}
+var _ TypedFunctionExecutor = &ChildOfAssignExecutor{}
 
 type ChildOfAssignExecutor struct{}
 
@@ -19,6 +19,10 @@ func (f *ChildOfAssignExecutor) Name() string {
 func (f *ChildOfAssignExecutor) NumParams() int {
 	return 0
 }
+
+func (f *ChildOfAssignExecutor) Signature() *Signature {
+	return &Signature{Returns: NODE}
+}
 func (f *ChildOfAssignExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
 	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
 	var child *graph.Node
@@ -0,0 +1,59 @@
+package epp
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time to the EPP and waits between the retries of a failed response, tests use a ManualClock to fire
+// the scheduled rules and retry without waiting.
+type Clock interface {
+	Now() time.Time
+	Sleep(time.Duration)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// SystemClock is the default clock, it reads the system time.
+var SystemClock Clock = systemClock{}
+
+// ManualClock only moves when it is set or advanced.
+type ManualClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) Set(now time.Time) {
+	c.Lock()
+	c.now = now
+	c.Unlock()
+}
+
+func (c *ManualClock) Advance(d time.Duration) {
+	c.Lock()
+	c.now = c.now.Add(d)
+	c.Unlock()
+}
+
+// Sleep advances the clock rather than waiting.
+func (c *ManualClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
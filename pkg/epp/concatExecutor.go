@@ -0,0 +1,43 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"strings"
+)
+
+// ConcatExecutor joins the string values of its arguments, nodes are given by their names.
+type ConcatExecutor struct{}
+
+func (f *ConcatExecutor) Name() string {
+	return "concat"
+}
+
+func (f *ConcatExecutor) NumParams() int {
+	return 1
+}
+
+// any number of values
+func (f *ConcatExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{STRING}, Variadic: true, Returns: STRING}
+}
+
+func (f *ConcatExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	for i := 0; i < args.len(); i++ {
+		s, err := args.string(i)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(s)
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,47 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// ContainsExecutor tells if a set, like the names returned by get_children, contains the value.
+type ContainsExecutor struct{}
+
+func (f *ContainsExecutor) Name() string {
+	return "contains"
+}
+
+func (f *ContainsExecutor) NumParams() int {
+	return 2
+}
+
+func (f *ContainsExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{SET, STRING}, Returns: BOOLEAN}
+}
+
+func (f *ContainsExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	elements, err := args.strings(0)
+	if err != nil {
+		return nil, err
+	}
+	value, err := args.string(1)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range elements {
+		if e == value {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -13,75 +13,41 @@ func (f *CreateNodeExecutor) Name() string {
 }
 
 /**
- * parent name, parent type, parent properties, name, type, properties
+ * parent name, parent type, name, type, properties
  * @return
  */
 func (f *CreateNodeExecutor) NumParams() int {
-    return 6
+    return 5
 }
-func (f *CreateNodeExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
-    eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
-    args := function.Args
-
-    // first arg is the name, can be function that returns a string
-    parentNameArg := args[0]
-    parentName := parentNameArg.Value
-    if parentNameArg.Function != nil {
-        p, err := functionEvaluator.Eval(g, p, o, eventCtx, parentNameArg.Function)
-        if err != nil {
-            return nil, err
-        }
-        parentName = p.(string)
-    }
 
-    // second arg is the type, can be function
-    parentTypeArg := args[1]
-    parentType := parentTypeArg.Value
-    if parentTypeArg.Function != nil {
-        pt, err := functionEvaluator.Eval(g, p, o, eventCtx, parentTypeArg.Function)
-        if err != nil {
-            return nil, err
-        }
-        parentType = pt.(string)
-    }
+// the properties are optional
+func (f *CreateNodeExecutor) Signature() *Signature {
+    return &Signature{Params: []ValueType{STRING, STRING, STRING, STRING, PROPERTIES}, Optional: 1, Returns: NODE}
+}
 
-    // fourth arg is the name, can be function
-    nameArg := args[2]
-    name := nameArg.Value
-    if nameArg.Function != nil {
-        n, err := functionEvaluator.Eval(g, p, o, eventCtx, nameArg.Function)
-        if err != nil {
-            return nil, err
-        }
-        name = n.(string)
+func (f *CreateNodeExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+    eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+    args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+    if err != nil {
+        return nil, err
     }
 
-    // fifth arg is the type, can be function
-    typeArg := args[3]
-    t := typeArg.Value
-    if typeArg.Function != nil {
-        tt, err := functionEvaluator.Eval(g, p, o, eventCtx, typeArg.Function)
-        if err != nil {
+    // the parent name and type, then the name and type of the node, each can be a function returning a string
+    values := make([]string, 4)
+    for i := range values {
+        if values[i], err = args.string(i); err != nil {
             return nil, err
         }
-        t = tt.(string)
     }
+    parentName, parentType, name, t := values[0], values[1], values[2], values[3]
 
-    // sixth arg is the properties which is a map that has to come from a function
-    props := graph.NewPropertyMap()
-    if len(args) > 4 {
-        propsArg := args[4]
-        if propsArg.Function != nil {
-            ppt, err := functionEvaluator.Eval(g, p, o, eventCtx, propsArg.Function)
-            if err != nil {
-                return nil, err
-            }
-            props = ppt.(graph.PropertyMap)
-        }
+    // the properties are a map that has to come from a function
+    props, err := args.properties(4)
+    if err != nil {
+        return nil, err
     }
 
     var parentNode *graph.Node
-    var err error
     if len(parentName) > 0 {
         parentNode, err = g.Node(parentName)
         if err != nil {
@@ -0,0 +1,182 @@
+package epp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the search for the next time of an expression that never matches, like "0 0 30 2 *", gives up after this many years
+const cron_search_years = 5
+
+var cronShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names of the values starting at min, like jan for 1
+}
+
+var (
+	minuteField = cronField{name: "minute", min: 0, max: 59}
+	hourField   = cronField{name: "hour", min: 0, max: 23}
+	domField    = cronField{name: "day of month", min: 1, max: 31}
+	monthField  = cronField{name: "month", min: 1, max: 12,
+		names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	// 7 is accepted for sunday and folded into 0
+	dowField = cronField{name: "day of week", min: 0, max: 7,
+		names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// Cron is a parsed cron expression with the minute, hour, day of month, month and day of week fields. Each field is
+// a "*", a value, a range like "1-5" or a list of them separated by commas, optionally followed by a step like "*/15".
+// When both the day of month and the day of week are restricted a day matching either one matches, as in cron.
+type Cron struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+// ParseCron parses a cron expression, or one of the shorthands @yearly, @monthly, @weekly, @daily and @hourly.
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if shorthand, ok := cronShorthands[strings.ToLower(spec)]; ok {
+		spec = shorthand
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields but has %d", expr, len(fields))
+	}
+
+	c := &Cron{expr: expr}
+	var err error
+	if c.minute, err = minuteField.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	if c.hour, err = hourField.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	if c.dom, err = domField.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	if c.month, err = monthField.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	if c.dow, err = dowField.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domRestricted = !strings.HasPrefix(fields[2], "*")
+	c.dowRestricted = !strings.HasPrefix(fields[4], "*")
+
+	return c, nil
+}
+
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Next returns the first time after t matching the expression, in t's location. The zero time is returned when
+// nothing matches within the next 5 years.
+func (c *Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cron_search_years, 0, 0)
+
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case c.month&(1<<uint(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+
+	return dom && dow
+}
+
+// parse returns the values of the field as a bit set.
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			rng = item[:i]
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in the %s field", item[i+1:], f.name)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// "5/15" starts at 5 and runs to the end of the field
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q in the %s field", rng, f.name)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in the %s field, expected %d to %d", s, f.name, f.min, f.max)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,49 @@
+package epp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 30, 15, 0, time.UTC) // a thursday
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 1, 0, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 1, 0, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC)},
+		{"30 0 * * *", time.Date(2026, 1, 2, 0, 30, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * sat,sun", time.Date(2026, 1, 3, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 mar *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either the day of month or the day of week
+		{"0 0 15 * fri", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"5/20 1-3 * * *", time.Date(2026, 1, 1, 1, 5, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		cron, err := ParseCron(test.expr)
+		if err != nil {
+			t.Fatalf("%s: %s", test.expr, err)
+		}
+		if next := cron.Next(from); !next.Equal(test.next) {
+			t.Errorf("%s: expected %s, got %s", test.expr, test.next, next)
+		}
+	}
+}
+
+func TestParseInvalidCron(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"*/0 * * * *", "5-1 * * * *", "* * * foo *", "@sometimes"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected %q to be rejected", expr)
+		}
+	}
+}
@@ -14,6 +14,10 @@ func (f *CurrentProcessExecutor) Name() string {
 func (f *CurrentProcessExecutor) NumParams() int {
 	return 0
 }
+
+func (f *CurrentProcessExecutor) Signature() *Signature {
+	return &Signature{Returns: STRING}
+}
 func (f *CurrentProcessExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
 	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
 	return eventCtx.UserCtx().Process(), nil
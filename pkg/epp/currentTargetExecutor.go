@@ -14,6 +14,10 @@ func (f *CurrentTargetExecutor) Name() string {
 func (f *CurrentTargetExecutor) NumParams() int {
 	return 0
 }
+
+func (f *CurrentTargetExecutor) Signature() *Signature {
+	return &Signature{Returns: NODE}
+}
 func (f *CurrentTargetExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
 	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
 	return eventCtx.Target(), nil
@@ -0,0 +1,49 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"time"
+)
+
+// CurrentTimeExecutor returns the current time formatted with the layout (time.Format) given, RFC 3339 by default.
+// Now replaces the system clock, the EPP sets it to the clock of its options.
+type CurrentTimeExecutor struct {
+	Now func() time.Time
+}
+
+func (f *CurrentTimeExecutor) Name() string {
+	return "current_time"
+}
+
+func (f *CurrentTimeExecutor) NumParams() int {
+	return 1
+}
+
+// the layout is optional
+func (f *CurrentTimeExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{STRING}, Optional: 1, Returns: STRING}
+}
+
+func (f *CurrentTimeExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := time.RFC3339
+	if args.len() > 0 {
+		if layout, err = args.string(0); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now
+	if f.Now != nil {
+		now = f.Now
+	}
+
+	return now().Format(layout), nil
+}
@@ -14,6 +14,10 @@ func (f *CurrentUserExecutor) Name() string {
 func (f *CurrentUserExecutor) NumParams() int {
 	return 0
 }
+
+func (f *CurrentUserExecutor) Signature() *Signature {
+	return &Signature{Returns: NODE}
+}
 func (f *CurrentUserExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
 	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
 	return g.Node(eventCtx.UserCtx().User())
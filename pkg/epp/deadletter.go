@@ -0,0 +1,213 @@
+package epp
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"github.com/jtejido/ngac/pkg/pip/tx"
+	"sync"
+	"time"
+)
+
+const (
+	default_retries      = 3
+	default_retry_delay  = 100 * time.Millisecond
+	default_dead_letters = 1000
+)
+
+// DeadLetter is a failed response the error policy tolerated, kept so it can be inspected and replayed once its cause
+// is fixed.
+type DeadLetter struct {
+	ID         uint64
+	Obligation string
+	// the failed rule, empty when the whole obligation failed
+	Rule string
+	// index of the failed action of a skipped rule, -1 when the whole response failed
+	Action   int
+	Event    EventContext
+	Err      error
+	Attempts int
+	// when the dead letter was kept or last failed
+	Time time.Time
+}
+
+func (dl *DeadLetter) String() string {
+	var target string
+	if node := dl.Event.Target(); node != nil {
+		target = node.Name
+	}
+
+	failed := dl.Obligation
+	if len(dl.Rule) > 0 {
+		failed = fmt.Sprintf("%s[%s]", dl.Obligation, dl.Rule)
+	}
+	if dl.Action >= 0 {
+		failed = fmt.Sprintf("%s action %d", failed, dl.Action)
+	}
+
+	return fmt.Sprintf("%d: %s on %s %s failed after %d attempts: %s", dl.ID, failed, dl.Event.Event(), target, dl.Attempts, dl.Err)
+}
+
+// DeadLetters keeps the latest dead letters, the oldest are dropped past the limit.
+type DeadLetters struct {
+	sync.Mutex
+	seq     uint64
+	limit   int
+	clock   Clock
+	letters []*DeadLetter
+}
+
+// NewDeadLetters keeps up to limit dead letters, 1000 when limit isn't positive. The dead letters are timed with the
+// clock, the system clock when it is nil.
+func NewDeadLetters(limit int, clock Clock) *DeadLetters {
+	if limit <= 0 {
+		limit = default_dead_letters
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &DeadLetters{limit: limit, clock: clock, letters: make([]*DeadLetter, 0)}
+}
+
+// Add numbers and times the dead letter and keeps it.
+func (d *DeadLetters) Add(letter *DeadLetter) {
+	d.Lock()
+	defer d.Unlock()
+	d.seq++
+	letter.ID = d.seq
+	letter.Time = d.clock.Now()
+	if len(d.letters) >= d.limit {
+		d.letters = append(d.letters[:0:0], d.letters[len(d.letters)-d.limit+1:]...)
+	}
+	d.letters = append(d.letters, letter)
+}
+
+// All returns copies of the dead letters, the oldest first.
+func (d *DeadLetters) All() []*DeadLetter {
+	d.Lock()
+	defer d.Unlock()
+	letters := make([]*DeadLetter, len(d.letters))
+	for i, letter := range d.letters {
+		l := *letter
+		letters[i] = &l
+	}
+	return letters
+}
+
+// Get returns a copy of the dead letter, nil if there is none with the ID.
+func (d *DeadLetters) Get(id uint64) *DeadLetter {
+	d.Lock()
+	defer d.Unlock()
+	if i := d.index(id); i >= 0 {
+		l := *d.letters[i]
+		return &l
+	}
+	return nil
+}
+
+// Remove drops the dead letter, it returns false if there is none with the ID.
+func (d *DeadLetters) Remove(id uint64) bool {
+	d.Lock()
+	defer d.Unlock()
+	i := d.index(id)
+	if i < 0 {
+		return false
+	}
+	d.letters = append(d.letters[:i:i], d.letters[i+1:]...)
+	return true
+}
+
+// Failed records another failed attempt of the dead letter.
+func (d *DeadLetters) Failed(id uint64, err error) {
+	d.Lock()
+	defer d.Unlock()
+	if i := d.index(id); i >= 0 {
+		d.letters[i].Err = err
+		d.letters[i].Attempts++
+		d.letters[i].Time = d.clock.Now()
+	}
+}
+
+func (d *DeadLetters) index(id uint64) int {
+	for i, letter := range d.letters {
+		if letter.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Retry runs f until it succeeds, as many times as the retry policy allows and once for the other policies. The
+// delay between attempts doubles after each one, it is waited on the clock. It returns the number of attempts and the
+// last error.
+func Retry(clock Clock, policy *obligations.ErrorPolicy, f func() error) (attempts int, err error) {
+	retries, delay := 0, default_retry_delay
+	if policy != nil && policy.Policy == obligations.RETRY {
+		retries = default_retries
+		if policy.Retries > 0 {
+			retries = policy.Retries
+		}
+		if d, err := time.ParseDuration(policy.Delay); err == nil && d >= 0 {
+			delay = d
+		}
+	}
+
+	for attempts = 1; ; attempts++ {
+		if err = f(); err == nil || attempts > retries {
+			return
+		}
+
+		clock.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// ApplyWithPolicy applies the response of the rule following the error policy. With the abort policy the error is
+// returned as with Apply. With the skip policy each action runs in its own transaction, a failing one is rolled back
+// and the next one runs. With the retry policy the response runs in its own transaction, rolled back and run again
+// while it fails, waiting on the clock. The failures the policy tolerates are returned as dead letters.
+func ApplyWithPolicy(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, functionEvaluator *FunctionEvaluator, eventCtx EventContext, rule *obligations.Rule, obligationLabel string, policy *obligations.ErrorPolicy, clock Clock) ([]*DeadLetter, error) {
+	letter := func(action, attempts int, err error) *DeadLetter {
+		return &DeadLetter{Obligation: obligationLabel, Rule: rule.Label, Action: action, Event: eventCtx, Err: err,
+			Attempts: attempts}
+	}
+
+	switch policy.Policy {
+	case obligations.SKIP:
+		letters := make([]*DeadLetter, 0)
+		err := apply(g, p, o, functionEvaluator, eventCtx, rule, obligationLabel, nil, func(i int, err error) {
+			letters = append(letters, letter(i, 1, err))
+		})
+		if err != nil {
+			letters = append(letters, letter(-1, 1, err))
+		}
+		return letters, nil
+	case obligations.RETRY:
+		attempts, err := Retry(clock, policy, func() error {
+			return tx.NewMemTx(g, p, o).RunTx(func(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations) error {
+				return Apply(g, p, o, functionEvaluator, eventCtx, rule, obligationLabel)
+			})
+		})
+		if err != nil {
+			return []*DeadLetter{letter(-1, attempts, err)}, nil
+		}
+		return nil, nil
+	}
+
+	return nil, Apply(g, p, o, functionEvaluator, eventCtx, rule, obligationLabel)
+}
+
+// ActionRule returns the rule responding with only its i-th action, used to replay a skipped action.
+func ActionRule(rule *obligations.Rule, i int) (*obligations.Rule, error) {
+	if i < 0 || i >= len(rule.ResponsePattern.Actions) {
+		return nil, fmt.Errorf("rule %s has no action %d", rule.Label, i)
+	}
+
+	response := &obligations.ResponsePattern{
+		Condition:        rule.ResponsePattern.Condition,
+		NegatedCondition: rule.ResponsePattern.NegatedCondition,
+		Actions:          []obligations.Action{rule.ResponsePattern.Actions[i]},
+	}
+	return &obligations.Rule{Label: rule.Label, EventPattern: rule.EventPattern, ResponsePattern: response}, nil
+}
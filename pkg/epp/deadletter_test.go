@@ -0,0 +1,63 @@
+package epp
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"testing"
+)
+
+func TestDeadLettersLimit(t *testing.T) {
+	letters := NewDeadLetters(2, nil)
+	for i := 0; i < 3; i++ {
+		letters.Add(&DeadLetter{Obligation: fmt.Sprintf("o%d", i), Action: -1})
+	}
+
+	all := letters.All()
+	if len(all) != 2 || all[0].ID != 2 || all[1].ID != 3 {
+		t.Fatalf("expected the 2 latest dead letters, got %v", all)
+	}
+	if letters.Get(1) != nil {
+		t.Errorf("expected the oldest dead letter to be dropped")
+	}
+
+	letters.Failed(2, fmt.Errorf("again"))
+	if letter := letters.Get(2); letter.Attempts != 1 || letter.Err.Error() != "again" {
+		t.Errorf("expected the failed attempt to be recorded, got %d attempts and %v", letter.Attempts, letter.Err)
+	}
+	if !letters.Remove(2) || letters.Remove(2) || len(letters.All()) != 1 {
+		t.Errorf("expected the dead letter to be removed once")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	failing := func(failures int) (func() error, *int) {
+		calls := 0
+		return func() error {
+			calls++
+			if calls <= failures {
+				return fmt.Errorf("failed %d times", calls)
+			}
+			return nil
+		}, &calls
+	}
+
+	tests := []struct {
+		policy   *obligations.ErrorPolicy
+		failures int
+		attempts int
+		fails    bool
+	}{
+		{nil, 1, 1, true},
+		{&obligations.ErrorPolicy{Policy: obligations.SKIP}, 1, 1, true},
+		{&obligations.ErrorPolicy{Policy: obligations.RETRY, Delay: "0s"}, 2, 3, false},
+		{&obligations.ErrorPolicy{Policy: obligations.RETRY, Delay: "0s"}, 5, 4, true},
+		{&obligations.ErrorPolicy{Policy: obligations.RETRY, Retries: 1, Delay: "1ms"}, 5, 2, true},
+	}
+	for i, test := range tests {
+		f, calls := failing(test.failures)
+		attempts, err := Retry(SystemClock, test.policy, f)
+		if attempts != test.attempts || *calls != test.attempts || (err != nil) != test.fails {
+			t.Errorf("%d: expected %d attempts, got %d and %v", i, test.attempts, attempts, err)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package epp
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/pkg/common"
+	"github.com/jtejido/ngac/pkg/operations"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"github.com/jtejido/ngac/pkg/pip/tx"
+	"sort"
+	"strings"
+)
+
+// DryRunReport describes what an obligation would do in response to an event.
+type DryRunReport struct {
+	Obligation string
+	Rules      []*RuleReport
+	// Changes lists the changes the responses would make, in order
+	Changes []*Change
+}
+
+// Matched returns the reports of the rules matching the event.
+func (r *DryRunReport) Matched() []*RuleReport {
+	matched := make([]*RuleReport, 0)
+	for _, rule := range r.Rules {
+		if rule.Matched {
+			matched = append(matched, rule)
+		}
+	}
+
+	return matched
+}
+
+// RuleReport tells if the rule matched the event, if its response conditions passed and the actions it applied.
+type RuleReport struct {
+	Label                       string
+	Matched                     bool
+	Condition, NegatedCondition bool
+	Actions                     []*ActionReport
+}
+
+// ActionReport tells if the conditions of an action passed and if it was applied.
+type ActionReport struct {
+	Index                       int
+	Action                      string
+	Condition, NegatedCondition bool
+	Applied                     bool
+}
+
+const (
+	GRAPH_STORE        = "graph"
+	PROHIBITIONS_STORE = "prohibitions"
+	OBLIGATIONS_STORE  = "obligations"
+)
+
+// Change is a call a response made to one of the stores, like create_node(o2, O, oa1).
+type Change struct {
+	Rule  string
+	Store string
+	Op    string
+	Args  []string
+}
+
+func (c *Change) String() string {
+	return fmt.Sprintf("%s.%s(%s)", c.Store, c.Op, strings.Join(c.Args, ", "))
+}
+
+// DryRun evaluates the obligation's rules against the event like the EPP would, on a transaction over the store
+// that is never committed. The report is returned along with the error that would have failed the response.
+func DryRun(store common.PolicyStore, functionEvaluator *FunctionEvaluator, obligation *obligations.Obligation, eventCtx EventContext) (*DryRunReport, error) {
+	return dryRun(store, functionEvaluator, obligation.Label, obligation.EnabledRules(), eventCtx)
+}
+
+// DryRunRule evaluates a single rule of the obligation with the given label, see DryRun.
+func DryRunRule(store common.PolicyStore, functionEvaluator *FunctionEvaluator, obligationLabel string, rule *obligations.Rule, eventCtx EventContext) (*DryRunReport, error) {
+	return dryRun(store, functionEvaluator, obligationLabel, []*obligations.Rule{rule}, eventCtx)
+}
+
+func dryRun(store common.PolicyStore, functionEvaluator *FunctionEvaluator, label string, rules []*obligations.Rule, eventCtx EventContext) (*DryRunReport, error) {
+	report := &DryRunReport{Obligation: label, Rules: make([]*RuleReport, 0), Changes: make([]*Change, 0)}
+	r := &recorder{report: report}
+	g := &recordingGraph{tx.NewTxGraph(store.Graph()), r}
+	p := &recordingProhibitions{tx.NewTxProhibitions(store.Prohibitions()), r}
+	o := &recordingObligations{tx.NewTxObligations(store.Obligations()), r}
+
+	for _, rule := range rules {
+		ruleReport := &RuleReport{Label: rule.Label, Actions: make([]*ActionReport, 0)}
+		report.Rules = append(report.Rules, ruleReport)
+		if !Triggers(label, rule, eventCtx, g) {
+			continue
+		}
+
+		ruleReport.Matched = true
+		r.rule = rule.Label
+		if err := apply(g, p, o, functionEvaluator, eventCtx, rule, label, ruleReport, nil); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+func actionName(action obligations.Action) string {
+	switch action.(type) {
+	case *obligations.AssignAction:
+		return "assign"
+	case *obligations.CreateAction:
+		return "create"
+	case *obligations.DeleteAction:
+		return "delete"
+	case *obligations.DenyAction:
+		return "deny"
+	case *obligations.GrantAction:
+		return "grant"
+	case *obligations.FunctionAction:
+		return "function"
+	}
+
+	return fmt.Sprintf("%T", action)
+}
+
+type recorder struct {
+	report *DryRunReport
+	rule   string
+}
+
+func (r *recorder) record(store, op string, args ...string) {
+	r.report.Changes = append(r.report.Changes, &Change{r.rule, store, op, args})
+}
+
+// recordingGraph records the changes made to the transaction.
+type recordingGraph struct {
+	*tx.TxGraph
+	r *recorder
+}
+
+func (g *recordingGraph) CreatePolicyClass(name string, properties graph.PropertyMap) (*graph.Node, error) {
+	node, err := g.TxGraph.CreatePolicyClass(name, properties)
+	if err == nil {
+		g.r.record(GRAPH_STORE, "create_policy_class", name, propertiesString(properties))
+	}
+	return node, err
+}
+
+func (g *recordingGraph) CreateNode(name string, t graph.NodeType, properties graph.PropertyMap, initialParent string, additionalParents ...string) (*graph.Node, error) {
+	node, err := g.TxGraph.CreateNode(name, t, properties, initialParent, additionalParents...)
+	if err == nil {
+		args := append([]string{name, t.String(), propertiesString(properties), initialParent}, additionalParents...)
+		g.r.record(GRAPH_STORE, "create_node", args...)
+	}
+	return node, err
+}
+
+func (g *recordingGraph) UpdateNode(name string, properties graph.PropertyMap) error {
+	err := g.TxGraph.UpdateNode(name, properties)
+	if err == nil {
+		g.r.record(GRAPH_STORE, "update_node", name, propertiesString(properties))
+	}
+	return err
+}
+
+func (g *recordingGraph) RemoveNode(name string) {
+	if !g.TxGraph.Exists(name) {
+		return
+	}
+
+	g.TxGraph.RemoveNode(name)
+	g.r.record(GRAPH_STORE, "remove_node", name)
+}
+
+func (g *recordingGraph) Assign(child, parent string) error {
+	err := g.TxGraph.Assign(child, parent)
+	if err == nil {
+		g.r.record(GRAPH_STORE, "assign", child, parent)
+	}
+	return err
+}
+
+func (g *recordingGraph) Deassign(child, parent string) error {
+	err := g.TxGraph.Deassign(child, parent)
+	if err == nil {
+		g.r.record(GRAPH_STORE, "deassign", child, parent)
+	}
+	return err
+}
+
+func (g *recordingGraph) Associate(ua, target string, ops operations.OperationSet) error {
+	err := g.TxGraph.Associate(ua, target, ops)
+	if err == nil {
+		g.r.record(GRAPH_STORE, "associate", ua, target, operationsString(ops))
+	}
+	return err
+}
+
+func (g *recordingGraph) Dissociate(ua, target string) error {
+	err := g.TxGraph.Dissociate(ua, target)
+	if err == nil {
+		g.r.record(GRAPH_STORE, "dissociate", ua, target)
+	}
+	return err
+}
+
+type recordingProhibitions struct {
+	*tx.TxProhibitions
+	r *recorder
+}
+
+func (p *recordingProhibitions) Add(prohibition *prohibitions.Prohibition) {
+	p.TxProhibitions.Add(prohibition)
+	p.r.record(PROHIBITIONS_STORE, "add", prohibition.Name)
+}
+
+func (p *recordingProhibitions) Update(name string, prohibition *prohibitions.Prohibition) {
+	p.TxProhibitions.Update(name, prohibition)
+	p.r.record(PROHIBITIONS_STORE, "update", name)
+}
+
+func (p *recordingProhibitions) Remove(name string) {
+	if p.TxProhibitions.Get(name) == nil {
+		return
+	}
+
+	p.TxProhibitions.Remove(name)
+	p.r.record(PROHIBITIONS_STORE, "remove", name)
+}
+
+type recordingObligations struct {
+	*tx.TxObligations
+	r *recorder
+}
+
+func (o *recordingObligations) Add(obligation *obligations.Obligation, enable bool) {
+	o.TxObligations.Add(obligation, enable)
+	o.r.record(OBLIGATIONS_STORE, "add", obligation.Label, fmt.Sprintf("%t", enable))
+}
+
+func (o *recordingObligations) Update(label string, obligation *obligations.Obligation) {
+	o.TxObligations.Update(label, obligation)
+	o.r.record(OBLIGATIONS_STORE, "update", label)
+}
+
+func (o *recordingObligations) Remove(label string) {
+	if o.TxObligations.Get(label) == nil {
+		return
+	}
+
+	o.TxObligations.Remove(label)
+	o.r.record(OBLIGATIONS_STORE, "remove", label)
+}
+
+func (o *recordingObligations) SetEnable(label string, enabled bool) {
+	if o.TxObligations.Get(label) == nil {
+		return
+	}
+
+	o.TxObligations.SetEnable(label, enabled)
+	o.r.record(OBLIGATIONS_STORE, "set_enable", label, fmt.Sprintf("%t", enabled))
+}
+
+func (o *recordingObligations) AddRule(label string, rule *obligations.Rule) error {
+	err := o.TxObligations.AddRule(label, rule)
+	if err == nil {
+		o.r.record(OBLIGATIONS_STORE, "add_rule", label, rule.Label)
+	}
+	return err
+}
+
+func (o *recordingObligations) RemoveRule(label, ruleLabel string) error {
+	err := o.TxObligations.RemoveRule(label, ruleLabel)
+	if err == nil {
+		o.r.record(OBLIGATIONS_STORE, "remove_rule", label, ruleLabel)
+	}
+	return err
+}
+
+func (o *recordingObligations) ReplaceRule(label, ruleLabel string, rule *obligations.Rule) error {
+	err := o.TxObligations.ReplaceRule(label, ruleLabel, rule)
+	if err == nil {
+		o.r.record(OBLIGATIONS_STORE, "replace_rule", label, ruleLabel)
+	}
+	return err
+}
+
+func (o *recordingObligations) SetRuleEnable(label, ruleLabel string, enabled bool) error {
+	err := o.TxObligations.SetRuleEnable(label, ruleLabel, enabled)
+	if err == nil {
+		o.r.record(OBLIGATIONS_STORE, "set_rule_enable", label, ruleLabel, fmt.Sprintf("%t", enabled))
+	}
+	return err
+}
+
+// propertiesString writes the properties as sorted key=value pairs so the changes are stable.
+func propertiesString(properties graph.PropertyMap) string {
+	pairs := make([]string, 0, len(properties))
+	for k, v := range properties {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func operationsString(ops operations.OperationSet) string {
+	list := make([]string, 0, ops.Len())
+	for op := range ops.Iter() {
+		list = append(list, op.(string))
+	}
+	sort.Strings(list)
+
+	return "{" + strings.Join(list, ", ") + "}"
+}
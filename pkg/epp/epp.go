@@ -6,6 +6,7 @@ import (
 	"github.com/jtejido/ngac/pkg/pip/graph"
 	"github.com/jtejido/ngac/pkg/pip/obligations"
 	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"github.com/jtejido/ngac/pkg/pip/tx"
 )
 
 type EPP interface {
@@ -13,6 +14,13 @@ type EPP interface {
 }
 
 func Apply(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, functionEvaluator *FunctionEvaluator, eventCtx EventContext, rule *obligations.Rule, obligationLabel string) error {
+	return apply(g, p, o, functionEvaluator, eventCtx, rule, obligationLabel, nil, nil)
+}
+
+// apply evaluates the response of the rule, recording the conditions and the actions applied in the report if
+// there's one. When skip is set each action runs in its own transaction, a failing one is rolled back and handed to
+// skip before the next one runs.
+func apply(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, functionEvaluator *FunctionEvaluator, eventCtx EventContext, rule *obligations.Rule, obligationLabel string, report *RuleReport, skip func(int, error)) error {
 	// check the response condition
 	responsePattern := rule.ResponsePattern
 	cc, err := checkCondition(g, p, o, functionEvaluator, responsePattern.Condition, eventCtx)
@@ -23,33 +31,64 @@ func Apply(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations
 	if err != nil {
 		return err
 	}
+	if report != nil {
+		report.Condition, report.NegatedCondition = cc, nc
+	}
 	if !cc || !nc {
 		return nil
 	}
 
-	for _, action := range rule.ResponsePattern.Actions {
-		cc, err := checkCondition(g, p, o, functionEvaluator, action.Condition(), eventCtx)
-		if err != nil {
-			return err
-		}
-		nc, err := checkNegatedCondition(g, p, o, functionEvaluator, action.NegatedCondition(), eventCtx)
-		if err != nil {
-			return err
-		}
-		if !cc {
-			continue
-		} else if !nc {
+	for i, action := range rule.ResponsePattern.Actions {
+		if skip == nil {
+			if err := applyIf(g, p, o, functionEvaluator, obligationLabel, eventCtx, i, action, report); err != nil {
+				return err
+			}
 			continue
 		}
 
-		if err := applyAction(g, p, o, functionEvaluator, obligationLabel, eventCtx, action); err != nil {
-			return err
+		i, action := i, action
+		if err := tx.NewMemTx(g, p, o).RunTx(func(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations) error {
+			return applyIf(g, p, o, functionEvaluator, obligationLabel, eventCtx, i, action, report)
+		}); err != nil {
+			skip(i, err)
 		}
 	}
 
 	return nil
 }
 
+// applyIf applies the action when its conditions are met.
+func applyIf(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, functionEvaluator *FunctionEvaluator, obligationLabel string, eventCtx EventContext, i int, action obligations.Action, report *RuleReport) error {
+	cc, err := checkCondition(g, p, o, functionEvaluator, action.Condition(), eventCtx)
+	if err != nil {
+		return err
+	}
+	nc, err := checkNegatedCondition(g, p, o, functionEvaluator, action.NegatedCondition(), eventCtx)
+	if err != nil {
+		return err
+	}
+
+	var actionReport *ActionReport
+	if report != nil {
+		actionReport = &ActionReport{Index: i, Action: actionName(action), Condition: cc, NegatedCondition: nc}
+		report.Actions = append(report.Actions, actionReport)
+	}
+	if !cc {
+		return nil
+	} else if !nc {
+		return nil
+	}
+
+	if err := applyAction(g, p, o, functionEvaluator, obligationLabel, eventCtx, action); err != nil {
+		return err
+	}
+	if actionReport != nil {
+		actionReport.Applied = true
+	}
+
+	return nil
+}
+
 func checkCondition(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, functionEvaluator *FunctionEvaluator, condition *obligations.Condition, eventCtx EventContext) (bool, error) {
 	if condition == nil {
 		return true, nil
@@ -57,11 +96,11 @@ func checkCondition(g graph.Graph, p prohibitions.Prohibitions, o obligations.Ob
 
 	functions := condition.Condition
 	for _, f := range functions {
-		result, err := functionEvaluator.Eval(g, p, o, eventCtx, f)
+		result, err := functionEvaluator.EvalBool(g, p, o, eventCtx, f)
 		if err != nil {
 			return false, err
 		}
-		if !result.(bool) {
+		if !result {
 			return false, nil
 		}
 	}
@@ -79,11 +118,11 @@ func checkNegatedCondition(g graph.Graph, p prohibitions.Prohibitions, o obligat
 
 	functions := condition.Condition
 	for _, f := range functions {
-		result, err := functionEvaluator.Eval(g, p, o, eventCtx, f)
+		result, err := functionEvaluator.EvalBool(g, p, o, eventCtx, f)
 		if err != nil {
 			return false, err
 		}
-		if result.(bool) {
+		if result {
 			return false, err
 		}
 	}
@@ -130,7 +169,16 @@ func applyGrantAction(g graph.Graph, p prohibitions.Prohibitions, o obligations.
 		return err
 	}
 
-	return g.Associate(subjectNode.Name, targetNode.Name, operations.NewOperationSet(op))
+	return g.Associate(subjectNode.Name, targetNode.Name, toOperationSet(op))
+}
+
+func toOperationSet(ops []string) operations.OperationSet {
+	set := operations.NewOperationSet()
+	for _, op := range ops {
+		set.Add(op)
+	}
+
+	return set
 }
 
 func applyDenyAction(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, functionEvaluator *FunctionEvaluator, eventCtx EventContext, action *obligations.DenyAction) error {
@@ -147,7 +195,7 @@ func applyDenyAction(g graph.Graph, p prohibitions.Prohibitions, o obligations.O
 		return err
 	}
 
-	builder := prohibitions.NewBuilder(action.Label, denySubject, operations.NewOperationSet(ops))
+	builder := prohibitions.NewBuilder(action.Label, denySubject, toOperationSet(ops))
 	builder.Intersection = target.Intersection
 	for contName, v := range denyNodes {
 		builder.AddContainer(contName, v)
@@ -195,7 +243,8 @@ func toDenySubject(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obl
 
 	if subject.Function != nil {
 		function := subject.Function
-		t, err := functionEvaluator.Eval(g, p, o, eventCtx, function)
+		// functions like current_user return the node itself, given by its name
+		t, err := functionEvaluator.eval(g, p, o, eventCtx, function, STRING)
 		if err != nil {
 			return "", err
 		}
@@ -275,17 +324,14 @@ func applyDeleteAction(g graph.Graph, p prohibitions.Prohibitions, o obligations
 		}
 	}
 
-	rules := action.Rules
-	if rules != nil {
-		for _, label := range rules {
-			allObligations := o.All()
-			for _, obligation := range allObligations {
-				oblRules := obligation.Rules
-				for i := 0; i < len(oblRules); i++ {
-					if oblRules[i].Label == label {
-						oblRules = append(oblRules[:i], oblRules[i+1:]...)
-					}
-				}
+	// the rules are removed from every obligation having them
+	for _, label := range action.Rules {
+		for _, obligation := range o.All() {
+			if obligation.Rule(label) == nil {
+				continue
+			}
+			if err := o.RemoveRule(obligation.Label, label); err != nil {
+				return err
 			}
 		}
 	}
@@ -295,11 +341,7 @@ func applyDeleteAction(g graph.Graph, p prohibitions.Prohibitions, o obligations
 
 func toNode(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, functionEvaluator *FunctionEvaluator, eventCtx EventContext, evrNode *obligations.EvrNode) (node *graph.Node, err error) {
 	if evrNode.Function != nil {
-		n, err := functionEvaluator.Eval(g, p, o, eventCtx, evrNode.Function)
-		if err != nil {
-			return nil, err
-		}
-		node = n.(*graph.Node)
+		return functionEvaluator.EvalNode(g, p, o, eventCtx, evrNode.Function)
 	} else {
 		if len(evrNode.Name) > 0 {
 			node, err = g.Node(evrNode.Name)
@@ -320,7 +362,9 @@ func applyCreateAction(g graph.Graph, p prohibitions.Prohibitions, o obligations
 	rules := action.Rules
 	if rules != nil {
 		for _, rule := range rules {
-			createRule(g, p, o, label, eventCtx, rule)
+			if err := createRule(g, p, o, label, eventCtx, rule); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -343,13 +387,17 @@ func applyCreateAction(g graph.Graph, p prohibitions.Prohibitions, o obligations
 	return nil
 }
 
-func createRule(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, obligationLabel string, eventCtx EventContext, rule *obligations.Rule) {
-	// add the rule to the obligation
+func createRule(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, obligationLabel string, eventCtx EventContext, rule *obligations.Rule) error {
+	// add the rule to the obligation, a rule created again replaces the previous one
 	obligation := o.Get(obligationLabel)
-	rules := obligation.Rules
-	rules = append(rules, rule)
-	obligation.Rules = rules
-	o.Update(obligationLabel, obligation)
+	if obligation == nil {
+		return fmt.Errorf("obligation %s does not exist", obligationLabel)
+	}
+	if obligation.Rule(rule.Label) != nil {
+		return o.ReplaceRule(obligationLabel, rule.Label, rule)
+	}
+
+	return o.AddRule(obligationLabel, rule)
 }
 
 func applyAssignAction(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, functionEvaluator *FunctionEvaluator, eventCtx EventContext, action *obligations.AssignAction) error {
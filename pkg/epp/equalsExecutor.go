@@ -0,0 +1,41 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// EqualsExecutor compares the string values of its arguments, nodes are compared by name.
+type EqualsExecutor struct{}
+
+func (f *EqualsExecutor) Name() string {
+	return "equals"
+}
+
+func (f *EqualsExecutor) NumParams() int {
+	return 2
+}
+
+func (f *EqualsExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{ANY, ANY}, Returns: BOOLEAN}
+}
+
+func (f *EqualsExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := args.string(0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := args.string(1)
+	if err != nil {
+		return nil, err
+	}
+
+	return a == b, nil
+}
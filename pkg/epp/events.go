@@ -5,6 +5,7 @@ import (
 	"github.com/jtejido/ngac/pkg/context"
 	"github.com/jtejido/ngac/pkg/pip/graph"
 	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"time"
 )
 
 const (
@@ -14,13 +15,17 @@ const (
 	DEASSIGN_EVENT      = "deassign"
 	CREATE_NODE_EVENT   = "create node"
 	DELETE_NODE_EVENT   = "delete node"
+	UPDATE_NODE_EVENT   = "update node"
 	ACCESS_DENIED_EVENT = "access denied"
+	TIME_EVENT          = "time"
 )
 
 type EventContext interface {
 	Event() string
 	Target() *graph.Node
 	UserCtx() context.Context
+	// Lineage is the chain of obligation responses that caused the event, empty for an event triggered by a user.
+	Lineage() []Cause
 	MatchesPattern(*obligations.EventPattern, graph.Graph) bool
 }
 
@@ -28,10 +33,12 @@ type eventContext struct {
 	userCtx context.Context
 	event   string
 	target  *graph.Node
+	// parents of the target when they can't be read from the graph, like for a deleted node
+	parents set.Set
 }
 
 func NewEventContext(userCtx context.Context, event string, target *graph.Node) *eventContext {
-	return &eventContext{userCtx, event, target}
+	return &eventContext{userCtx: userCtx, event: event, target: target}
 }
 
 func (ctx *eventContext) Event() string {
@@ -46,6 +53,10 @@ func (ctx *eventContext) UserCtx() context.Context {
 	return ctx.userCtx
 }
 
+func (ctx *eventContext) Lineage() []Cause {
+	return lineageOf(ctx.userCtx)
+}
+
 func (ctx *eventContext) MatchesPattern(pattern *obligations.EventPattern, g graph.Graph) bool {
 	if pattern.Operations != nil {
 		var found bool
@@ -65,7 +76,7 @@ func (ctx *eventContext) MatchesPattern(pattern *obligations.EventPattern, g gra
 	matchTarget := pattern.Target
 
 	return ctx.subjectMatches(g, ctx.userCtx.User(), ctx.userCtx.Process(), matchSubject) &&
-		ctx.pcMatches(ctx.userCtx.User(), ctx.userCtx.Process(), matchPolicyClass) &&
+		ctx.pcMatches(g, matchPolicyClass) &&
 		ctx.targetMatches(g, ctx.target, matchTarget)
 }
 
@@ -144,9 +155,73 @@ func (ctx *eventContext) checkAnyUser(g graph.Graph, userNode *graph.Node, anyUs
 	return false
 }
 
-func (ctx *eventContext) pcMatches(user, process string, matchPolicyClass *obligations.PolicyClass) bool {
-	// not yet implemented
-	return true
+func (ctx *eventContext) pcMatches(g graph.Graph, matchPolicyClass *obligations.PolicyClass) bool {
+	// any policy class
+	if matchPolicyClass == nil || (len(matchPolicyClass.AnyOf) == 0 && len(matchPolicyClass.EachOf) == 0) {
+		return true
+	}
+
+	pcs := ctx.policyClassesOf(g)
+
+	if len(matchPolicyClass.EachOf) > 0 {
+		for _, pc := range matchPolicyClass.EachOf {
+			if !pcs.Contains(pc) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, pc := range matchPolicyClass.AnyOf {
+		if pcs.Contains(pc) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// policyClassesOf returns the names of the policy classes the target is contained in.
+func (ctx *eventContext) policyClassesOf(g graph.Graph) set.Set {
+	pcs := set.NewSet()
+	if ctx.target == nil {
+		return pcs
+	}
+
+	if ctx.target.Type == graph.PC {
+		pcs.Add(ctx.target.Name)
+	}
+
+	it := ctx.targetContainers(g).Iterator()
+	for it.HasNext() {
+		node := it.Next().(*graph.Node)
+		if node.Type == graph.PC {
+			pcs.Add(node.Name)
+		}
+	}
+
+	return pcs
+}
+
+// targetContainers returns every node the target is contained in.
+func (ctx *eventContext) targetContainers(g graph.Graph) set.Set {
+	if ctx.parents == nil {
+		return ctx.containersOf(g, ctx.target.Name)
+	}
+
+	nodes := set.NewSet()
+	for parent := range ctx.parents.Iter() {
+		nn, err := g.Node(parent.(string))
+		if err != nil {
+			// the parent was removed as well
+			continue
+		}
+		nodes.Add(nn)
+		nodes.AddFrom(ctx.containersOf(g, nn.Name))
+	}
+
+	return nodes
 }
 
 func (ctx *eventContext) targetMatches(g graph.Graph, target *graph.Node, matchTarget *obligations.Target) bool {
@@ -164,7 +239,7 @@ func (ctx *eventContext) targetMatches(g graph.Graph, target *graph.Node, matchT
 		}
 
 		// check that target is contained in any container
-		containers := ctx.containersOf(g, target.Name)
+		containers := ctx.targetContainers(g)
 		for _, evrContainer := range matchTarget.Containers {
 			it := containers.Iterator()
 			for it.HasNext() {
@@ -280,7 +355,6 @@ func (ae *AssociationEvent) Target() *graph.Node {
 
 type CreateNodeEvent struct {
 	eventContext
-	parents set.Set
 }
 
 func NewCreateNodeEvent(userCtx context.Context, deletedNode *graph.Node, initialParent string, additionalParents ...string) *CreateNodeEvent {
@@ -345,13 +419,12 @@ func (ae *DeleteAssociationEvent) Target() *graph.Node {
 
 type DeleteNodeEvent struct {
 	eventContext
-	parents set.Set
 }
 
 func NewDeleteNodeEvent(userCtx context.Context, deletedNode *graph.Node, parents set.Set) *DeleteNodeEvent {
 	ans := new(DeleteNodeEvent)
 	ans.userCtx = userCtx
-	ans.event = "delete association"
+	ans.event = DELETE_NODE_EVENT
 	ans.target = deletedNode
 	ans.parents = parents
 	return ans
@@ -368,3 +441,62 @@ func NewObjectAccessEvent(userCtx context.Context, event string, target *graph.N
 	ans.target = target
 	return ans
 }
+
+type UpdateNodeEvent struct {
+	eventContext
+	OldProperties, NewProperties graph.PropertyMap
+}
+
+func NewUpdateNodeEvent(userCtx context.Context, updatedNode *graph.Node, oldProperties, newProperties graph.PropertyMap) *UpdateNodeEvent {
+	ans := new(UpdateNodeEvent)
+	ans.userCtx = userCtx
+	ans.event = UPDATE_NODE_EVENT
+	ans.target = updatedNode
+	ans.OldProperties = oldProperties
+	ans.NewProperties = newProperties
+	return ans
+}
+
+// AccessDeniedEvent is emitted when a user is denied operations on a target.
+type AccessDeniedEvent struct {
+	eventContext
+	Operations []string
+}
+
+func NewAccessDeniedEvent(userCtx context.Context, target *graph.Node, ops ...string) *AccessDeniedEvent {
+	ans := new(AccessDeniedEvent)
+	ans.userCtx = userCtx
+	ans.event = ACCESS_DENIED_EVENT
+	ans.target = target
+	ans.Operations = ops
+	return ans
+}
+
+// TimeEvent is emitted by the scheduler when a timer of a scheduled rule is due. It fires that rule only, the rules
+// of other obligations can still respond to it with the "time" operation. The user is the one of the event that
+// started the timer, or the obligation's user for a cron schedule.
+type TimeEvent struct {
+	eventContext
+	Time       time.Time
+	Obligation string
+	Rule       string
+	// the event that started the timer, empty for a cron schedule
+	Cause string
+}
+
+func NewTimeEvent(userCtx context.Context, target *graph.Node, timer *obligations.Timer) *TimeEvent {
+	ans := new(TimeEvent)
+	ans.userCtx = userCtx
+	ans.event = TIME_EVENT
+	ans.target = target
+	ans.Time = timer.Due
+	ans.Obligation = timer.Obligation
+	ans.Rule = timer.Rule
+	ans.Cause = timer.Event
+	return ans
+}
+
+// Fires tells if the event is the timer of the obligation's rule.
+func (te *TimeEvent) Fires(obligation, rule string) bool {
+	return te.Obligation == obligation && te.Rule == rule
+}
@@ -0,0 +1,46 @@
+package epp
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// FormatExecutor formats the string values of its arguments with the format (fmt.Sprintf) given first.
+type FormatExecutor struct{}
+
+func (f *FormatExecutor) Name() string {
+	return "format"
+}
+
+func (f *FormatExecutor) NumParams() int {
+	return 1
+}
+
+// the format followed by any number of values
+func (f *FormatExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{STRING, ANY}, Optional: 1, Variadic: true, Returns: STRING}
+}
+
+func (f *FormatExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := args.string(0)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, args.len()-1)
+	for i := range values {
+		if values[i], err = args.string(i + 1); err != nil {
+			return nil, err
+		}
+	}
+
+	return fmt.Sprintf(format, values...), nil
+}
@@ -27,6 +27,21 @@ func NewFunctionEvaluator() *FunctionEvaluator {
 	ans.Add(new(IsNodeContainedInExecutor))
 	ans.Add(new(ParentOfAssignExecutor))
 	ans.Add(new(ToPropertiesExecutor))
+	ans.Add(new(ConcatExecutor))
+	ans.Add(new(FormatExecutor))
+	ans.Add(new(GetPropertyExecutor))
+	ans.Add(new(HasPropertyExecutor))
+	ans.Add(new(PropertyEqualsExecutor))
+	ans.Add(new(EqualsExecutor))
+	ans.Add(new(AndExecutor))
+	ans.Add(new(OrExecutor))
+	ans.Add(new(NotExecutor))
+	ans.Add(new(ContainsExecutor))
+	ans.Add(new(GetAncestorsExecutor))
+	ans.Add(new(GetDescendantsExecutor))
+	ans.Add(new(GetPolicyClassesExecutor))
+	ans.Add(new(CurrentTimeExecutor))
+	ans.Add(new(IsNodeTypeExecutor))
 	return ans
 }
 
@@ -38,17 +53,79 @@ func (fe *FunctionEvaluator) Remove(executor FunctionExecutor) {
 	delete(fe.funExecs, executor.Name())
 }
 
-func (fe *FunctionEvaluator) FunctionExecutor(name string) FunctionExecutor {
-	var f FunctionExecutor
-	var ok bool
-	if f, ok = fe.funExecs[name]; !ok {
-		panic(fmt.Sprintf("%s is not a recognized function", name))
-	}
-	return f
+// Lookup returns the executor registered with the name.
+func (fe *FunctionEvaluator) Lookup(name string) (FunctionExecutor, bool) {
+	f, ok := fe.funExecs[name]
+	return f, ok
 }
 
+// Eval checks the arguments of the function against its signature, executes it and coerces the result to the type
+// it declares.
 func (fe *FunctionEvaluator) Eval(graph graph.Graph, prohibitions prohibitions.Prohibitions, obligations obligations.Obligations, eventCtx EventContext, function *obligations.Function) (interface{}, error) {
-	functionName := function.Name
-	functionExecutor := fe.FunctionExecutor(functionName)
-	return functionExecutor.Exec(graph, prohibitions, obligations, eventCtx, function, fe)
+	return fe.eval(graph, prohibitions, obligations, eventCtx, function, ANY)
+}
+
+// EvalBool evaluates a function expected to return a boolean, like a condition.
+func (fe *FunctionEvaluator) EvalBool(graph graph.Graph, prohibitions prohibitions.Prohibitions, obligations obligations.Obligations, eventCtx EventContext, function *obligations.Function) (bool, error) {
+	result, err := fe.eval(graph, prohibitions, obligations, eventCtx, function, BOOLEAN)
+	if err != nil {
+		return false, err
+	}
+
+	return result.(bool), nil
+}
+
+// EvalNode evaluates a function expected to return a node, it's an error when there is none.
+func (fe *FunctionEvaluator) EvalNode(g graph.Graph, prohibitions prohibitions.Prohibitions, obligations obligations.Obligations, eventCtx EventContext, function *obligations.Function) (*graph.Node, error) {
+	result, err := fe.eval(g, prohibitions, obligations, eventCtx, function, NODE)
+	if err != nil {
+		return nil, err
+	}
+
+	node := result.(*graph.Node)
+	if node == nil {
+		return nil, fmt.Errorf("%s returned no node", function.Name)
+	}
+
+	return node, nil
+}
+
+// Check checks the function and the nested ones without evaluating them, returning the first problem found.
+func (fe *FunctionEvaluator) Check(function *obligations.Function, expected ValueType) (err error) {
+	fe.check("", function, expected, true, func(pointer, format string, a ...interface{}) {
+		if err == nil {
+			err = fmt.Errorf(format, a...)
+		}
+	})
+
+	return
+}
+
+func (fe *FunctionEvaluator) eval(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations, eventCtx EventContext, function *obligations.Function, expected ValueType) (interface{}, error) {
+	// the nested functions are checked when they are evaluated
+	var err error
+	fe.check("", function, expected, false, func(pointer, format string, a ...interface{}) {
+		if err == nil {
+			err = fmt.Errorf(format, a...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	executor, _ := fe.Lookup(function.Name)
+	sig := signature(executor)
+	result, err := executor.Exec(g, p, o, eventCtx, function, fe)
+	if err != nil {
+		return nil, err
+	}
+
+	if result, err = coerce(g, result, sig.Returns); err != nil {
+		return nil, fmt.Errorf("%s: %w", function.Name, err)
+	}
+	if result, err = coerce(g, result, expected); err != nil {
+		return nil, fmt.Errorf("%s: %w", function.Name, err)
+	}
+
+	return result, nil
 }
@@ -34,3 +34,21 @@ type FunctionExecutor interface {
 	Exec(graph graph.Graph, prohibitions prohibitions.Prohibitions, obligations obligations.Obligations,
 		eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error)
 }
+
+// TypedFunctionExecutor is implemented by functions declaring the types of their parameters and of what they
+// return. The FunctionEvaluator checks the arguments against the signature and coerces the result to its type, the
+// obligation validator uses it to check nested functions. Functions without a signature take NumParams arguments of
+// any type.
+type TypedFunctionExecutor interface {
+	FunctionExecutor
+
+	Signature() *Signature
+}
+
+// ParamRangeFunctionExecutor is implemented by untyped functions whose trailing parameters are optional, NumParams
+// being the most they take. A negative max means there's no upper bound.
+type ParamRangeFunctionExecutor interface {
+	FunctionExecutor
+
+	ParamRange() (min, max int)
+}
@@ -0,0 +1,37 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// GetAncestorsExecutor returns the names of the nodes a node is contained in.
+type GetAncestorsExecutor struct{}
+
+func (f *GetAncestorsExecutor) Name() string {
+	return "get_ancestors"
+}
+
+func (f *GetAncestorsExecutor) NumParams() int {
+	return 1
+}
+
+func (f *GetAncestorsExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{NODE}, Returns: SET}
+}
+
+func (f *GetAncestorsExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := args.node(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return ancestors(g, node.Name), nil
+}
@@ -12,12 +12,17 @@ func (f *GetChildrenExecutor) Name() string {
     return "get_children"
 }
 func (f *GetChildrenExecutor) NumParams() int {
-    return 1
+    return 3
 }
+
+// the arguments of get_node
+func (f *GetChildrenExecutor) Signature() *Signature {
+    return &Signature{Params: []ValueType{STRING, STRING, PROPERTIES}, Optional: 1, Returns: SET}
+}
+
 func (f *GetChildrenExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
     eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
-    getNodeExecutor := functionEvaluator.FunctionExecutor("get_node")
-    node, err := getNodeExecutor.Exec(g, p, o, eventCtx, function, functionEvaluator)
+    node, err := new(GetNodeExecutor).Exec(g, p, o, eventCtx, function, functionEvaluator)
     if err != nil {
         return nil, err
     }
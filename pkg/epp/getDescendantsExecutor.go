@@ -0,0 +1,37 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// GetDescendantsExecutor returns the names of the nodes contained in a node.
+type GetDescendantsExecutor struct{}
+
+func (f *GetDescendantsExecutor) Name() string {
+	return "get_descendants"
+}
+
+func (f *GetDescendantsExecutor) NumParams() int {
+	return 1
+}
+
+func (f *GetDescendantsExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{NODE}, Returns: SET}
+}
+
+func (f *GetDescendantsExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := args.node(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return descendants(g, node.Name), nil
+}
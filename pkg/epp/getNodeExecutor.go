@@ -1,7 +1,6 @@
 package epp
 
 import (
-    "fmt"
     "github.com/jtejido/ngac/pkg/pip/graph"
     "github.com/jtejido/ngac/pkg/pip/obligations"
     "github.com/jtejido/ngac/pkg/pip/prohibitions"
@@ -13,47 +12,36 @@ func (f *GetNodeExecutor) Name() string {
     return "get_node"
 }
 func (f *GetNodeExecutor) NumParams() int {
-    return 2
+    return 3
 }
+
+// the properties are optional
+func (f *GetNodeExecutor) Signature() *Signature {
+    return &Signature{Params: []ValueType{STRING, STRING, PROPERTIES}, Optional: 1, Returns: NODE}
+}
+
 func (f *GetNodeExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
     eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
-    args := function.Args
-    if args == nil || len(args) < f.NumParams() || len(args) > f.NumParams() {
-        return nil, fmt.Errorf("%s expected at least two arguments (name and type) but found none", f.Name())
+    args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+    if err != nil {
+        return nil, err
     }
 
     // first arg should be a string or a function tht returns a string
-    arg := args[0]
-    name := arg.Value
-    if arg.Function != nil {
-        n, err := functionEvaluator.Eval(g, p, o, eventCtx, arg.Function)
-        if err != nil {
-            return nil, err
-        }
-        name = n.(string)
+    name, err := args.string(0)
+    if err != nil {
+        return nil, err
     }
 
     // second arg should be the type of the node to search for
-    arg = args[1]
-    t := arg.Value
-    if arg.Function != nil {
-        ty, err := functionEvaluator.Eval(g, p, o, eventCtx, arg.Function)
-        if err != nil {
-            return nil, err
-        }
-        t = ty.(string)
+    t, err := args.string(1)
+    if err != nil {
+        return nil, err
     }
 
-    props := graph.NewPropertyMap()
-    if len(args) > 2 {
-        arg = args[0]
-        if arg.Function != nil {
-            pr, err := functionEvaluator.Eval(g, p, o, eventCtx, arg.Function)
-            if err != nil {
-                return nil, err
-            }
-            props = pr.(graph.PropertyMap)
-        }
+    props, err := args.properties(2)
+    if err != nil {
+        return nil, err
     }
 
     if len(name) > 0 {
@@ -1,7 +1,6 @@
 package epp
 
 import (
-    "fmt"
     "github.com/jtejido/ngac/pkg/pip/graph"
     "github.com/jtejido/ngac/pkg/pip/obligations"
     "github.com/jtejido/ngac/pkg/pip/prohibitions"
@@ -15,24 +14,21 @@ func (f *GetNodeNameExecutor) Name() string {
 func (f *GetNodeNameExecutor) NumParams() int {
     return 1
 }
+
+func (f *GetNodeNameExecutor) Signature() *Signature {
+    return &Signature{Params: []ValueType{NODE}, Returns: STRING}
+}
 func (f *GetNodeNameExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
     eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
-    args := function.Args
-    if len(args) != f.NumParams() {
-        return nil, fmt.Errorf("%s expected %d arg but got %d", f.Name(), f.NumParams(), len(args))
-    }
-
-    arg := args[0]
-    argFunction := arg.Function
-    if argFunction == nil {
-        return nil, fmt.Errorf("%s expected the first argument to be a function but it was null", f.Name())
+    args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+    if err != nil {
+        return nil, err
     }
 
-    n, err := functionEvaluator.Eval(g, p, o, eventCtx, argFunction)
+    node, err := args.node(0)
     if err != nil {
         return nil, err
     }
 
-    node := n.(*graph.Node)
     return node.Name, nil
 }
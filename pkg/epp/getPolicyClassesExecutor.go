@@ -0,0 +1,52 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/internal/set"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// GetPolicyClassesExecutor returns the names of the policy classes a node, like a user, is contained in.
+type GetPolicyClassesExecutor struct{}
+
+func (f *GetPolicyClassesExecutor) Name() string {
+	return "get_policy_classes"
+}
+
+func (f *GetPolicyClassesExecutor) NumParams() int {
+	return 1
+}
+
+func (f *GetPolicyClassesExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{NODE}, Returns: SET}
+}
+
+func (f *GetPolicyClassesExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := args.node(0)
+	if err != nil {
+		return nil, err
+	}
+
+	pcs := set.NewSet()
+	if node.Type == graph.PC {
+		pcs.Add(node.Name)
+	}
+	for name := range ancestors(g, node.Name).Iter() {
+		n, err := g.Node(name.(string))
+		if err != nil {
+			return nil, err
+		}
+		if n.Type == graph.PC {
+			pcs.Add(n.Name)
+		}
+	}
+
+	return pcs, nil
+}
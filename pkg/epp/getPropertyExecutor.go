@@ -0,0 +1,41 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// GetPropertyExecutor returns the value of a property of a node, empty when it is not set.
+type GetPropertyExecutor struct{}
+
+func (f *GetPropertyExecutor) Name() string {
+	return "get_property"
+}
+
+func (f *GetPropertyExecutor) NumParams() int {
+	return 2
+}
+
+func (f *GetPropertyExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{NODE, STRING}, Returns: STRING}
+}
+
+func (f *GetPropertyExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := args.node(0)
+	if err != nil {
+		return nil, err
+	}
+	key, err := args.string(1)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Properties[key], nil
+}
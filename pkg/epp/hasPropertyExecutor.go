@@ -0,0 +1,42 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// HasPropertyExecutor tells if a property of a node is set.
+type HasPropertyExecutor struct{}
+
+func (f *HasPropertyExecutor) Name() string {
+	return "has_property"
+}
+
+func (f *HasPropertyExecutor) NumParams() int {
+	return 2
+}
+
+func (f *HasPropertyExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{NODE, STRING}, Returns: BOOLEAN}
+}
+
+func (f *HasPropertyExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := args.node(0)
+	if err != nil {
+		return nil, err
+	}
+	key, err := args.string(1)
+	if err != nil {
+		return nil, err
+	}
+
+	_, ok := node.Properties[key]
+	return ok, nil
+}
@@ -1,7 +1,6 @@
 package epp
 
 import (
-    "fmt"
     "github.com/jtejido/ngac/internal/set"
     "github.com/jtejido/ngac/pkg/pip/graph"
     "github.com/jtejido/ngac/pkg/pip/obligations"
@@ -16,20 +15,19 @@ func (f *IsNodeContainedInExecutor) Name() string {
 func (f *IsNodeContainedInExecutor) NumParams() int {
     return 2
 }
+
+func (f *IsNodeContainedInExecutor) Signature() *Signature {
+    return &Signature{Params: []ValueType{NODE, NODE}, Returns: BOOLEAN}
+}
 func (f *IsNodeContainedInExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
     eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
-    args := function.Args
-    if len(args) != f.NumParams() {
-        return nil, fmt.Errorf("%s expected %d arg but got %d", f.Name(), f.NumParams(), len(args))
-    }
-
-    arg := args[0]
-    ff := arg.Function
-    if ff == nil {
-        return nil, fmt.Errorf("%s expects two functions as parameters", ff.Name)
+    args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+    if err != nil {
+        return nil, err
     }
 
-    cn, err := functionEvaluator.Eval(g, p, o, eventCtx, ff)
+    // a function returning no node is not contained in anything
+    cn, err := args.as(0, NODE)
     if err != nil {
         return nil, err
     }
@@ -38,13 +36,7 @@ func (f *IsNodeContainedInExecutor) Exec(g graph.Graph, p prohibitions.Prohibiti
         return false, nil
     }
 
-    arg = args[1]
-    ff = arg.Function
-    if ff == nil {
-        return nil, fmt.Errorf("%s expects two functions as parameters", f.Name())
-    }
-
-    pn, err := functionEvaluator.Eval(g, p, o, eventCtx, ff)
+    pn, err := args.as(1, NODE)
     if err != nil {
         return nil, err
     }
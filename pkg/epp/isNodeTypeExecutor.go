@@ -0,0 +1,41 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// IsNodeTypeExecutor tells if a node is of the type (PC, OA, O, UA or U).
+type IsNodeTypeExecutor struct{}
+
+func (f *IsNodeTypeExecutor) Name() string {
+	return "is_node_type"
+}
+
+func (f *IsNodeTypeExecutor) NumParams() int {
+	return 2
+}
+
+func (f *IsNodeTypeExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{NODE, STRING}, Returns: BOOLEAN}
+}
+
+func (f *IsNodeTypeExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := args.node(0)
+	if err != nil {
+		return nil, err
+	}
+	t, err := args.string(1)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Type == graph.ToNodeType(t), nil
+}
@@ -0,0 +1,86 @@
+package epp
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/pkg/context"
+	"strings"
+)
+
+// DefaultMaxCascadeDepth is the number of obligation responses an event can trigger in a row when the EPPOptions
+// don't set one.
+const DefaultMaxCascadeDepth = 10
+
+type lineageKey struct{}
+
+// Cause is the application of an obligation's rules to an event, the events emitted by its response are caused by it.
+type Cause struct {
+	Obligation string
+	Rules      []string
+	Event      string
+	Target     string
+}
+
+func (c Cause) String() string {
+	return fmt.Sprintf("%s[%s] on %s %s", c.Obligation, strings.Join(c.Rules, ", "), c.Event, c.Target)
+}
+
+// NewCause describes the application of an obligation to an event, the rules are added as they match.
+func NewCause(obligationLabel string, eventCtx EventContext) Cause {
+	var target string
+	if node := eventCtx.Target(); node != nil {
+		target = node.Name
+	}
+	return Cause{Obligation: obligationLabel, Event: eventCtx.Event(), Target: target}
+}
+
+// CascadeError is returned when an obligation response would exceed the maximum cascade depth or trigger a rule
+// again on the same target within the same chain of events.
+type CascadeError struct {
+	Reason string
+	Chain  []Cause
+}
+
+func (e *CascadeError) Error() string {
+	chain := make([]string, len(e.Chain))
+	for i, cause := range e.Chain {
+		chain[i] = cause.String()
+	}
+	return fmt.Sprintf("%s: %s", e.Reason, strings.Join(chain, " -> "))
+}
+
+// SetLineage records the chain of causes on the user context, the events emitted with it carry the chain.
+func SetLineage(userCtx context.Context, lineage []Cause) {
+	userCtx.SetValue(lineageKey{}, lineage)
+}
+
+func lineageOf(userCtx context.Context) []Cause {
+	if userCtx == nil {
+		return nil
+	}
+	lineage, _ := userCtx.Value(lineageKey{}).([]Cause)
+	return lineage
+}
+
+// CheckCascade returns a CascadeError if applying the cause after the lineage exceeds the maximum depth, or if one of
+// its rules already fired on the same target in the lineage.
+func CheckCascade(lineage []Cause, cause Cause, maxDepth int) error {
+	chain := append(append(make([]Cause, 0, len(lineage)+1), lineage...), cause)
+	if len(lineage) >= maxDepth {
+		return &CascadeError{fmt.Sprintf("maximum cascade depth of %d exceeded", maxDepth), chain}
+	}
+
+	for _, previous := range lineage {
+		if previous.Obligation != cause.Obligation || previous.Target != cause.Target {
+			continue
+		}
+		for _, rule := range previous.Rules {
+			for _, r := range cause.Rules {
+				if rule == r {
+					return &CascadeError{fmt.Sprintf("rule %s of %s fired again on %s", r, cause.Obligation, cause.Target), chain}
+				}
+			}
+		}
+	}
+
+	return nil
+}
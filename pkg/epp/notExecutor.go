@@ -0,0 +1,37 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// NotExecutor negates its argument.
+type NotExecutor struct{}
+
+func (f *NotExecutor) Name() string {
+	return "not"
+}
+
+func (f *NotExecutor) NumParams() int {
+	return 1
+}
+
+func (f *NotExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{BOOLEAN}, Returns: BOOLEAN}
+}
+
+func (f *NotExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := args.bool(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return !b, nil
+}
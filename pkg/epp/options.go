@@ -2,6 +2,12 @@ package epp
 
 type EPPOptions struct {
 	executors []FunctionExecutor
+	bus       *BusOptions
+	maxDepth  int
+	validate  bool
+	clock     Clock
+	// number of dead letters kept
+	deadLetters int
 }
 
 func NewEPPOptions(executors ...FunctionExecutor) *EPPOptions {
@@ -13,3 +19,57 @@ func NewEPPOptions(executors ...FunctionExecutor) *EPPOptions {
 func (eo *EPPOptions) Executors() []FunctionExecutor {
 	return eo.executors
 }
+
+// WithBus configures how events are delivered to obligations and subscribers. Without it events are processed
+// synchronously.
+func (eo *EPPOptions) WithBus(opts *BusOptions) *EPPOptions {
+	eo.bus = opts
+	return eo
+}
+
+func (eo *EPPOptions) BusOptions() *BusOptions {
+	return eo.bus
+}
+
+// WithMaxCascadeDepth limits the number of obligation responses an event can trigger in a row.
+func (eo *EPPOptions) WithMaxCascadeDepth(depth int) *EPPOptions {
+	eo.maxDepth = depth
+	return eo
+}
+
+func (eo *EPPOptions) MaxCascadeDepth() int {
+	if eo.maxDepth <= 0 {
+		return DefaultMaxCascadeDepth
+	}
+	return eo.maxDepth
+}
+
+// WithObligationValidation makes the PDP reject the obligations failing validation when they are added or updated.
+func (eo *EPPOptions) WithObligationValidation() *EPPOptions {
+	eo.validate = true
+	return eo
+}
+
+func (eo *EPPOptions) ObligationValidation() bool {
+	return eo.validate
+}
+
+// WithClock sets the clock of the scheduler firing the scheduled rules, the system clock is used otherwise.
+func (eo *EPPOptions) WithClock(clock Clock) *EPPOptions {
+	eo.clock = clock
+	return eo
+}
+
+func (eo *EPPOptions) Clock() Clock {
+	return eo.clock
+}
+
+// WithDeadLetterLimit sets how many dead letters the EPP keeps, the oldest are dropped past it. It keeps 1000 otherwise.
+func (eo *EPPOptions) WithDeadLetterLimit(limit int) *EPPOptions {
+	eo.deadLetters = limit
+	return eo
+}
+
+func (eo *EPPOptions) DeadLetterLimit() int {
+	return eo.deadLetters
+}
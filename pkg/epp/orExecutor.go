@@ -0,0 +1,44 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// OrExecutor is true when any of its arguments is.
+type OrExecutor struct{}
+
+func (f *OrExecutor) Name() string {
+	return "or"
+}
+
+func (f *OrExecutor) NumParams() int {
+	return 1
+}
+
+// any number of booleans
+func (f *OrExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{BOOLEAN}, Variadic: true, Returns: BOOLEAN}
+}
+
+func (f *OrExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// the arguments are evaluated until one is true
+	for i := 0; i < args.len(); i++ {
+		b, err := args.bool(i)
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
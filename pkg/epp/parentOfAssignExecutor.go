@@ -17,6 +17,10 @@ func (f *ParentOfAssignExecutor) Name() string {
 func (f *ParentOfAssignExecutor) NumParams() int {
     return 0
 }
+
+func (f *ParentOfAssignExecutor) Signature() *Signature {
+    return &Signature{Returns: NODE}
+}
 func (f *ParentOfAssignExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
     eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
     var parent *graph.Node
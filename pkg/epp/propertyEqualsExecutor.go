@@ -0,0 +1,46 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+// PropertyEqualsExecutor tells if a property of a node is set to the value.
+type PropertyEqualsExecutor struct{}
+
+func (f *PropertyEqualsExecutor) Name() string {
+	return "property_equals"
+}
+
+func (f *PropertyEqualsExecutor) NumParams() int {
+	return 3
+}
+
+func (f *PropertyEqualsExecutor) Signature() *Signature {
+	return &Signature{Params: []ValueType{NODE, STRING, STRING}, Returns: BOOLEAN}
+}
+
+func (f *PropertyEqualsExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	args, err := newArgs(g, p, o, eventCtx, function, functionEvaluator, f)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := args.node(0)
+	if err != nil {
+		return nil, err
+	}
+	key, err := args.string(1)
+	if err != nil {
+		return nil, err
+	}
+	value, err := args.string(2)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := node.Properties[key]
+	return ok && v == value, nil
+}
@@ -0,0 +1,276 @@
+package epp
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/internal/set"
+	"github.com/jtejido/ngac/pkg/context"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Triggers tells if the rule of the obligation responds to the event. A scheduled rule only responds to its own
+// timers, the other rules to the events matching their pattern.
+func Triggers(obligationLabel string, rule *obligations.Rule, eventCtx EventContext, g graph.Graph) bool {
+	if rule.EventPattern != nil && rule.EventPattern.Schedule != nil {
+		te, ok := eventCtx.(*TimeEvent)
+		return ok && te.Fires(obligationLabel, rule.Label)
+	}
+
+	return eventCtx.MatchesPattern(rule.EventPattern, g)
+}
+
+// StartsTimer tells if the event starts a timer of the rule, that is an event matching the pattern of a rule scheduled
+// some time after it.
+func StartsTimer(rule *obligations.Rule, eventCtx EventContext, g graph.Graph) bool {
+	if rule.EventPattern == nil || rule.EventPattern.Schedule == nil || rule.EventPattern.Schedule.After == "" {
+		return false
+	}
+	if _, ok := eventCtx.(*TimeEvent); ok {
+		return false
+	}
+
+	return eventCtx.MatchesPattern(rule.EventPattern, g)
+}
+
+// Scheduler keeps the timers of the scheduled rules and fires the due ones when it ticks. The timers are kept in the
+// obligations store when it implements obligations.TimerStore, so they survive a restart.
+type Scheduler struct {
+	store  obligations.Obligations
+	timers obligations.TimerStore
+	clock  Clock
+	fire   func(*obligations.Timer) error
+	seq    uint64
+
+	sync.Mutex // held while ticking
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewScheduler returns a scheduler for the rules of the obligations in the store, fire is called with each due timer.
+// The system clock is used when clock is nil.
+func NewScheduler(store obligations.Obligations, clock Clock, fire func(*obligations.Timer) error) *Scheduler {
+	timers, ok := store.(obligations.TimerStore)
+	if !ok {
+		timers = obligations.NewTimerStore()
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	return &Scheduler{store: store, timers: timers, clock: clock, fire: fire}
+}
+
+// Clock returns the clock telling the scheduler the time.
+func (s *Scheduler) Clock() Clock {
+	return s.clock
+}
+
+// Pending returns the pending timers, the earliest due first.
+func (s *Scheduler) Pending() []*obligations.Timer {
+	return s.timers.PendingTimers()
+}
+
+// Schedule starts a timer of the rule scheduled some time after the event.
+func (s *Scheduler) Schedule(obligationLabel string, rule *obligations.Rule, eventCtx EventContext) error {
+	timer, err := s.Timer(obligationLabel, rule, eventCtx)
+	if err != nil {
+		return err
+	}
+
+	s.AddTimer(timer)
+	return nil
+}
+
+// Timer returns the timer of the rule scheduled some time after the event, without starting it. It fails when the
+// schedule isn't a positive duration.
+func (s *Scheduler) Timer(obligationLabel string, rule *obligations.Rule, eventCtx EventContext) (*obligations.Timer, error) {
+	after, err := time.ParseDuration(rule.EventPattern.Schedule.After)
+	if err == nil && after <= 0 {
+		err = fmt.Errorf("%q is not a positive duration", rule.EventPattern.Schedule.After)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rule %s of obligation %s: %w", rule.Label, obligationLabel, err)
+	}
+
+	now := s.clock.Now()
+	timer := &obligations.Timer{
+		// the time keeps the IDs unique across restarts, the sequence within the same instant
+		ID:         fmt.Sprintf("after:%s/%s/%d.%d", obligationLabel, rule.Label, now.UnixNano(), atomic.AddUint64(&s.seq, 1)),
+		Obligation: obligationLabel,
+		Rule:       rule.Label,
+		Due:        now.Add(after),
+		Event:      eventCtx.Event(),
+	}
+	if target := eventCtx.Target(); target != nil {
+		timer.Target = target.Name
+	}
+	if userCtx := eventCtx.UserCtx(); userCtx != nil {
+		timer.User = userCtx.User()
+		timer.Process = userCtx.Process()
+	}
+
+	return timer, nil
+}
+
+// AddTimer keeps the timer until it is due.
+func (s *Scheduler) AddTimer(timer *obligations.Timer) {
+	s.timers.AddTimer(timer)
+}
+
+// Tick fires the timers due at the current time, the earliest first. The timer of each cron schedule is started or
+// moved to its next time, a cron schedule missed while nothing was ticking fires once. The timers of the rules that
+// were removed or disabled are dropped. Every due timer is fired, the first error is returned.
+func (s *Scheduler) Tick() error {
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.clock.Now()
+	firstErr := s.sync(now)
+
+	for _, timer := range s.timers.PendingTimers() {
+		if timer.Due.After(now) {
+			break
+		}
+
+		// the timer is moved or dropped before firing, so a failing response isn't repeated on every tick
+		if timer.Cron != "" {
+			s.startCron(timer.Obligation, timer.Rule, timer.Cron, now)
+		} else {
+			s.timers.RemoveTimer(timer.ID)
+		}
+
+		if err := s.fire(timer); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// sync starts the timers of the new cron schedules and drops the timers of the rules no longer scheduled.
+func (s *Scheduler) sync(now time.Time) error {
+	scheduled := make(map[string]*obligations.Schedule)
+	crons := make([]*obligations.Timer, 0)
+	for _, obligation := range s.store.All() {
+		if !obligation.Enabled {
+			continue
+		}
+		for _, rule := range obligation.EnabledRules() {
+			if rule.EventPattern == nil || rule.EventPattern.Schedule == nil {
+				continue
+			}
+			scheduled[obligation.Label+"/"+rule.Label] = rule.EventPattern.Schedule
+			if rule.EventPattern.Schedule.Cron != "" {
+				crons = append(crons, &obligations.Timer{Obligation: obligation.Label, Rule: rule.Label, Cron: rule.EventPattern.Schedule.Cron})
+			}
+		}
+	}
+
+	started := make(map[string]bool)
+	for _, timer := range s.timers.PendingTimers() {
+		key := timer.Obligation + "/" + timer.Rule
+		schedule, ok := scheduled[key]
+		if !ok || timer.Cron != schedule.Cron {
+			s.timers.RemoveTimer(timer.ID)
+			continue
+		}
+		started[key] = true
+	}
+
+	var firstErr error
+	for _, cron := range crons {
+		if started[cron.Obligation+"/"+cron.Rule] {
+			continue
+		}
+		if err := s.startCron(cron.Obligation, cron.Rule, cron.Cron, now); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// startCron sets the timer of the cron schedule to its next time after now.
+func (s *Scheduler) startCron(obligationLabel, ruleLabel, expr string, now time.Time) error {
+	cron, err := ParseCron(expr)
+	if err != nil {
+		return fmt.Errorf("rule %s of obligation %s: %w", ruleLabel, obligationLabel, err)
+	}
+
+	id := fmt.Sprintf("cron:%s/%s", obligationLabel, ruleLabel)
+	next := cron.Next(now)
+	if next.IsZero() {
+		s.timers.RemoveTimer(id)
+		return fmt.Errorf("rule %s of obligation %s: cron expression %q never fires", ruleLabel, obligationLabel, expr)
+	}
+
+	s.timers.AddTimer(&obligations.Timer{ID: id, Obligation: obligationLabel, Rule: ruleLabel, Due: next, Cron: expr})
+	return nil
+}
+
+// Start ticks at the given interval until Stop is called, the errors are logged.
+func (s *Scheduler) Start(interval time.Duration) {
+	s.Lock()
+	if s.stop != nil {
+		s.Unlock()
+		return
+	}
+	s.stop, s.done = make(chan struct{}), make(chan struct{})
+	stop, done := s.stop, s.done
+	s.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.Tick(); err != nil {
+					log.Println(err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the ticking started by Start and waits for the current tick to finish.
+func (s *Scheduler) Stop() {
+	s.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// NewTimerEvent builds the event of a due timer of the obligation. The target is looked up in the graph, a target
+// removed since the timer started is kept by name and is in no container.
+func NewTimerEvent(g graph.Graph, obligation *obligations.Obligation, timer *obligations.Timer) *TimeEvent {
+	user, process := timer.User, timer.Process
+	if timer.Cron != "" || user == "" {
+		user, process = obligation.User, ""
+	}
+	userCtx, _ := context.NewUserContextWithProcess(user, process)
+
+	if timer.Target == "" {
+		return NewTimeEvent(userCtx, nil, timer)
+	}
+
+	if node, err := g.Node(timer.Target); err == nil {
+		return NewTimeEvent(userCtx, node, timer)
+	}
+
+	event := NewTimeEvent(userCtx, &graph.Node{Name: timer.Target}, timer)
+	event.parents = set.NewSet()
+	return event
+}
@@ -0,0 +1,136 @@
+package epp
+
+import (
+	"errors"
+	"github.com/jtejido/ngac/pkg/context"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	gm "github.com/jtejido/ngac/pkg/pip/graph/memory"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	obm "github.com/jtejido/ngac/pkg/pip/obligations/memory"
+	"testing"
+	"time"
+)
+
+const scheduledObligation = `{
+  "label": "scheduled",
+  "rules": [
+    {"label": "after", "event": {"operations": ["assign"], "schedule": {"after": "1h"}}, "response": {"actions": []}},
+    {"label": "cron", "event": {"schedule": {"cron": "0 * * * *"}}, "response": {"actions": []}}
+  ]
+}`
+
+func TestSchedulerFiresDueTimers(t *testing.T) {
+	store := obm.New()
+	obligation, err := obligations.ParseBytes("super", []byte(scheduledObligation))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	store.Add(obligation, true)
+
+	clock := NewManualClock(time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC))
+	fired := make([]string, 0)
+	fire := func(timer *obligations.Timer) error {
+		fired = append(fired, timer.Rule)
+		return errors.New("failed")
+	}
+	scheduler := NewScheduler(store, clock, fire)
+
+	userCtx, _ := context.NewUserContextWithProcess("u1", "42")
+	event := NewAssignEvent(userCtx, &graph.Node{Name: "o1"}, &graph.Node{Name: "oa1"})
+	if err := scheduler.Schedule("scheduled", obligation.Rules[0], event); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := scheduler.Tick(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(fired) != 0 || len(scheduler.Pending()) != 2 {
+		t.Fatalf("expected 2 pending timers and none fired, got %v fired and %v pending", fired, scheduler.Pending())
+	}
+
+	// the timers are kept by the store, a new scheduler picks them up
+	scheduler = NewScheduler(store, clock, fire)
+	clock.Advance(time.Hour)
+	if err := scheduler.Tick(); err == nil || err.Error() != "failed" {
+		t.Errorf("expected the first error, got %v", err)
+	}
+	if len(fired) != 2 || fired[0] != "cron" || fired[1] != "after" {
+		t.Errorf("expected cron then after to fire, got %v", fired)
+	}
+
+	pending := scheduler.Pending()
+	if len(pending) != 1 || pending[0].Rule != "cron" || !pending[0].Due.Equal(time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected only the cron timer at 11:00, got %v", pending)
+	}
+
+	// removing the obligation drops its timers
+	store.Remove("scheduled")
+	if err := scheduler.Tick(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if pending := scheduler.Pending(); len(pending) != 0 {
+		t.Errorf("expected no pending timer, got %v", pending)
+	}
+}
+
+func TestTimeEventTriggersItsRule(t *testing.T) {
+	obligation, err := obligations.ParseBytes("super", []byte(scheduledObligation))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	after, cron := obligation.Rules[0], obligation.Rules[1]
+
+	userCtx, _ := context.NewUserContext("u1")
+	assign := NewAssignEvent(userCtx, &graph.Node{Name: "o1"}, &graph.Node{Name: "oa1"})
+	if Triggers("scheduled", after, assign, nil) || !StartsTimer(after, assign, nil) {
+		t.Errorf("expected the event to start a timer of the rule rather than trigger it")
+	}
+
+	timer := &obligations.Timer{ID: "t", Obligation: "scheduled", Rule: "after", Target: "o1", User: "u1"}
+	event := NewTimeEvent(userCtx, nil, timer)
+	if !Triggers("scheduled", after, event, nil) || StartsTimer(after, event, nil) {
+		t.Errorf("expected the time event to trigger the rule")
+	}
+	if Triggers("scheduled", cron, event, nil) || Triggers("other", after, event, nil) {
+		t.Errorf("expected the time event to trigger its rule only")
+	}
+}
+
+func TestTimerOfRemovedTarget(t *testing.T) {
+	g := gm.New()
+	g.CreatePolicyClass("pc1", nil)
+	g.CreateNode("contractors", graph.UA, nil, "pc1")
+	g.CreateNode("u1", graph.U, nil, "contractors")
+
+	store := obm.New()
+	obligation, err := obligations.ParseBytes("super", []byte(scheduledObligation))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	store.Add(obligation, true)
+
+	pattern := &obligations.EventPattern{Target: &obligations.Target{Containers: []*obligations.EvrNode{{Name: "contractors", Type: "UA"}}}}
+	var matched bool
+	fire := func(timer *obligations.Timer) error {
+		event := NewTimerEvent(g, store.Get(timer.Obligation), timer)
+		matched = event.MatchesPattern(pattern, g)
+		return nil
+	}
+
+	clock := NewManualClock(time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC))
+	scheduler := NewScheduler(store, clock, fire)
+	userCtx, _ := context.NewUserContext("u1")
+	event := NewAssignEvent(userCtx, &graph.Node{Name: "u1", Type: graph.U}, &graph.Node{Name: "contractors", Type: graph.UA})
+	if err := scheduler.Schedule("scheduled", obligation.Rules[0], event); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// the target is removed before the timer is due, it is then in no container
+	g.RemoveNode("u1")
+	clock.Advance(time.Hour)
+	if err := scheduler.Tick(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if matched {
+		t.Errorf("expected the removed target to be in no container")
+	}
+}
@@ -0,0 +1,247 @@
+package epp
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/internal/set"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"strconv"
+)
+
+// ValueType is the type of a function parameter or of what a function returns.
+type ValueType int
+
+const (
+	ANY ValueType = iota
+	STRING
+	BOOLEAN
+	NODE
+	SET
+	PROPERTIES
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case STRING:
+		return "string"
+	case BOOLEAN:
+		return "boolean"
+	case NODE:
+		return "node"
+	case SET:
+		return "set"
+	case PROPERTIES:
+		return "properties"
+	}
+
+	return "any"
+}
+
+// Signature declares the parameter and return types of a function. The last Optional parameters can be omitted and
+// the last parameter of a Variadic function can be repeated.
+type Signature struct {
+	Params   []ValueType
+	Optional int
+	Variadic bool
+	Returns  ValueType
+}
+
+// ParamRange returns how many arguments the function accepts, a negative max meaning there's no upper bound.
+func (s *Signature) ParamRange() (min, max int) {
+	min, max = len(s.Params)-s.Optional, len(s.Params)
+	if s.Variadic {
+		max = -1
+	}
+
+	return
+}
+
+// Param returns the type of the i-th parameter.
+func (s *Signature) Param(i int) ValueType {
+	if len(s.Params) == 0 {
+		return ANY
+	}
+	if i >= len(s.Params) {
+		return s.Params[len(s.Params)-1]
+	}
+
+	return s.Params[i]
+}
+
+func (s *Signature) String() string {
+	params := ""
+	for i, t := range s.Params {
+		if i > 0 {
+			params += ", "
+		}
+		params += t.String()
+		if i >= len(s.Params)-s.Optional {
+			params += "?"
+		}
+	}
+	if s.Variadic {
+		params += "..."
+	}
+
+	return fmt.Sprintf("(%s) %s", params, s.Returns)
+}
+
+// signature returns the signature the executor declares, or one taking arguments of any type, NumParams of them unless
+// the executor declares a range.
+func signature(executor FunctionExecutor) *Signature {
+	if typed, ok := executor.(TypedFunctionExecutor); ok {
+		return typed.Signature()
+	}
+
+	min, max := executor.NumParams(), executor.NumParams()
+	if r, ok := executor.(ParamRangeFunctionExecutor); ok {
+		min, max = r.ParamRange()
+	}
+
+	sig := &Signature{Returns: ANY}
+	if max < 0 {
+		// the last parameter is repeated
+		max, sig.Variadic = min, true
+		if max == 0 {
+			max = 1
+		}
+	}
+
+	sig.Params = make([]ValueType, max)
+	for i := range sig.Params {
+		sig.Params[i] = ANY
+	}
+	sig.Optional = max - min
+
+	return sig
+}
+
+func arity(min, max int) string {
+	switch {
+	case min == max:
+		return fmt.Sprintf("%d arguments", min)
+	case max < 0:
+		return fmt.Sprintf("at least %d arguments", min)
+	}
+
+	return fmt.Sprintf("%d to %d arguments", min, max)
+}
+
+// assignable tells if what a function returns can be passed where the other type is expected. Strings and nodes are
+// interchangeable, a node being given by its name, and a boolean can be written as a string.
+func assignable(from, to ValueType) bool {
+	if from == to || from == ANY || to == ANY {
+		return true
+	}
+
+	switch to {
+	case STRING:
+		return from == NODE || from == BOOLEAN
+	case NODE:
+		return from == STRING
+	}
+
+	return false
+}
+
+// literal tells if a literal argument can be coerced to the type.
+func literal(value string, t ValueType) bool {
+	switch t {
+	case BOOLEAN:
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	case SET, PROPERTIES:
+		return false
+	}
+
+	return true
+}
+
+// coerce converts the value to the type, looking up a node given by name. A nil node is kept, meaning there is none.
+func coerce(g graph.Graph, v interface{}, t ValueType) (interface{}, error) {
+	switch t {
+	case STRING:
+		switch s := v.(type) {
+		case string:
+			return s, nil
+		case *graph.Node:
+			if s != nil {
+				return s.Name, nil
+			}
+		case bool:
+			return strconv.FormatBool(s), nil
+		}
+	case BOOLEAN:
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			if parsed, err := strconv.ParseBool(b); err == nil {
+				return parsed, nil
+			}
+		}
+	case NODE:
+		switch n := v.(type) {
+		case *graph.Node:
+			return n, nil
+		case string:
+			return g.Node(n)
+		}
+	case SET:
+		switch s := v.(type) {
+		case set.Set:
+			return s, nil
+		case []string:
+			elements := set.NewSet()
+			for _, e := range s {
+				elements.Add(e)
+			}
+			return elements, nil
+		}
+	case PROPERTIES:
+		switch m := v.(type) {
+		case graph.PropertyMap:
+			return m, nil
+		case map[string]string:
+			return graph.PropertyMap(m), nil
+		}
+	default:
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("expected a %s but got %v", t, v)
+}
+
+// check reports what is wrong with a function call without evaluating it: an unknown function, the wrong number of
+// arguments, or arguments and results that can't be coerced to the types expected of them. Nested functions are
+// checked when nested is set.
+func (fe *FunctionEvaluator) check(pointer string, function *obligations.Function, expected ValueType, nested bool,
+	report func(pointer, format string, a ...interface{})) {
+	executor, ok := fe.Lookup(function.Name)
+	if !ok {
+		report(pointer+"/name", "%s is not a recognized function", function.Name)
+		return
+	}
+
+	sig := signature(executor)
+	if !assignable(sig.Returns, expected) {
+		report(pointer+"/name", "%s returns a %s but a %s is expected", function.Name, sig.Returns, expected)
+	}
+	if min, max := sig.ParamRange(); len(function.Args) < min || (max >= 0 && len(function.Args) > max) {
+		report(pointer+"/args", "%s expects %s but got %d", function.Name, arity(min, max), len(function.Args))
+	}
+
+	for i, arg := range function.Args {
+		p, t := fmt.Sprintf("%s/args/%d", pointer, i), sig.Param(i)
+		if arg.Function == nil {
+			if !literal(arg.Value, t) {
+				report(p, "%s expects argument %d to be a %s but got %q", function.Name, i+1, t, arg.Value)
+			}
+		} else if nested {
+			fe.check(p+"/function", arg.Function, t, nested, report)
+		} else if executor, ok := fe.Lookup(arg.Function.Name); ok && !assignable(signature(executor).Returns, t) {
+			report(p+"/function/name", "%s expects argument %d to be a %s but %s returns a %s", function.Name, i+1, t,
+				arg.Function.Name, signature(executor).Returns)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package epp
+
+import (
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"testing"
+)
+
+type rangeExecutor struct {
+	min, max int
+}
+
+func (f *rangeExecutor) Name() string {
+	return "range"
+}
+
+func (f *rangeExecutor) NumParams() int {
+	return f.max
+}
+
+func (f *rangeExecutor) ParamRange() (min, max int) {
+	return f.min, f.max
+}
+
+func (f *rangeExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+	eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
+	return len(function.Args), nil
+}
+
+func TestParamRangeSignature(t *testing.T) {
+	for _, r := range [][2]int{{2, 2}, {1, 3}, {0, 2}, {2, -1}, {0, -1}} {
+		min, max := signature(&rangeExecutor{r[0], r[1]}).ParamRange()
+		if min != r[0] || max != r[1] {
+			t.Errorf("expected the range %v, got (%d, %d)", r, min, max)
+		}
+	}
+}
+
+func TestParamRangeIsChecked(t *testing.T) {
+	fe := NewFunctionEvaluator()
+	fe.Add(&rangeExecutor{1, 2})
+
+	for n, ok := range map[int]bool{0: false, 1: true, 2: true, 3: false} {
+		function := &obligations.Function{Name: "range"}
+		for i := 0; i < n; i++ {
+			function.Args = append(function.Args, obligations.NewArg("a"))
+		}
+
+		if err := fe.Check(function, ANY); (err == nil) != ok {
+			t.Errorf("unexpected result checking %d arguments: %v", n, err)
+		}
+	}
+}
@@ -15,6 +15,12 @@ func (f *ToPropertiesExecutor) Name() string {
 func (f *ToPropertiesExecutor) NumParams() int {
     return 0
 }
+
+// any number of "key=value" pairs
+func (f *ToPropertiesExecutor) Signature() *Signature {
+    return &Signature{Params: []ValueType{STRING}, Optional: 1, Variadic: true, Returns: PROPERTIES}
+}
+
 func (f *ToPropertiesExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
     eventCtx EventContext, function *obligations.Function, functionEvaluator *FunctionEvaluator) (interface{}, error) {
     props := graph.NewPropertyMap()
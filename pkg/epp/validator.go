@@ -0,0 +1,389 @@
+package epp
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"time"
+)
+
+// Validator checks an obligation before it is added, catching what would otherwise fail when an event is processed.
+type Validator struct {
+	functionEvaluator *FunctionEvaluator
+}
+
+func NewValidator(functionEvaluator *FunctionEvaluator) *Validator {
+	return &Validator{functionEvaluator}
+}
+
+// Validate checks that the rule labels are unique, that the functions are registered and get the arguments their
+// signatures declare, that the node types are valid, that the nodes named by the obligation exist in the graph and
+// that the schedules and error policies are valid. The problems are returned as an *obligations.ValidationError,
+// pointing at the offending value.
+func (v *Validator) Validate(g graph.Graph, obligation *obligations.Obligation) error {
+	vd := &validation{g: g, functionEvaluator: v.functionEvaluator}
+	if len(obligation.Label) == 0 {
+		vd.add("/label", "no label specified for obligation")
+	}
+	vd.rules("/rules", obligation.Rules, true)
+	vd.errorPolicy("/onError", obligation.OnError)
+
+	if len(vd.violations) == 0 {
+		return nil
+	}
+
+	return &obligations.ValidationError{Violations: vd.violations}
+}
+
+// MissingNode is a node named by an obligation that doesn't exist in the graph.
+type MissingNode struct {
+	// where the obligation names the node
+	Pointer string
+	Name    string
+}
+
+// MissingNodes returns the nodes named by the obligation that don't exist in the graph, ignoring the other problems.
+// The nodes created by a previous action of the same response aren't missing.
+func MissingNodes(g graph.Graph, obligation *obligations.Obligation) []MissingNode {
+	vd := &validation{g: g, missingOnly: true}
+	vd.rules("/rules", obligation.Rules, true)
+
+	return vd.missing
+}
+
+type validation struct {
+	g                 graph.Graph
+	functionEvaluator *FunctionEvaluator
+	violations        []obligations.Violation
+	// only the missing nodes are collected, the functions aren't checked
+	missingOnly bool
+	missing     []MissingNode
+}
+
+func (vd *validation) add(pointer, format string, a ...interface{}) {
+	if vd.missingOnly {
+		return
+	}
+	vd.violations = append(vd.violations, obligations.Violation{Pointer: pointer, Description: fmt.Sprintf(format, a...)})
+}
+
+// rules checks a list of rules. The nodes of the rules created by a response don't have to exist yet.
+func (vd *validation) rules(pointer string, rules []*obligations.Rule, checkNodes bool) {
+	labels := make(map[string]bool)
+	for i, rule := range rules {
+		p := fmt.Sprintf("%s/%d", pointer, i)
+		if len(rule.Label) == 0 {
+			vd.add(p+"/label", "no label provided for rule")
+		} else if labels[rule.Label] {
+			vd.add(p+"/label", "duplicate rule label %s", rule.Label)
+		}
+		labels[rule.Label] = true
+
+		if rule.EventPattern == nil {
+			vd.add(p, "no event provided for rule")
+		} else {
+			vd.event(p+"/event", rule.EventPattern, checkNodes)
+		}
+
+		if rule.ResponsePattern == nil {
+			vd.add(p, "no response provided for rule")
+		} else {
+			vd.response(p+"/response", rule.ResponsePattern, checkNodes)
+		}
+
+		vd.errorPolicy(p+"/onError", rule.OnError)
+	}
+}
+
+func (vd *validation) errorPolicy(pointer string, policy *obligations.ErrorPolicy) {
+	if policy == nil {
+		return
+	}
+
+	switch policy.Policy {
+	case obligations.ABORT, obligations.SKIP, obligations.RETRY:
+	default:
+		vd.add(pointer+"/policy", "%q is not an error policy, expected abort, skip or retry", policy.Policy)
+	}
+	if policy.Retries < 0 {
+		vd.add(pointer+"/retries", "the number of retries can't be negative")
+	}
+	if len(policy.Delay) > 0 {
+		if d, err := time.ParseDuration(policy.Delay); err != nil || d < 0 {
+			vd.add(pointer+"/delay", "%q is not a duration", policy.Delay)
+		}
+	}
+}
+
+func (vd *validation) event(pointer string, event *obligations.EventPattern, checkNodes bool) {
+	if subject := event.Subject; subject != nil && checkNodes {
+		if len(subject.User) > 0 {
+			vd.node(pointer+"/subject/user", subject.User, graph.U)
+		}
+		for i, user := range subject.AnyUser {
+			vd.node(fmt.Sprintf("%s/subject/anyUser/%d", pointer, i), user, graph.NOOP)
+		}
+	}
+	if subject := event.Subject; subject != nil && subject.Process != nil && subject.Process.Function != nil {
+		vd.function(pointer+"/subject/process/function", subject.Process.Function, STRING)
+	}
+
+	if pc := event.PolicyClass; pc != nil && checkNodes {
+		for i, name := range pc.AnyOf {
+			vd.node(fmt.Sprintf("%s/policyClass/anyOf/%d", pointer, i), name, graph.PC)
+		}
+		for i, name := range pc.EachOf {
+			vd.node(fmt.Sprintf("%s/policyClass/eachOf/%d", pointer, i), name, graph.PC)
+		}
+	}
+
+	if target := event.Target; target != nil {
+		for i, node := range target.PolicyElements {
+			vd.evrNode(fmt.Sprintf("%s/target/policyElements/%d", pointer, i), node, checkNodes, nil)
+		}
+		for i, node := range target.Containers {
+			vd.evrNode(fmt.Sprintf("%s/target/containers/%d", pointer, i), node, checkNodes, nil)
+		}
+	}
+
+	if schedule := event.Schedule; schedule != nil {
+		vd.schedule(pointer+"/schedule", event)
+	}
+}
+
+// schedule checks that the schedule has a valid cron expression or duration, a cron schedule fires on its own so it
+// can't be combined with the rest of the pattern.
+func (vd *validation) schedule(pointer string, event *obligations.EventPattern) {
+	schedule := event.Schedule
+	switch {
+	case len(schedule.Cron) > 0 && len(schedule.After) > 0:
+		vd.add(pointer, "a schedule has either a cron expression or a duration")
+	case len(schedule.Cron) > 0:
+		if _, err := ParseCron(schedule.Cron); err != nil {
+			vd.add(pointer+"/cron", "%s", err)
+		}
+		if event.Subject != nil || event.PolicyClass != nil || len(event.Operations) > 0 || event.Target != nil {
+			vd.add(pointer+"/cron", "a cron schedule can't be combined with a subject, policy class, operations or target")
+		}
+	case len(schedule.After) > 0:
+		if d, err := time.ParseDuration(schedule.After); err != nil || d <= 0 {
+			vd.add(pointer+"/after", "%q is not a positive duration", schedule.After)
+		}
+	default:
+		vd.add(pointer, "a schedule needs a cron expression or a duration")
+	}
+}
+
+func (vd *validation) response(pointer string, response *obligations.ResponsePattern, checkNodes bool) {
+	if response.Condition != nil {
+		vd.functions(pointer+"/condition", response.Condition.Condition)
+	}
+	if response.NegatedCondition != nil {
+		vd.functions(pointer+"/condition!", response.NegatedCondition.Condition)
+	}
+
+	// nodes created by an action can be used by the following ones
+	created := make(map[string]graph.NodeType)
+	for i, action := range response.Actions {
+		p := fmt.Sprintf("%s/actions/%d", pointer, i)
+		if action.Condition() != nil {
+			vd.functions(p+"/condition", action.Condition().Condition)
+		}
+		if action.NegatedCondition() != nil {
+			vd.functions(p+"/condition!", action.NegatedCondition().Condition)
+		}
+		vd.action(p, action, checkNodes, created)
+	}
+}
+
+func (vd *validation) action(pointer string, action obligations.Action, checkNodes bool, created map[string]graph.NodeType) {
+	switch a := action.(type) {
+	case *obligations.FunctionAction:
+		vd.function(pointer+"/function", a.Function, ANY)
+	case *obligations.AssignAction:
+		vd.assignments(pointer+"/assign", a.Assignments, checkNodes, created)
+	case *obligations.CreateAction:
+		p := pointer + "/create"
+		vd.rules(p, a.Rules, false)
+		for i, cn := range a.CreateNodesList {
+			np := fmt.Sprintf("%s/%d", p, i+len(a.Rules))
+			vd.createNode(np, cn, checkNodes, created)
+		}
+	case *obligations.DeleteAction:
+		p := pointer + "/delete"
+		for i, node := range a.Nodes {
+			vd.evrNode(fmt.Sprintf("%s/nodes/%d", p, i), node, checkNodes, created)
+		}
+		if a.Assignments != nil {
+			vd.assignments(p+"/assignments", a.Assignments.Assignments, checkNodes, created)
+		}
+		for i, grant := range a.Associations {
+			vd.grant(fmt.Sprintf("%s/associations/%d", p, i), grant, checkNodes, created)
+		}
+	case *obligations.DenyAction:
+		p := pointer + "/deny"
+		if a.Subject != nil {
+			vd.evrNode(p+"/subject", a.Subject, checkNodes, created)
+		}
+		if a.Target != nil {
+			for i, container := range a.Target.Containers {
+				cp := fmt.Sprintf("%s/target/containers/%d", p, i)
+				if container.Function != nil {
+					vd.function(cp+"/function", container.Function, ANY)
+					continue
+				}
+				vd.evrNode(cp, obligations.NewEvrNode(container.Name, container.Type, container.Properties), checkNodes, created)
+			}
+		}
+	case *obligations.GrantAction:
+		vd.grant(pointer+"/grant", a, checkNodes, created)
+	}
+}
+
+func (vd *validation) assignments(pointer string, assignments []*obligations.ActionAssignment, checkNodes bool, created map[string]graph.NodeType) {
+	for i, assignment := range assignments {
+		p := fmt.Sprintf("%s/%d", pointer, i)
+		if assignment.What != nil {
+			vd.evrNode(p+"/what", assignment.What, checkNodes, created)
+		}
+		if assignment.Where != nil {
+			vd.evrNode(p+"/where", assignment.Where, checkNodes, created)
+		}
+	}
+}
+
+func (vd *validation) grant(pointer string, grant *obligations.GrantAction, checkNodes bool, created map[string]graph.NodeType) {
+	if grant.Subject != nil {
+		vd.evrNode(pointer+"/subject", grant.Subject, checkNodes, created)
+	}
+	if grant.Target != nil {
+		vd.evrNode(pointer+"/target", grant.Target, checkNodes, created)
+	}
+}
+
+// createNode checks the node to create, its type is required and it must be assignable to where it is created.
+func (vd *validation) createNode(pointer string, cn *obligations.ActionCreateNode, checkNodes bool, created map[string]graph.NodeType) {
+	if cn.Where != nil {
+		vd.evrNode(pointer+"/where", cn.Where, checkNodes, created)
+	}
+	what := cn.What
+	if what == nil {
+		return
+	}
+	if what.Function != nil {
+		vd.function(pointer+"/what/function", what.Function, NODE)
+		return
+	}
+
+	t, ok := vd.nodeType(pointer+"/what/type", what.Type)
+	if !ok {
+		return
+	}
+	if len(what.Type) == 0 {
+		vd.add(pointer+"/what/type", "no type provided for %s", what.Name)
+		return
+	}
+	if cn.Where != nil && cn.Where.Function == nil {
+		if parentType := vd.typeOf(cn.Where, created); parentType != graph.NOOP {
+			if err := graph.CheckAssignment(t, parentType); err != nil {
+				vd.add(pointer+"/what/type", "%s", err)
+			}
+		}
+	}
+	if len(what.Name) > 0 {
+		if checkNodes && vd.g.Exists(what.Name) {
+			vd.add(pointer+"/what/name", "node %s already exists", what.Name)
+		}
+		created[what.Name] = t
+	}
+}
+
+// evrNode checks a node referenced by the obligation, a function returning it or a process.
+func (vd *validation) evrNode(pointer string, node *obligations.EvrNode, checkNodes bool, created map[string]graph.NodeType) {
+	if node.Function != nil {
+		vd.function(pointer+"/function", node.Function, NODE)
+		return
+	}
+	if node.Process != nil {
+		if node.Process.Function != nil {
+			vd.function(pointer+"/process/function", node.Process.Function, STRING)
+		}
+		return
+	}
+
+	t, ok := vd.nodeType(pointer+"/type", node.Type)
+	if !ok || !checkNodes || len(node.Name) == 0 {
+		return
+	}
+	if _, ok := created[node.Name]; ok {
+		return
+	}
+	vd.node(pointer, node.Name, t)
+}
+
+// nodeType parses a node type, an empty type is valid and matches any node.
+func (vd *validation) nodeType(pointer, s string) (graph.NodeType, bool) {
+	if len(s) == 0 {
+		return graph.NOOP, true
+	}
+
+	t := graph.ToNodeType(s)
+	if t == graph.NOOP {
+		vd.add(pointer, "invalid node type %s", s)
+		return t, false
+	}
+
+	return t, true
+}
+
+// typeOf returns the type of a node given by name, from the obligation or the graph.
+func (vd *validation) typeOf(node *obligations.EvrNode, created map[string]graph.NodeType) graph.NodeType {
+	if len(node.Type) > 0 {
+		return graph.ToNodeType(node.Type)
+	}
+	if t, ok := created[node.Name]; ok {
+		return t
+	}
+	if len(node.Name) > 0 && vd.g.Exists(node.Name) {
+		if n, err := vd.g.Node(node.Name); err == nil {
+			return n.Type
+		}
+	}
+
+	return graph.NOOP
+}
+
+// node checks that the node exists with the type, NOOP matching any type.
+func (vd *validation) node(pointer, name string, t graph.NodeType) {
+	if !vd.g.Exists(name) {
+		vd.missing = append(vd.missing, MissingNode{pointer, name})
+		vd.add(pointer, "node %s does not exist", name)
+		return
+	}
+	if t == graph.NOOP {
+		return
+	}
+
+	n, err := vd.g.Node(name)
+	if err != nil {
+		vd.add(pointer, "%s", err)
+	} else if n.Type != t {
+		vd.add(pointer, "node %s is a %s not a %s", name, n.Type, t)
+	}
+}
+
+func (vd *validation) functions(pointer string, functions []*obligations.Function) {
+	for i, f := range functions {
+		vd.function(fmt.Sprintf("%s/%d/function", pointer, i), f, BOOLEAN)
+	}
+}
+
+// function checks that the function is registered, gets the arguments it expects and returns what is expected of
+// it, nested functions included.
+func (vd *validation) function(pointer string, function *obligations.Function, expected ValueType) {
+	if function == nil || vd.missingOnly {
+		return
+	}
+
+	vd.functionEvaluator.check(pointer, function, expected, true, vd.add)
+}
@@ -12,7 +12,8 @@ import (
 )
 
 var (
-	_ graph.Graph = &GraphAdmin{}
+	_ graph.Graph   = &GraphAdmin{}
+	_ graph.Querier = &GraphAdmin{}
 )
 
 type GraphAdmin struct {
@@ -200,6 +201,10 @@ func (ga *GraphAdmin) Search(t graph.NodeType, properties graph.PropertyMap) set
 	return ga.graph.Search(t, properties)
 }
 
+func (ga *GraphAdmin) Query(q *graph.Query) ([]*graph.Node, error) {
+	return graph.Find(ga.graph, q)
+}
+
 func (ga *GraphAdmin) Children(name string) set.Set {
 	return ga.graph.Children(name)
 }
@@ -7,14 +7,21 @@ import (
 
 var (
 	_ obligations.Obligations = &ObligationsAdmin{}
+	_ obligations.TimerStore  = &ObligationsAdmin{}
 )
 
 type ObligationsAdmin struct {
 	obligations obligations.Obligations
+	timers      obligations.TimerStore
 }
 
+// NewObligationsAdmin keeps the timers of scheduled rules in the obligations store when it can, otherwise in memory.
 func NewObligationsAdmin(pip common.PolicyStore) *ObligationsAdmin {
-	return &ObligationsAdmin{pip.Obligations()}
+	timers, ok := pip.Obligations().(obligations.TimerStore)
+	if !ok {
+		timers = obligations.NewTimerStore()
+	}
+	return &ObligationsAdmin{pip.Obligations(), timers}
 }
 
 func (oa *ObligationsAdmin) Add(obligation *obligations.Obligation, enable bool) {
@@ -44,3 +51,35 @@ func (oa *ObligationsAdmin) SetEnable(label string, enabled bool) {
 func (oa *ObligationsAdmin) GetEnabled() []*obligations.Obligation {
 	return oa.obligations.GetEnabled()
 }
+
+func (oa *ObligationsAdmin) AddRule(label string, rule *obligations.Rule) error {
+	return oa.obligations.AddRule(label, rule)
+}
+
+func (oa *ObligationsAdmin) RemoveRule(label, ruleLabel string) error {
+	return oa.obligations.RemoveRule(label, ruleLabel)
+}
+
+func (oa *ObligationsAdmin) ReplaceRule(label, ruleLabel string, rule *obligations.Rule) error {
+	return oa.obligations.ReplaceRule(label, ruleLabel, rule)
+}
+
+func (oa *ObligationsAdmin) SetRuleEnable(label, ruleLabel string, enabled bool) error {
+	return oa.obligations.SetRuleEnable(label, ruleLabel, enabled)
+}
+
+func (oa *ObligationsAdmin) Rules(label string) ([]*obligations.Rule, error) {
+	return oa.obligations.Rules(label)
+}
+
+func (oa *ObligationsAdmin) AddTimer(timer *obligations.Timer) {
+	oa.timers.AddTimer(timer)
+}
+
+func (oa *ObligationsAdmin) RemoveTimer(id string) {
+	oa.timers.RemoveTimer(id)
+}
+
+func (oa *ObligationsAdmin) PendingTimers() []*obligations.Timer {
+	return oa.timers.PendingTimers()
+}
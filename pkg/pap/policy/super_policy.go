@@ -63,10 +63,15 @@ func (sp *SuperPolicy) Configure(gr graph.Graph) (err error) {
         if sp.superPC, err = gr.Node("super_pc"); err != nil {
             return
         }
-        sp.superPC.Properties[graph.REP_PROPERTY] = superPCRep
-        if err = gr.UpdateNode(sp.superPC.Name, sp.superPC.Properties); err != nil {
+        properties := graph.NewPropertyMap()
+        for k, v := range sp.superPC.Properties {
+            properties[k] = v
+        }
+        properties[graph.REP_PROPERTY] = superPCRep
+        if err = gr.UpdateNode(sp.superPC.Name, properties); err != nil {
             return
         }
+        sp.superPC.Properties = properties
     }
 
     if !gr.Exists("super_ua1") {
@@ -24,6 +24,8 @@ func NewPReviewAuditor(graph graph.Graph, resourceOps operations.OperationSet) *
 }
 
 func (pa *PReviewAuditor) Explain(userID, target string) (*Explain, error) {
+    // the paths are found on a snapshot of the graph, consistent while they are traversed
+    pa = &PReviewAuditor{graph.Snapshot(pa.graph), pa.resourceOps}
     userNode, err := pa.graph.Node(userID)
     if err != nil {
         return nil, err
@@ -42,6 +42,12 @@ func NewPReviewDeciderWithProhibitions(graph graph.Graph, prohibs prohibitions.P
 	return d
 }
 
+// snapshot returns the decider reading a snapshot of the graph, so a decision traverses a consistent graph without
+// blocking its writers.
+func (pr *PReviewDecider) snapshot() *PReviewDecider {
+	return &PReviewDecider{graph.Snapshot(pr.graph), pr.prohibitions, pr.ResourceOps}
+}
+
 func (pr *PReviewDecider) Check(subject, process, target string, perms ...interface{}) bool {
 	allowed := pr.List(subject, process, target)
 
@@ -52,6 +58,7 @@ func (pr *PReviewDecider) Check(subject, process, target string, perms ...interf
 }
 
 func (pr *PReviewDecider) List(subject, process, target string) set.Set {
+	pr = pr.snapshot()
 	perms := set.NewSet()
 
 	// traverse the user side of the graph to get the associations
@@ -74,6 +81,7 @@ func (pr *PReviewDecider) List(subject, process, target string) set.Set {
 }
 
 func (pr *PReviewDecider) Filter(subject, process string, nodes set.Set, perms ...interface{}) set.Set {
+	pr = pr.snapshot()
 	n := set.NewSet()
 	for nn := range nodes.Iter() {
 		node := nn.(string)
@@ -86,11 +94,13 @@ func (pr *PReviewDecider) Filter(subject, process string, nodes set.Set, perms .
 }
 
 func (pr *PReviewDecider) Children(subject, process, target string, perms ...interface{}) set.Set {
+	pr = pr.snapshot()
 	children := pr.graph.Children(target)
 	return pr.Filter(subject, process, children, perms...)
 }
 
 func (pr *PReviewDecider) CapabilityList(subject, process string) map[string]set.Set {
+	pr = pr.snapshot()
 	results := make(map[string]set.Set)
 
 	//get border nodes.  Can be OA or UA.  Return empty set if no OAs are reachable
@@ -124,6 +134,7 @@ func (pr *PReviewDecider) CapabilityList(subject, process string) map[string]set
 }
 
 func (pr *PReviewDecider) GenerateACL(target, process string) map[string]set.Set {
+	pr = pr.snapshot()
 	acl := make(map[string]set.Set)
 
 	search := pr.graph.Search(graph.U, nil)
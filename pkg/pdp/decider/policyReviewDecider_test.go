@@ -1174,3 +1174,44 @@ func TestPermissionInOnlyOnePC(t *testing.T) {
 	}
 
 }
+
+func TestConcurrentDecisions(t *testing.T) {
+	g := gm.New()
+	pc1, _ := g.CreatePolicyClass("pc1", nil)
+	ua1, _ := g.CreateNode("ua1", graph.UA, nil, pc1.Name)
+	u1, _ := g.CreateNode("u1", graph.U, nil, ua1.Name)
+	oa1, _ := g.CreateNode("oa1", graph.OA, nil, pc1.Name)
+	oa2, _ := g.CreateNode("oa2", graph.OA, nil, pc1.Name)
+	o1, _ := g.CreateNode("o1", graph.O, nil, oa1.Name)
+	g.Associate(ua1.Name, oa1.Name, operations.NewOperationSet("read"))
+	g.Associate(ua1.Name, oa2.Name, operations.NewOperationSet("write"))
+
+	decider := NewPReviewDecider(g, rwe)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			if err := g.Assign(o1.Name, oa2.Name); err != nil {
+				t.Errorf("%s", err)
+				return
+			}
+			if err := g.Deassign(o1.Name, oa2.Name); err != nil {
+				t.Errorf("%s", err)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		list := decider.List(u1.Name, "", o1.Name)
+		if !list.Contains("read") || list.Len() > 2 {
+			t.Fatalf("unexpected permissions %v", list)
+		}
+	}
+}
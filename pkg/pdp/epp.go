@@ -1,6 +1,7 @@
 package pdp
 
 import (
+	"fmt"
 	"github.com/jtejido/ngac/pkg/common"
 	"github.com/jtejido/ngac/pkg/context"
 	"github.com/jtejido/ngac/pkg/epp"
@@ -13,6 +14,13 @@ type EPP struct {
 	pap               common.PolicyStore
 	pdp               *PDP
 	functionEvaluator *epp.FunctionEvaluator
+	bus               *epp.Bus
+	maxCascadeDepth   int
+	validator         *epp.Validator
+	rejectInvalid     bool
+	scheduler         *epp.Scheduler
+	deadLetters       *epp.DeadLetters
+	clock             epp.Clock
 }
 
 func NewEPP(pap common.PolicyStore, p *PDP, eppOptions *epp.EPPOptions) *EPP {
@@ -20,11 +28,28 @@ func NewEPP(pap common.PolicyStore, p *PDP, eppOptions *epp.EPPOptions) *EPP {
 	e.pap = pap
 	e.pdp = p
 	e.functionEvaluator = epp.NewFunctionEvaluator()
+	e.validator = epp.NewValidator(e.functionEvaluator)
+	e.maxCascadeDepth = epp.DefaultMaxCascadeDepth
+	e.clock = epp.SystemClock
+	if eppOptions != nil && eppOptions.Clock() != nil {
+		e.clock = eppOptions.Clock()
+	}
+	// current_time reads the clock of the EPP, unless replaced by the executors of the options
+	e.functionEvaluator.Add(&epp.CurrentTimeExecutor{Now: e.clock.Now})
+	var busOptions *epp.BusOptions
+	var deadLetterLimit int
 	if eppOptions != nil {
 		for _, executor := range eppOptions.Executors() {
 			e.functionEvaluator.Add(executor)
 		}
+		busOptions = eppOptions.BusOptions()
+		e.maxCascadeDepth = eppOptions.MaxCascadeDepth()
+		e.rejectInvalid = eppOptions.ObligationValidation()
+		deadLetterLimit = eppOptions.DeadLetterLimit()
 	}
+	e.bus = epp.NewBus(busOptions)
+	e.scheduler = epp.NewScheduler(pap.Obligations(), e.clock, e.fireTimer)
+	e.deadLetters = epp.NewDeadLetters(deadLetterLimit, e.clock)
 
 	return e
 }
@@ -37,6 +62,51 @@ func (e *EPP) RemoveFunctionExecutor(executor epp.FunctionExecutor) {
 	e.functionEvaluator.Remove(executor)
 }
 
+// Validator checks obligations against the function executors of the EPP.
+func (e *EPP) Validator() *epp.Validator {
+	return e.validator
+}
+
+// DryRun reports what the obligation would do in response to the event without changing the policy.
+func (e *EPP) DryRun(obligation *obligations.Obligation, eventCtx epp.EventContext) (*epp.DryRunReport, error) {
+	return epp.DryRun(e.pap, e.functionEvaluator, obligation, eventCtx)
+}
+
+// Scheduler keeps the timers of the scheduled rules. It fires the due ones when it ticks, Start makes it tick at a
+// regular interval.
+func (e *EPP) Scheduler() *epp.Scheduler {
+	return e.scheduler
+}
+
+// Subscribe registers a handler receiving every event processed by the EPP. The returned function removes it.
+func (e *EPP) Subscribe(name string, handler epp.Handler) (unsubscribe func()) {
+	return e.bus.Subscribe(name, handler)
+}
+
+// Wait blocks until the queued events are processed, it returns immediately in synchronous mode.
+func (e *EPP) Wait() {
+	e.bus.Wait()
+}
+
+// Close stops the scheduler and stops accepting events once the queued ones are processed.
+func (e *EPP) Close() {
+	e.scheduler.Stop()
+	e.bus.Close()
+}
+
+// fireTimer processes the time event of a due timer, the timers of removed obligations are ignored.
+func (e *EPP) fireTimer(timer *obligations.Timer) error {
+	obligation := e.pap.Obligations().Get(timer.Obligation)
+	if obligation == nil {
+		return nil
+	}
+
+	return e.ProcessEvent(epp.NewTimerEvent(e.pap.Graph(), obligation, timer))
+}
+
+// ProcessEvent delivers the event to every enabled obligation and to the subscribers. In synchronous mode the
+// obligations are applied before it returns, otherwise they are queued and the events of each obligation are
+// processed in order.
 func (e *EPP) ProcessEvent(eventCtx epp.EventContext) error {
 	obligs := e.pap.Obligations().All()
 	for _, obligation := range obligs {
@@ -44,26 +114,148 @@ func (e *EPP) ProcessEvent(eventCtx epp.EventContext) error {
 			continue
 		}
 
-		definingUser, _ := context.NewUserContext(obligation.User)
+		obligation := obligation
+		if err := e.bus.Deliver("obligation:"+obligation.Label, eventCtx, func(eventCtx epp.EventContext) error {
+			return e.applyObligation(obligation, eventCtx)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return e.bus.Publish(eventCtx)
+}
 
-		if err := e.pdp.WithUser(definingUser).RunTx(func(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations) error {
-			rules := obligation.Rules
-			for _, rule := range rules {
-				if !eventCtx.MatchesPattern(rule.EventPattern, g) {
-					continue
-				}
+// applyObligation applies the matching rules of the obligation following their error policies. A failure of the
+// whole obligation, like a CascadeError, follows the obligation's policy: it is returned with the abort policy, kept as
+// a dead letter otherwise.
+func (e *EPP) applyObligation(obligation *obligations.Obligation, eventCtx epp.EventContext) error {
+	policy := obligation.ErrorPolicy(nil)
+	var letters []*epp.DeadLetter
+	attempts, err := epp.Retry(e.clock, policy, func() (err error) {
+		letters, err = e.applyRules(obligation, eventCtx, obligation.EnabledRules(), true, nil)
+		return
+	})
+	if err != nil {
+		if policy.Policy == obligations.ABORT {
+			return err
+		}
+		letters = []*epp.DeadLetter{{Obligation: obligation.Label, Action: -1, Event: eventCtx, Err: err, Attempts: attempts}}
+	}
+
+	for _, letter := range letters {
+		e.deadLetters.Add(letter)
+	}
+
+	return nil
+}
 
-				err := epp.Apply(g, p, o, e.functionEvaluator, eventCtx, rule, obligation.Label)
+// applyRules applies the rules in a single transaction as the obligation's defining user, the rules not matching the
+// event are skipped when match is set and a time event only fires the rule of its timer. Each rule follows its error
+// policy unless one is given, the failures it tolerates are returned as dead letters. The events emitted by the
+// response carry the lineage of the event, so a cascade deeper than the maximum depth or a rule firing again on the
+// same target fails with a CascadeError.
+func (e *EPP) applyRules(obligation *obligations.Obligation, eventCtx epp.EventContext, rules []*obligations.Rule, match bool, policy *obligations.ErrorPolicy) ([]*epp.DeadLetter, error) {
+	definingUser, _ := context.NewUserContext(obligation.User)
+	lineage := eventCtx.Lineage()
+
+	// the rules scheduled after a matching event start their timers once the transaction commits, an invalid schedule
+	// fails the transaction so nothing can fail after the commit
+	var timers []*obligations.Timer
+	var letters []*epp.DeadLetter
+	err := e.pdp.WithUser(definingUser).RunTx(func(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations) error {
+		cause := epp.NewCause(obligation.Label, eventCtx)
+		for _, rule := range rules {
+			if match && epp.StartsTimer(rule, eventCtx, g) {
+				timer, err := e.scheduler.Timer(obligation.Label, rule, eventCtx)
 				if err != nil {
 					return err
 				}
+				timers = append(timers, timer)
+				continue
+			}
+			if match && !epp.Triggers(obligation.Label, rule, eventCtx, g) {
+				continue
 			}
 
-			return nil
-		}); err != nil {
-			return err
+			cause.Rules = append(cause.Rules, rule.Label)
+			if err := epp.CheckCascade(lineage, cause, e.maxCascadeDepth); err != nil {
+				return err
+			}
+
+			rulePolicy := policy
+			if rulePolicy == nil {
+				rulePolicy = obligation.ErrorPolicy(rule)
+			}
+			failed, err := epp.ApplyWithPolicy(g, p, o, e.functionEvaluator, eventCtx, rule, obligation.Label, rulePolicy, e.clock)
+			if err != nil {
+				return err
+			}
+			letters = append(letters, failed...)
 		}
+
+		// the events are emitted when the transaction commits
+		epp.SetLineage(definingUser, append(lineage[:len(lineage):len(lineage)], cause))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, timer := range timers {
+		e.scheduler.AddTimer(timer)
 	}
 
+	return letters, nil
+}
+
+// DeadLetters returns the failures tolerated by the error policies of the obligations.
+func (e *EPP) DeadLetters() *epp.DeadLetters {
+	return e.deadLetters
+}
+
+// Replay applies the failed response of the dead letter again, to the same event. A skipped action is applied alone
+// and a failed obligation applies every rule matching the event. The dead letter is removed when the response
+// succeeds, otherwise the error is returned and recorded in the dead letter.
+func (e *EPP) Replay(id uint64) error {
+	letter := e.deadLetters.Get(id)
+	if letter == nil {
+		return fmt.Errorf("dead letter %d does not exist", id)
+	}
+
+	err := e.replay(letter)
+	if err != nil {
+		e.deadLetters.Failed(id, err)
+		return err
+	}
+
+	e.deadLetters.Remove(id)
 	return nil
 }
+
+func (e *EPP) replay(letter *epp.DeadLetter) error {
+	obligation := e.pap.Obligations().Get(letter.Obligation)
+	if obligation == nil {
+		return fmt.Errorf("obligation %s does not exist", letter.Obligation)
+	}
+
+	abort := &obligations.ErrorPolicy{Policy: obligations.ABORT}
+	if len(letter.Rule) == 0 {
+		_, err := e.applyRules(obligation, letter.Event, obligation.EnabledRules(), true, abort)
+		return err
+	}
+
+	rule := obligation.Rule(letter.Rule)
+	if rule == nil {
+		return fmt.Errorf("obligation %s has no rule %s", obligation.Label, letter.Rule)
+	}
+	if letter.Action >= 0 {
+		var err error
+		if rule, err = epp.ActionRule(rule, letter.Action); err != nil {
+			return err
+		}
+	}
+
+	_, err := e.applyRules(obligation, letter.Event, []*obligations.Rule{rule}, false, abort)
+	return err
+}
@@ -0,0 +1,47 @@
+package pdp
+
+import (
+	"github.com/jtejido/ngac/pkg/common"
+	"github.com/jtejido/ngac/pkg/epp"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+)
+
+// The kinds of problems found by CheckIntegrity, besides the ones of graph.Validate.
+const (
+	MISSING_PROHIBITION_SUBJECT   = "missing_prohibition_subject"
+	MISSING_PROHIBITION_CONTAINER = "missing_prohibition_container"
+	MISSING_OBLIGATION_NODE       = "missing_obligation_node"
+)
+
+// CheckIntegrity checks the policy in the store, typically after an import or a migration. On top of the problems
+// reported by graph.Validate, it reports the prohibitions whose subject or containers don't exist and the obligations
+// naming nodes that don't exist. A subject that isn't a node may be a process, so it is only a warning.
+func CheckIntegrity(store common.PolicyStore) *graph.Report {
+	g := graph.Snapshot(store.Graph())
+	report := graph.Validate(g)
+
+	for _, prohibition := range store.Prohibitions().All() {
+		if !g.Exists(prohibition.Subject) {
+			problem := report.Add(MISSING_PROHIBITION_SUBJECT, prohibition.Name, []string{prohibition.Subject},
+				"the subject %s of prohibition %s isn't a node, unless it is a process", prohibition.Subject, prohibition.Name)
+			problem.Severity = graph.SEVERITY_WARNING
+		}
+		for container := range prohibition.Containers() {
+			if !g.Exists(container) {
+				report.Add(MISSING_PROHIBITION_CONTAINER, prohibition.Name, []string{container},
+					"the container %s of prohibition %s doesn't exist", container, prohibition.Name)
+			}
+		}
+	}
+
+	for _, obligation := range store.Obligations().All() {
+		for _, missing := range epp.MissingNodes(g, obligation) {
+			problem := report.Add(MISSING_OBLIGATION_NODE, obligation.Label, []string{missing.Name},
+				"obligation %s names %s which doesn't exist", obligation.Label, missing.Name)
+			problem.Pointer = missing.Pointer
+		}
+	}
+
+	report.Sort()
+	return report
+}
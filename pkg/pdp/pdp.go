@@ -1,6 +1,7 @@
 package pdp
 
 import (
+	"fmt"
 	"github.com/jtejido/ngac/pkg/common"
 	"github.com/jtejido/ngac/pkg/context"
 	"github.com/jtejido/ngac/pkg/epp"
@@ -48,20 +49,29 @@ type WithUser struct {
 	epp     *EPP
 	decider decider.Decider
 	auditor audit.Auditor
-	gs      graph.Graph
+	gs      *service.Graph
 	ps      prohibitions.Prohibitions
-	os      obligations.Obligations
+	os      *service.Obligations
 	// analyticsService
 }
 
 var _ common.PolicyStore = &WithUser{}
 
+// EPP returns the event processing point, used to subscribe to the events it processes.
+func (p *PDP) EPP() *EPP {
+	return p.epp
+}
+
 func (p *PDP) WithUser(userCtx context.Context) *WithUser {
 	return newWithUser(userCtx, p.pap, p.epp, p.decider, p.auditor)
 }
 
 func newWithUser(u context.Context, p common.PolicyStore, e *EPP, d decider.Decider, a audit.Auditor) *WithUser {
-	return &WithUser{u, p, e, d, a, service.NewGraphService(u, p, e, d, a), service.NewProhibitionsService(u, p, e, d, a), service.NewObligationsService(u, p, e, d, a)}
+	obs := service.NewObligationsService(u, p, e, d, a)
+	if e.rejectInvalid {
+		obs.SetValidator(e.validator)
+	}
+	return &WithUser{u, p, e, d, a, service.NewGraphService(u, p, e, d, a), service.NewProhibitionsService(u, p, e, d, a), obs}
 }
 
 func (wu *WithUser) Graph() graph.Graph {
@@ -72,6 +82,8 @@ func (wu *WithUser) Prohibitions() prohibitions.Prohibitions {
 	return wu.ps
 }
 
+// Obligations returns the obligations the user can manage. Its Add and Update discard the obligations the user isn't
+// allowed to add or that fail validation, use AddObligation and UpdateObligation to get the error.
 func (wu *WithUser) Obligations() obligations.Obligations {
 	return wu.os
 }
@@ -80,3 +92,61 @@ func (wu *WithUser) RunTx(txRunner common.TxRunner) error {
 	tx := tx.NewMemTx(wu.gs, wu.ps, wu.os)
 	return tx.RunTx(txRunner)
 }
+
+// ValidateObligation checks the obligation against the function executors of the EPP and the graph, the problems are
+// returned as an *obligations.ValidationError.
+func (wu *WithUser) ValidateObligation(obligation *obligations.Obligation) error {
+	return wu.epp.Validator().Validate(wu.pap.Graph(), obligation)
+}
+
+// AddObligation adds the obligation, the problems found when the EPP rejects invalid obligations are returned as an
+// *obligations.ValidationError.
+func (wu *WithUser) AddObligation(obligation *obligations.Obligation, enable bool) error {
+	return wu.os.AddValidated(obligation, enable)
+}
+
+// UpdateObligation updates the obligation, the problems found when the EPP rejects invalid obligations are returned as
+// an *obligations.ValidationError.
+func (wu *WithUser) UpdateObligation(label string, obligation *obligations.Obligation) error {
+	return wu.os.UpdateValidated(label, obligation)
+}
+
+// ToJSON serializes the policy, the user must have the "to json" permission on the super policy.
+func (wu *WithUser) ToJSON() ([]byte, error) {
+	return wu.gs.ToJSON()
+}
+
+// FromJSON loads a policy serialized by ToJSON, the user must have the "from json" permission on the super policy.
+func (wu *WithUser) FromJSON(b []byte) error {
+	return wu.gs.FromJSON(b)
+}
+
+// Access checks that the user can perform the operations on the target. An object access event is emitted to the EPP
+// for each operation when they are all allowed, otherwise a single access denied event carrying the operations.
+func (wu *WithUser) Access(target string, ops ...string) (bool, error) {
+	if len(ops) == 0 {
+		return false, fmt.Errorf("no operations to check on %s", target)
+	}
+
+	node, err := wu.pap.Graph().Node(target)
+	if err != nil {
+		return false, err
+	}
+
+	perms := make([]interface{}, len(ops))
+	for i, op := range ops {
+		perms[i] = op
+	}
+
+	if !wu.decider.Check(wu.userCtx.User(), wu.userCtx.Process(), target, perms...) {
+		return false, wu.epp.ProcessEvent(epp.NewAccessDeniedEvent(wu.userCtx, node, ops...))
+	}
+
+	for _, op := range ops {
+		if err := wu.epp.ProcessEvent(epp.NewObjectAccessEvent(wu.userCtx, op, node)); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
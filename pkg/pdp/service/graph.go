@@ -11,10 +11,14 @@ import (
     "github.com/jtejido/ngac/pkg/pdp/audit"
     "github.com/jtejido/ngac/pkg/pdp/decider"
     "github.com/jtejido/ngac/pkg/pdp/service/guard"
+    "github.com/jtejido/ngac/pkg/pip"
     "github.com/jtejido/ngac/pkg/pip/graph"
 )
 
-var _ graph.Graph = &Graph{}
+var (
+    _ graph.Graph   = &Graph{}
+    _ graph.Querier = &Graph{}
+)
 
 type Graph struct {
     Service
@@ -80,8 +84,29 @@ func (g *Graph) UpdateNode(name string, properties graph.PropertyMap) error {
         return err
     }
 
+    node, err := g.GraphAdmin().Node(name)
+    if err != nil {
+        return err
+    }
+
+    // copy the properties, the stored node may be updated in place
+    oldProperties := graph.NewPropertyMap()
+    for k, v := range node.Properties {
+        oldProperties[k] = v
+    }
+
     //update node in the PAP
-    return g.GraphAdmin().UpdateNode(name, properties)
+    if err := g.GraphAdmin().UpdateNode(name, properties); err != nil {
+        return err
+    }
+
+    updated, err := g.GraphAdmin().Node(name)
+    if err != nil {
+        return err
+    }
+
+    // process the event
+    return g.epp.ProcessEvent(epp.NewUpdateNodeEvent(g.userCtx, updated, oldProperties, updated.Properties))
 }
 
 /**
@@ -367,6 +392,21 @@ func (g *Graph) Search(t graph.NodeType, properties graph.PropertyMap) set.Set {
     return search
 }
 
+/**
+ * Find the nodes matching the query that the user has permissions on. The nodes are filtered before the offset and
+ * limit of the query are applied, so a page only holds nodes the user can see.
+ */
+func (g *Graph) Query(q *graph.Query) ([]*graph.Node, error) {
+    unpaged := *q
+    unpaged.Offset, unpaged.Limit = 0, 0
+    nodes, err := graph.Find(g.GraphAdmin(), &unpaged)
+    if err != nil {
+        return nil, err
+    }
+
+    return q.Page(g.guard.FilterNodeList(g.userCtx, nodes)), nil
+}
+
 /**
  * Retrieve the node from the graph with the given name.
  */
@@ -464,3 +504,25 @@ func (g *Graph) Reset(userCtx context.Context) (err error) {
     g.superPolicy = policy.NewSuperPolicy()
     return g.superPolicy.Configure(g.GraphAdmin())
 }
+
+/**
+ * Serialize the graph, prohibitions and obligations to a JSON document that can be loaded with FromJSON.
+ */
+func (g *Graph) ToJSON() ([]byte, error) {
+    if err := g.guard.CheckToJSON(g.userCtx); err != nil {
+        return nil, err
+    }
+
+    return pip.ToJSON(g.pap)
+}
+
+/**
+ * Load a JSON document created by ToJSON. Elements that already exist are left untouched.
+ */
+func (g *Graph) FromJSON(b []byte) error {
+    if err := g.guard.CheckFromJSON(g.userCtx); err != nil {
+        return err
+    }
+
+    return pip.FromJSON(g.pap, b)
+}
@@ -175,6 +175,18 @@ func (g *Graph) FilterNodes(userCtx context.Context, nodes set.Set) {
     })
 }
 
+// FilterNodeList returns the nodes the user has permissions on, in the same order.
+func (g *Graph) FilterNodeList(userCtx context.Context, nodes []*graph.Node) []*graph.Node {
+    filtered := make([]*graph.Node, 0, len(nodes))
+    for _, node := range nodes {
+        if ok, err := g.hasPermissions(userCtx, node.Name); err == nil && ok {
+            filtered = append(filtered, node)
+        }
+    }
+
+    return filtered
+}
+
 func (g *Graph) FilterMap(userCtx context.Context, m map[string]operations.OperationSet) {
     for key := range m {
         ok, err := g.hasPermissions(userCtx, key)
@@ -68,6 +68,32 @@ func (g *Guard) CheckReset(userCtx context.Context) error {
 	return nil
 }
 
+func (g *Guard) CheckToJSON(userCtx context.Context) error {
+	// check that the user can serialize the policy
+	ok, err := g.hasPermissions(userCtx, policy.SUPER_PC_REP, operations.TO_JSON)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("unauthorized permissions to serialize the policy")
+	}
+
+	return nil
+}
+
+func (g *Guard) CheckFromJSON(userCtx context.Context) error {
+	// check that the user can load a serialized policy
+	ok, err := g.hasPermissions(userCtx, policy.SUPER_PC_REP, operations.FROM_JSON)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("unauthorized permissions to load a serialized policy")
+	}
+
+	return nil
+}
+
 func (g *Guard) ResourceOps() operations.OperationSet {
 	return g.resourceOps
 }
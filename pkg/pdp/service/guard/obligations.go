@@ -80,3 +80,35 @@ func (o *Obligations) CheckEnable(userCtx context.Context) error {
     }
     return nil
 }
+
+// the rules are checked like the obligation they belong to
+func (o *Obligations) checkRule(userCtx context.Context, op, action string) error {
+    ok, err := o.hasPermissions(userCtx, policy.SUPER_OA, op)
+    if err != nil {
+        return err
+    }
+    if !ok {
+        return fmt.Errorf("unauthorized permissions to %s an obligation rule", action)
+    }
+    return nil
+}
+
+func (o *Obligations) CheckAddRule(userCtx context.Context) error {
+    return o.checkRule(userCtx, operations.UPDATE_OBLIGATION, "add")
+}
+
+func (o *Obligations) CheckRemoveRule(userCtx context.Context) error {
+    return o.checkRule(userCtx, operations.UPDATE_OBLIGATION, "remove")
+}
+
+func (o *Obligations) CheckReplaceRule(userCtx context.Context) error {
+    return o.checkRule(userCtx, operations.UPDATE_OBLIGATION, "replace")
+}
+
+func (o *Obligations) CheckEnableRule(userCtx context.Context) error {
+    return o.checkRule(userCtx, operations.ENABLE_OBLIGATION, "enable")
+}
+
+func (o *Obligations) CheckGetRules(userCtx context.Context) error {
+    return o.checkRule(userCtx, operations.GET_OBLIGATION, "get")
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+    "fmt"
     "github.com/jtejido/ngac/internal/set"
     "github.com/jtejido/ngac/pkg/common"
     "github.com/jtejido/ngac/pkg/context"
@@ -15,7 +16,8 @@ var _ obligations.Obligations = &Obligations{}
 
 type Obligations struct {
     Service
-    guard *guard.Obligations
+    guard     *guard.Obligations
+    validator *epp.Validator
 }
 
 func NewObligationsService(userCtx context.Context, p common.PolicyStore, e epp.EPP, d decider.Decider, a audit.Auditor) *Obligations {
@@ -29,9 +31,38 @@ func NewObligationsService(userCtx context.Context, p common.PolicyStore, e epp.
     return ans
 }
 
+// SetValidator makes the service reject the obligations failing validation, nil accepts any obligation.
+func (o *Obligations) SetValidator(validator *epp.Validator) {
+    o.validator = validator
+}
+
+// Validate returns an *obligations.ValidationError listing the problems of the obligation, it always succeeds when
+// the service has no validator.
+func (o *Obligations) Validate(obligation *obligations.Obligation) error {
+    if o.validator == nil {
+        return nil
+    }
+
+    return o.validator.Validate(o.GraphAdmin(), obligation)
+}
+
+// Add the obligation. Add can't report a failure: an obligation the user isn't allowed to add or failing validation
+// is discarded without notice. Callers that need to know use AddValidated, or AddObligation on the PDP.
 func (o *Obligations) Add(obligation *obligations.Obligation, enable bool) {
-    o.guard.CheckAdd(o.userCtx)
+    o.AddValidated(obligation, enable)
+}
+
+// AddValidated adds the obligation, returning an *obligations.ValidationError when it fails validation.
+func (o *Obligations) AddValidated(obligation *obligations.Obligation, enable bool) error {
+    if err := o.guard.CheckAdd(o.userCtx); err != nil {
+        return err
+    }
+    if err := o.Validate(obligation); err != nil {
+        return err
+    }
+
     o.ObligationsAdmin().Add(obligation, enable)
+    return nil
 }
 
 func (o *Obligations) Get(label string) *obligations.Obligation {
@@ -44,9 +75,24 @@ func (o *Obligations) All() []*obligations.Obligation {
     return o.ObligationsAdmin().All()
 }
 
+// Update the obligation. Update can't report a failure: an update the user isn't allowed to make or failing
+// validation is discarded without notice. Callers that need to know use UpdateValidated, or UpdateObligation on the
+// PDP.
 func (o *Obligations) Update(label string, obligation *obligations.Obligation) {
-    o.guard.CheckUpdate(o.userCtx)
+    o.UpdateValidated(label, obligation)
+}
+
+// UpdateValidated updates the obligation, returning an *obligations.ValidationError when it fails validation.
+func (o *Obligations) UpdateValidated(label string, obligation *obligations.Obligation) error {
+    if err := o.guard.CheckUpdate(o.userCtx); err != nil {
+        return err
+    }
+    if err := o.Validate(obligation); err != nil {
+        return err
+    }
+
     o.ObligationsAdmin().Update(label, obligation)
+    return nil
 }
 
 func (o *Obligations) Remove(label string) {
@@ -64,6 +110,74 @@ func (o *Obligations) GetEnabled() []*obligations.Obligation {
     return o.ObligationsAdmin().GetEnabled()
 }
 
+// validateRules validates the obligation as it would be after the change.
+func (o *Obligations) validateRules(label string, change func(*obligations.Obligation) error) error {
+    if o.validator == nil {
+        return nil
+    }
+    obligation := o.ObligationsAdmin().Get(label)
+    if obligation == nil {
+        return fmt.Errorf("obligation %s does not exist", label)
+    }
+
+    updated := obligation.Clone()
+    if err := change(updated); err != nil {
+        return err
+    }
+
+    return o.Validate(updated)
+}
+
+func (o *Obligations) AddRule(label string, rule *obligations.Rule) error {
+    if err := o.guard.CheckAddRule(o.userCtx); err != nil {
+        return err
+    }
+    if err := o.validateRules(label, func(obligation *obligations.Obligation) error {
+        return obligation.AddRule(rule)
+    }); err != nil {
+        return err
+    }
+
+    return o.ObligationsAdmin().AddRule(label, rule)
+}
+
+func (o *Obligations) RemoveRule(label, ruleLabel string) error {
+    if err := o.guard.CheckRemoveRule(o.userCtx); err != nil {
+        return err
+    }
+
+    return o.ObligationsAdmin().RemoveRule(label, ruleLabel)
+}
+
+func (o *Obligations) ReplaceRule(label, ruleLabel string, rule *obligations.Rule) error {
+    if err := o.guard.CheckReplaceRule(o.userCtx); err != nil {
+        return err
+    }
+    if err := o.validateRules(label, func(obligation *obligations.Obligation) error {
+        return obligation.ReplaceRule(ruleLabel, rule)
+    }); err != nil {
+        return err
+    }
+
+    return o.ObligationsAdmin().ReplaceRule(label, ruleLabel, rule)
+}
+
+func (o *Obligations) SetRuleEnable(label, ruleLabel string, enabled bool) error {
+    if err := o.guard.CheckEnableRule(o.userCtx); err != nil {
+        return err
+    }
+
+    return o.ObligationsAdmin().SetRuleEnable(label, ruleLabel, enabled)
+}
+
+func (o *Obligations) Rules(label string) ([]*obligations.Rule, error) {
+    if err := o.guard.CheckGetRules(o.userCtx); err != nil {
+        return nil, err
+    }
+
+    return o.ObligationsAdmin().Rules(label)
+}
+
 func (o *Obligations) Reset() (err error) {
     o.guard.CheckReset(o.userCtx)
 
@@ -44,6 +44,9 @@ func (p *Prohibitions) All() []*prohibitions.Prohibition {
 
 func (p *Prohibitions) Get(prohibitionName string) *prohibitions.Prohibition {
     prohibition := p.ProhibitionsAdmin().Get(prohibitionName)
+    if prohibition == nil {
+        return nil
+    }
     p.guard.CheckGet(p.userCtx, prohibition)
 
     return prohibition
@@ -0,0 +1,594 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jtejido/ngac/internal/set"
+	"github.com/jtejido/ngac/pkg/operations"
+	g "github.com/jtejido/ngac/pkg/pip/graph"
+	bolt "go.etcd.io/bbolt"
+)
+
+var _ g.Graph = &graph{}
+
+const (
+	node_not_found_msg = "node %s does not exist in the graph"
+)
+
+// The graph is kept in the following buckets:
+//
+//	nodes:     name -> json encoded node
+//	pcs:       name -> nil, the policy class index
+//	parents:   child -> bucket of parent -> nil
+//	children:  parent -> bucket of child -> nil
+//	sources:   ua -> bucket of target -> json encoded operations
+//	targets:   target -> bucket of ua -> json encoded operations
+var (
+	nodes_bucket    = []byte("nodes")
+	pcs_bucket      = []byte("pcs")
+	parents_bucket  = []byte("parents")
+	children_bucket = []byte("children")
+	sources_bucket  = []byte("sources")
+	targets_bucket  = []byte("targets")
+
+	buckets = [][]byte{nodes_bucket, pcs_bucket, parents_bucket, children_bucket, sources_bucket, targets_bucket}
+)
+
+// This is a dag implementation persisted in a single bbolt file.
+type graph struct {
+	db *bolt.DB
+}
+
+// Opens (or creates) the graph stored in the file at the given path.
+func New(path string) (g.Graph, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &graph{db}, nil
+}
+
+func (bg *graph) Close() error {
+	return bg.db.Close()
+}
+
+func (bg *graph) view(fn func(tx *bolt.Tx) error) {
+	if err := bg.db.View(fn); err != nil {
+		panic(err)
+	}
+}
+
+func getNode(tx *bolt.Tx, name string) (*g.Node, error) {
+	v := tx.Bucket(nodes_bucket).Get([]byte(name))
+	if v == nil {
+		return nil, nil
+	}
+
+	return decodeNode(v)
+}
+
+func decodeNode(v []byte) (*g.Node, error) {
+	var raw struct {
+		Name       string
+		Type       string
+		Properties g.PropertyMap
+	}
+	if err := json.Unmarshal(v, &raw); err != nil {
+		return nil, err
+	}
+
+	if raw.Properties == nil {
+		raw.Properties = g.NewPropertyMap()
+	}
+
+	return &g.Node{Name: raw.Name, Type: g.ToNodeType(raw.Type), Properties: raw.Properties}, nil
+}
+
+func putNode(tx *bolt.Tx, n *g.Node) error {
+	v, err := json.Marshal(map[string]interface{}{
+		"Name":       n.Name,
+		"Type":       n.Type.String(),
+		"Properties": n.Properties,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(nodes_bucket).Put([]byte(n.Name), v)
+}
+
+func nodeExists(tx *bolt.Tx, name string) bool {
+	return tx.Bucket(nodes_bucket).Get([]byte(name)) != nil
+}
+
+// putEdge stores the key from -> to in the given bucket, creating the nested bucket of from if needed.
+func putEdge(tx *bolt.Tx, bucket []byte, from, to string, value []byte) error {
+	b, err := tx.Bucket(bucket).CreateBucketIfNotExists([]byte(from))
+	if err != nil {
+		return err
+	}
+
+	if value == nil {
+		value = []byte{}
+	}
+
+	return b.Put([]byte(to), value)
+}
+
+func deleteEdge(tx *bolt.Tx, bucket []byte, from, to string) error {
+	b := tx.Bucket(bucket).Bucket([]byte(from))
+	if b == nil {
+		return nil
+	}
+
+	return b.Delete([]byte(to))
+}
+
+func getEdge(tx *bolt.Tx, bucket []byte, from, to string) []byte {
+	b := tx.Bucket(bucket).Bucket([]byte(from))
+	if b == nil {
+		return nil
+	}
+
+	return b.Get([]byte(to))
+}
+
+// edges calls fn for every key -> value stored in the nested bucket of from.
+func edges(tx *bolt.Tx, bucket []byte, from string, fn func(to string, value []byte) error) error {
+	b := tx.Bucket(bucket).Bucket([]byte(from))
+	if b == nil {
+		return nil
+	}
+
+	return b.ForEach(func(k, v []byte) error {
+		return fn(string(k), v)
+	})
+}
+
+func encodeOperations(ops operations.OperationSet) ([]byte, error) {
+	s := make([]string, 0)
+	if ops != nil {
+		for op := range ops.Iter() {
+			s = append(s, op.(string))
+		}
+	}
+
+	return json.Marshal(s)
+}
+
+func decodeOperations(v []byte) (operations.OperationSet, error) {
+	var s []string
+	if err := json.Unmarshal(v, &s); err != nil {
+		return nil, err
+	}
+
+	ops := operations.NewOperationSet()
+	for _, op := range s {
+		ops.Add(op)
+	}
+
+	return ops, nil
+}
+
+func (bg *graph) CreatePolicyClass(name string, properties g.PropertyMap) (*g.Node, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("no name was provided when creating a node in the bolt graph")
+	}
+
+	if properties == nil {
+		properties = g.NewPropertyMap()
+	}
+
+	node := &g.Node{Name: name, Type: g.PC, Properties: properties}
+	err := bg.db.Update(func(tx *bolt.Tx) error {
+		if nodeExists(tx, name) {
+			return fmt.Errorf("the name %s already exists in the graph", name)
+		}
+
+		if err := putNode(tx, node); err != nil {
+			return err
+		}
+
+		// add the pc's name to the pc index
+		return tx.Bucket(pcs_bucket).Put([]byte(name), []byte{})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+func (bg *graph) CreateNode(name string, t g.NodeType, properties g.PropertyMap, initialParent string, additionalParents ...string) (*g.Node, error) {
+	if t == g.PC {
+		return nil, fmt.Errorf("use CreatePolicyClass to create a policy class node")
+	} else if len(name) == 0 {
+		return nil, fmt.Errorf("no name was provided when creating a node in the bolt graph")
+	}
+
+	if properties == nil {
+		properties = g.NewPropertyMap()
+	}
+
+	node := &g.Node{Name: name, Type: t, Properties: properties}
+	err := bg.db.Update(func(tx *bolt.Tx) error {
+		if nodeExists(tx, name) {
+			return fmt.Errorf("the name %s already exists in the graph", name)
+		}
+
+		if err := putNode(tx, node); err != nil {
+			return err
+		}
+
+		// assign the new node the to given parent nodes, nothing is stored if one of the assignments fails
+		for _, parent := range append([]string{initialParent}, additionalParents...) {
+			if err := assign(tx, name, parent); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+func (bg *graph) UpdateNode(name string, properties g.PropertyMap) error {
+	return bg.db.Update(func(tx *bolt.Tx) error {
+		n, err := getNode(tx, name)
+		if err != nil {
+			return err
+		}
+		if n == nil {
+			return fmt.Errorf("node with the name %s could not be found to update", name)
+		}
+
+		// update the properties
+		if properties == nil {
+			return nil
+		}
+		n.Properties = properties
+
+		return putNode(tx, n)
+	})
+}
+
+func (bg *graph) RemoveNode(name string) {
+	err := bg.db.Update(func(tx *bolt.Tx) error {
+		if !nodeExists(tx, name) {
+			return nil
+		}
+
+		// remove both directions of every edge the node is part of
+		for _, pair := range [][2][]byte{
+			{parents_bucket, children_bucket},
+			{children_bucket, parents_bucket},
+			{sources_bucket, targets_bucket},
+			{targets_bucket, sources_bucket},
+		} {
+			err := edges(tx, pair[0], name, func(to string, _ []byte) error {
+				return deleteEdge(tx, pair[1], to, name)
+			})
+			if err != nil {
+				return err
+			}
+
+			if tx.Bucket(pair[0]).Bucket([]byte(name)) != nil {
+				if err := tx.Bucket(pair[0]).DeleteBucket([]byte(name)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Bucket(nodes_bucket).Delete([]byte(name)); err != nil {
+			return err
+		}
+
+		//remove the node from the policies if it is a policy class
+		return tx.Bucket(pcs_bucket).Delete([]byte(name))
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (bg *graph) Exists(name string) (exists bool) {
+	bg.view(func(tx *bolt.Tx) error {
+		exists = nodeExists(tx, name)
+		return nil
+	})
+
+	return
+}
+
+func (bg *graph) PolicyClasses() set.Set {
+	pcs := set.NewSet()
+	bg.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(pcs_bucket).ForEach(func(k, _ []byte) error {
+			pcs.Add(string(k))
+			return nil
+		})
+	})
+
+	return pcs
+}
+
+func (bg *graph) Nodes() set.Set {
+	s := set.NewSet()
+	bg.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(nodes_bucket).ForEach(func(_, v []byte) error {
+			n, err := decodeNode(v)
+			if err != nil {
+				return err
+			}
+
+			s.Add(n)
+			return nil
+		})
+	})
+
+	return s
+}
+
+func (bg *graph) Node(name string) (node *g.Node, err error) {
+	bg.view(func(tx *bolt.Tx) error {
+		node, err = getNode(tx, name)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if node == nil {
+		return nil, fmt.Errorf("a node with the name %s does not exist", name)
+	}
+
+	return node, nil
+}
+
+func (bg *graph) NodeFromDetails(t g.NodeType, properties g.PropertyMap) (*g.Node, error) {
+	search := bg.Search(t, properties).Iterator()
+	if !search.HasNext() {
+		return nil, fmt.Errorf("a node matching the criteria (%s, %v) does not exist", t.String(), properties)
+	}
+
+	return search.Next().(*g.Node), nil
+}
+
+func (bg *graph) Search(t g.NodeType, properties g.PropertyMap) set.Set {
+	if properties == nil {
+		properties = g.NewPropertyMap()
+	}
+
+	results := set.NewSet()
+	// iterate over the nodes to find ones that match the search parameters
+	for n := range bg.Nodes().Iter() {
+		node := n.(*g.Node)
+		if node.Type != t && t != g.NOOP {
+			continue
+		}
+
+		match := true
+		for k, v := range properties {
+			if node.Properties[k] != v {
+				match = false
+			}
+		}
+
+		if match {
+			results.Add(node)
+		}
+	}
+
+	return results
+}
+
+func (bg *graph) Children(name string) set.Set {
+	return bg.related(children_bucket, name)
+}
+
+func (bg *graph) Parents(name string) set.Set {
+	return bg.related(parents_bucket, name)
+}
+
+func (bg *graph) related(bucket []byte, name string) set.Set {
+	s := set.NewSet()
+	bg.view(func(tx *bolt.Tx) error {
+		if !nodeExists(tx, name) {
+			return fmt.Errorf(node_not_found_msg, name)
+		}
+
+		return edges(tx, bucket, name, func(to string, _ []byte) error {
+			s.Add(to)
+			return nil
+		})
+	})
+
+	return s
+}
+
+func assign(tx *bolt.Tx, child, parent string) error {
+	c, err := getNode(tx, child)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf(node_not_found_msg, child)
+	}
+
+	p, err := getNode(tx, parent)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf(node_not_found_msg, parent)
+	}
+
+	if child == parent {
+		return fmt.Errorf("adding self edge")
+	}
+
+	if getEdge(tx, parents_bucket, child, parent) != nil {
+		return fmt.Errorf("%s is already assigned to %s", parent, child)
+	}
+
+	if err := g.CheckAssignment(c.Type, p.Type); err != nil {
+		return err
+	}
+
+	if err := g.CheckCycle(child, parent, func(name string) []string {
+		parents := make([]string, 0)
+		edges(tx, parents_bucket, name, func(to string, _ []byte) error {
+			parents = append(parents, to)
+			return nil
+		})
+		return parents
+	}); err != nil {
+		return err
+	}
+
+	if err := putEdge(tx, parents_bucket, child, parent, nil); err != nil {
+		return err
+	}
+
+	return putEdge(tx, children_bucket, parent, child, nil)
+}
+
+func (bg *graph) Assign(child, parent string) error {
+	return bg.db.Update(func(tx *bolt.Tx) error {
+		return assign(tx, child, parent)
+	})
+}
+
+func (bg *graph) Deassign(child, parent string) error {
+	return bg.db.Update(func(tx *bolt.Tx) error {
+		if !nodeExists(tx, child) {
+			return fmt.Errorf("source vertex not in the g.")
+		} else if !nodeExists(tx, parent) {
+			return fmt.Errorf("target vertex not in the g.")
+		}
+
+		if err := deleteEdge(tx, parents_bucket, child, parent); err != nil {
+			return err
+		}
+
+		return deleteEdge(tx, children_bucket, parent, child)
+	})
+}
+
+func (bg *graph) IsAssigned(child, parent string) (assigned bool) {
+	bg.view(func(tx *bolt.Tx) error {
+		assigned = getEdge(tx, parents_bucket, child, parent) != nil
+		return nil
+	})
+
+	return
+}
+
+func (bg *graph) Associate(ua, target string, ops operations.OperationSet) error {
+	return bg.db.Update(func(tx *bolt.Tx) error {
+		uaNode, err := getNode(tx, ua)
+		if err != nil {
+			return err
+		}
+		if uaNode == nil {
+			return fmt.Errorf(node_not_found_msg, ua)
+		}
+
+		targetNode, err := getNode(tx, target)
+		if err != nil {
+			return err
+		}
+		if targetNode == nil {
+			return fmt.Errorf(node_not_found_msg, target)
+		}
+
+		// check that the association is valid
+		if err := g.CheckAssociation(uaNode.Type, targetNode.Type); err != nil {
+			return err
+		}
+
+		if ua == target {
+			return fmt.Errorf("adding self edge")
+		}
+
+		// create the association or replace the operations of an existing one
+		v, err := encodeOperations(ops)
+		if err != nil {
+			return err
+		}
+
+		if err := putEdge(tx, sources_bucket, ua, target, v); err != nil {
+			return err
+		}
+
+		return putEdge(tx, targets_bucket, target, ua, v)
+	})
+}
+
+func (bg *graph) Dissociate(ua, target string) error {
+	return bg.db.Update(func(tx *bolt.Tx) error {
+		if !nodeExists(tx, ua) {
+			return fmt.Errorf("source vertex not in the g.")
+		} else if !nodeExists(tx, target) {
+			return fmt.Errorf("target vertex not in the g.")
+		}
+
+		if err := deleteEdge(tx, sources_bucket, ua, target); err != nil {
+			return err
+		}
+
+		return deleteEdge(tx, targets_bucket, target, ua)
+	})
+}
+
+func (bg *graph) SourceAssociations(source string) (map[string]operations.OperationSet, error) {
+	return bg.associations(sources_bucket, source)
+}
+
+func (bg *graph) TargetAssociations(target string) (map[string]operations.OperationSet, error) {
+	return bg.associations(targets_bucket, target)
+}
+
+func (bg *graph) associations(bucket []byte, name string) (map[string]operations.OperationSet, error) {
+	assocs := make(map[string]operations.OperationSet)
+	err := bg.db.View(func(tx *bolt.Tx) error {
+		if !nodeExists(tx, name) {
+			return fmt.Errorf(node_not_found_msg, name)
+		}
+
+		return edges(tx, bucket, name, func(to string, v []byte) error {
+			ops, err := decodeOperations(v)
+			if err != nil {
+				return err
+			}
+
+			assocs[to] = ops
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return assocs, nil
+}
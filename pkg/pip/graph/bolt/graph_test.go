@@ -0,0 +1,525 @@
+package bolt
+
+import (
+	"github.com/jtejido/ngac/pkg/operations"
+	gg "github.com/jtejido/ngac/pkg/pip/graph"
+	"path/filepath"
+	"testing"
+)
+
+// testGraph opens a new graph in a temporary directory that is removed once the test is done.
+func testGraph(t *testing.T) gg.Graph {
+	g, err := New(filepath.Join(t.TempDir(), "graph.db"))
+	if err != nil {
+		t.Fatalf("failed to open graph: %s", err)
+	}
+	t.Cleanup(func() { g.(*graph).Close() })
+
+	return g
+}
+
+func TestCreateNode(t *testing.T) {
+	g := testGraph(t)
+
+	pc, _ := g.CreatePolicyClass("pc", nil)
+
+	if !g.PolicyClasses().Contains(pc.Name) {
+		t.Fatalf("failed to lookup policy class")
+	}
+
+	node, _ := g.CreateNode("oa", gg.OA, gg.ToProperties(gg.PropertyPair{"namespace", "test"}), pc.Name)
+
+	// check node is added
+	node, _ = g.Node(node.Name)
+
+	if node.Name != "oa" {
+		t.Fatalf("failed to lookup node")
+	}
+
+	if node.Type != gg.OA {
+		t.Fatalf("failed to lookup type")
+	}
+}
+
+func TestUpdateNode(t *testing.T) {
+	g := testGraph(t)
+
+	node, _ := g.CreatePolicyClass("node", gg.ToProperties(gg.PropertyPair{"namespace", "test"}))
+
+	if err := g.UpdateNode("newNodeName", nil); err == nil {
+		t.Fatalf("failed to catch an error for non-existing node update")
+	}
+
+	g.UpdateNode("node", gg.ToProperties(gg.PropertyPair{"newKey", "newValue"}))
+
+	n, _ := g.Node(node.Name)
+
+	if v, _ := n.Properties["newKey"]; v != "newValue" {
+		t.Fatalf("failed to update properties")
+	}
+}
+
+func TestRemoveNode(t *testing.T) {
+	g := testGraph(t)
+
+	node, _ := g.CreatePolicyClass("node", gg.ToProperties(gg.PropertyPair{"namespace", "test"}))
+
+	g.RemoveNode(node.Name)
+
+	if g.Exists(node.Name) {
+		t.Fatalf("node should not exist after deleting")
+	}
+
+	if g.PolicyClasses().Contains(node.Name) {
+		t.Fatalf("node should not have policy after deletion of policy node")
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	g := testGraph(t)
+
+	g.CreatePolicyClass("node1", nil)
+	g.CreatePolicyClass("node2", nil)
+	g.CreatePolicyClass("node3", nil)
+
+	if g.PolicyClasses().Len() != 3 {
+		t.Fatalf("node should not have 3 policies")
+	}
+}
+
+func TestChildren(t *testing.T) {
+	g := testGraph(t)
+
+	parentNode, _ := g.CreatePolicyClass("parent", nil)
+
+	child1Node, _ := g.CreateNode("child1", gg.OA, nil, "parent")
+	child2Node, _ := g.CreateNode("child2", gg.OA, nil, "parent")
+
+	children := g.Children(parentNode.Name)
+
+	if !children.Contains(child1Node.Name, child2Node.Name) {
+		t.Fatalf("failed to lookup child 1 or 2")
+	}
+}
+
+func TestParents(t *testing.T) {
+	g := testGraph(t)
+
+	parent1Node, _ := g.CreatePolicyClass("parent1", nil)
+	parent2Node, _ := g.CreateNode("parent2", gg.OA, nil, "parent1")
+	child1Node, _ := g.CreateNode("child1", gg.OA, nil, "parent1", "parent2")
+
+	parents := g.Parents(child1Node.Name)
+
+	if !parents.Contains(parent1Node.Name, parent2Node.Name) {
+		t.Fatalf("failed to lookup parent 1 or 2")
+	}
+}
+
+func TestAssign(t *testing.T) {
+	g := testGraph(t)
+
+	parent1Node, _ := g.CreatePolicyClass("parent1", nil)
+	child1Node, _ := g.CreateNode("child1", gg.OA, nil, "parent1")
+	child2Node, _ := g.CreateNode("child2", gg.OA, nil, "parent1")
+
+	if err := g.Assign("1241124", "123442141"); err == nil {
+		t.Fatalf("should not assign non existing node ids")
+	}
+
+	if err := g.Assign("1", "12341234"); err == nil {
+		t.Fatalf("should not assign non existing node ids")
+	}
+
+	g.Assign(child1Node.Name, child2Node.Name)
+
+	if !g.Children(parent1Node.Name).Contains(child1Node.Name) {
+		t.Fatalf("failed to lookup child 1")
+	}
+
+	if !g.Parents(child1Node.Name).Contains(parent1Node.Name) {
+		t.Fatalf("failed to lookup parent")
+	}
+}
+
+func TestDeassign(t *testing.T) {
+	g := testGraph(t)
+
+	parent1Node, _ := g.CreatePolicyClass("parent1", nil)
+	child1Node, _ := g.CreateNode("child1", gg.OA, nil, "parent1")
+
+	if err := g.Assign("", ""); err == nil {
+		t.Fatalf("should not assign non existing node ids")
+	}
+
+	if err := g.Assign(child1Node.Name, ""); err == nil {
+		t.Fatalf("should not assign non existing node ids")
+	}
+
+	g.Deassign(child1Node.Name, parent1Node.Name)
+
+	if g.Children(parent1Node.Name).Contains(child1Node.Name) {
+		t.Fatalf("still able lookup child")
+	}
+
+	if g.Parents(child1Node.Name).Contains(parent1Node.Name) {
+		t.Fatalf("still able lookup parent")
+	}
+
+}
+
+func TestAssociate(t *testing.T) {
+	g := testGraph(t)
+
+	g.CreatePolicyClass("pc", nil)
+	uaNode, _ := g.CreateNode("subject", gg.UA, nil, "pc")
+	targetNode, _ := g.CreateNode("target", gg.OA, nil, "pc")
+
+	g.Associate(uaNode.Name, targetNode.Name, operations.NewOperationSet("read", "write"))
+
+	associations, err := g.SourceAssociations(uaNode.Name)
+	if err != nil {
+		t.Fatalf("error thrown at getting source associations")
+	}
+
+	if _, ok := associations[targetNode.Name]; !ok {
+		t.Fatalf("failed to get association for id: %s", targetNode.Name)
+	}
+
+	if !associations[targetNode.Name].Contains("read", "write") {
+		t.Fatalf("failed to get right associations for source:  read/write")
+	}
+
+	associations, err = g.TargetAssociations(targetNode.Name)
+
+	if err != nil {
+		t.Fatalf("error thrown at getting target associations")
+	}
+
+	if _, ok := associations[uaNode.Name]; !ok {
+		t.Fatalf("failed to get association for id: %s", uaNode.Name)
+	}
+
+	if !associations[uaNode.Name].Contains("read", "write") {
+		t.Fatalf("failed to get right associations for target:  read/write")
+	}
+
+	g.CreateNode("test", gg.UA, nil, "subject")
+	g.Associate("test", "subject", operations.NewOperationSet("read"))
+	associations, err = g.SourceAssociations("test")
+	if err != nil {
+		t.Fatalf("error thrown at getting source associations")
+	}
+
+	if _, ok := associations["subject"]; !ok {
+		t.Fatalf("failed to get association for id: subject")
+	}
+
+	if !associations["subject"].Contains("read") {
+		t.Fatalf("failed to get right associations for source:  read")
+	}
+
+}
+
+func TestDissociate(t *testing.T) {
+	g := testGraph(t)
+
+	g.CreatePolicyClass("pc", nil)
+	uaNode, _ := g.CreateNode("subject", gg.UA, nil, "pc")
+	targetNode, _ := g.CreateNode("target", gg.OA, nil, "pc")
+
+	g.Associate(uaNode.Name, targetNode.Name, operations.NewOperationSet("read", "write"))
+	g.Dissociate(uaNode.Name, targetNode.Name)
+
+	associations, err := g.SourceAssociations(uaNode.Name)
+
+	if err != nil {
+		t.Fatalf("error thrown at getting source associations")
+	}
+
+	if _, ok := associations[targetNode.Name]; ok {
+		t.Fatalf("able to get association for target id: %s", targetNode.Name)
+	}
+
+	associations, err = g.TargetAssociations(targetNode.Name)
+
+	if err != nil {
+		t.Fatalf("error thrown at getting target associations")
+	}
+
+	if _, ok := associations[uaNode.Name]; ok {
+		t.Fatalf("able to get association for source id: %s", uaNode.Name)
+	}
+}
+
+func TestSourceAssociations(t *testing.T) {
+	g := testGraph(t)
+
+	g.CreatePolicyClass("pc", nil)
+	uaNode, _ := g.CreateNode("subject", gg.UA, nil, "pc")
+	targetNode, _ := g.CreateNode("target", gg.OA, nil, "pc")
+
+	g.Associate(uaNode.Name, targetNode.Name, operations.NewOperationSet("read", "write"))
+
+	associations, err := g.SourceAssociations(uaNode.Name)
+
+	if err != nil {
+		t.Fatalf("error thrown at getting uaNode associations")
+	}
+
+	if _, ok := associations[targetNode.Name]; !ok {
+		t.Fatalf("failed to get association for target id: %s", targetNode.Name)
+	}
+
+	if !associations[targetNode.Name].Contains("read", "write") {
+		t.Fatalf("failed to get right associations for target:  read/write")
+	}
+
+	if _, err := g.SourceAssociations("123"); err == nil {
+		t.Fatalf("able to get association for source id: %s", "123")
+	}
+}
+
+func TestTargetAssociations(t *testing.T) {
+	g := testGraph(t)
+
+	g.CreatePolicyClass("pc", nil)
+	uaNode, _ := g.CreateNode("subject", gg.UA, nil, "pc")
+	targetNode, _ := g.CreateNode("target", gg.OA, nil, "pc")
+
+	g.Associate(uaNode.Name, targetNode.Name, operations.NewOperationSet("read", "write"))
+
+	associations, err := g.TargetAssociations(targetNode.Name)
+
+	if err != nil {
+		t.Fatalf("error thrown at getting uaNode associations")
+	}
+
+	if _, ok := associations[uaNode.Name]; !ok {
+		t.Fatalf("failed to get association for source id: %s", uaNode.Name)
+	}
+
+	if !associations[uaNode.Name].Contains("read", "write") {
+		t.Fatalf("failed to get right associations for target:  read/write")
+	}
+
+	if _, err := g.TargetAssociations("123"); err == nil {
+		t.Fatalf("able to get association for target id: %s", "123")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	g := testGraph(t)
+
+	g.CreatePolicyClass("pc", nil)
+	g.CreateNode("oa1", gg.OA, gg.ToProperties(gg.PropertyPair{"namespace", "test"}), "pc")
+	g.CreateNode("oa2", gg.OA, gg.ToProperties(gg.PropertyPair{"key1", "value1"}), "pc")
+	g.CreateNode("oa3", gg.OA, gg.ToProperties(gg.PropertyPair{"key1", "value1"}, gg.PropertyPair{"key2", "value2"}), "pc")
+
+	// name and type no properties
+	nodes := g.Search(gg.OA, nil)
+	if nodes.Len() != 3 {
+		t.Fatalf("incorrect length after search: %d", nodes.Len())
+	}
+
+	// one property
+	nodes = g.Search(-1, gg.ToProperties(gg.PropertyPair{"key1", "value1"}))
+	if nodes.Len() != 2 {
+		t.Fatalf("incorrect length after search: %d", nodes.Len())
+	}
+
+	// just namespace
+	nodes = g.Search(-1, gg.ToProperties(gg.PropertyPair{"namespace", "test"}))
+
+	if nodes.Len() != 1 {
+		t.Fatalf("incorrect length after search: %d", nodes.Len())
+	}
+
+	// name, type, namespace
+	nodes = g.Search(gg.OA, gg.ToProperties(gg.PropertyPair{"namespace", "test"}))
+
+	if nodes.Len() != 1 {
+		t.Fatalf("incorrect length after search: %d", nodes.Len())
+	}
+
+	nodes = g.Search(gg.OA, gg.ToProperties(gg.PropertyPair{"namespace", "test"}))
+	if nodes.Len() != 1 {
+		t.Fatalf("incorrect length after search: %d", nodes.Len())
+	}
+
+	nodes = g.Search(gg.OA, nil)
+	if nodes.Len() != 3 {
+		t.Fatalf("incorrect length after search: %d", nodes.Len())
+	}
+	nodes = g.Search(gg.OA, gg.ToProperties(gg.PropertyPair{"key1", "value1"}))
+	if nodes.Len() != 2 {
+		t.Fatalf("incorrect length after search: %d", nodes.Len())
+	}
+	nodes = g.Search(-1, nil)
+	if nodes.Len() != 4 {
+		t.Fatalf("incorrect length after search: %d", nodes.Len())
+	}
+}
+
+func TestNodes(t *testing.T) {
+	g := testGraph(t)
+
+	g.CreatePolicyClass("pc", nil)
+	g.CreateNode("node1", gg.OA, nil, "pc")
+	g.CreateNode("node2", gg.OA, nil, "pc")
+	g.CreateNode("node3", gg.OA, nil, "pc")
+	// name and type no properties
+
+	if g.Nodes().Len() != 4 {
+		t.Fatalf("incorrect length : %d", g.Nodes().Len())
+	}
+}
+
+func TestNode(t *testing.T) {
+	g := testGraph(t)
+	_, err := g.Node("123")
+
+	if err == nil {
+		t.Fatalf("no node expected")
+	}
+
+	node, _ := g.CreatePolicyClass("pc", nil)
+
+	// name and type no properties
+	n, _ := g.Node(node.Name)
+	if n.Name != "pc" {
+		t.Fatalf("incorrect node name")
+	}
+
+	if n.Type != gg.PC {
+		t.Fatalf("incorrect node type")
+	}
+}
+
+func TestCreateNodeRollback(t *testing.T) {
+	g := testGraph(t)
+
+	g.CreatePolicyClass("pc", nil)
+	if _, err := g.CreateNode("oa", gg.OA, nil, "pc", "missing"); err == nil {
+		t.Fatalf("should not create a node assigned to a non existing node")
+	}
+
+	if g.Exists("oa") {
+		t.Fatalf("node should not exist after a failed creation")
+	}
+
+	if g.Children("pc").Contains("oa") {
+		t.Fatalf("assignment should not exist after a failed creation")
+	}
+}
+
+func TestValidation(t *testing.T) {
+	g := testGraph(t)
+
+	g.CreatePolicyClass("pc", nil)
+	g.CreateNode("oa", gg.OA, nil, "pc")
+	g.CreateNode("ua", gg.UA, nil, "pc")
+	g.CreateNode("o", gg.O, nil, "oa")
+
+	if err := g.Assign("ua", "oa"); err == nil {
+		t.Fatalf("should not assign a UA to an OA")
+	}
+
+	if err := g.Assign("o", "oa"); err == nil {
+		t.Fatalf("should not assign a node twice to the same parent")
+	}
+
+	if err := g.Associate("oa", "ua", operations.NewOperationSet("read")); err == nil {
+		t.Fatalf("should not associate an OA to a UA")
+	}
+}
+
+func TestRemoveNodeEdges(t *testing.T) {
+	g := testGraph(t)
+
+	g.CreatePolicyClass("pc", nil)
+	g.CreateNode("oa", gg.OA, nil, "pc")
+	g.CreateNode("ua", gg.UA, nil, "pc")
+	g.CreateNode("o", gg.O, nil, "oa")
+	g.Associate("ua", "oa", operations.NewOperationSet("read"))
+
+	g.RemoveNode("oa")
+
+	if g.Children("pc").Contains("oa") {
+		t.Fatalf("pc should not have oa as a child after deleting it")
+	}
+
+	if g.Parents("o").Contains("oa") {
+		t.Fatalf("o should not have oa as a parent after deleting it")
+	}
+
+	associations, err := g.SourceAssociations("ua")
+	if err != nil {
+		t.Fatalf("error thrown at getting source associations")
+	}
+
+	if len(associations) != 0 {
+		t.Fatalf("ua should not have associations after deleting oa")
+	}
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.db")
+	g, err := New(path)
+	if err != nil {
+		t.Fatalf("failed to open graph: %s", err)
+	}
+
+	g.CreatePolicyClass("pc", gg.ToProperties(gg.PropertyPair{"key", "value"}))
+	g.CreateNode("oa", gg.OA, nil, "pc")
+	g.CreateNode("ua", gg.UA, nil, "pc")
+	g.Associate("ua", "oa", operations.NewOperationSet("read", "write"))
+	g.(*graph).Close()
+
+	g, err = New(path)
+	if err != nil {
+		t.Fatalf("failed to reopen graph: %s", err)
+	}
+	defer g.(*graph).Close()
+
+	if !g.PolicyClasses().Contains("pc") {
+		t.Fatalf("failed to lookup policy class after reopening")
+	}
+
+	pc, _ := g.Node("pc")
+	if pc.Properties["key"] != "value" {
+		t.Fatalf("failed to lookup properties after reopening")
+	}
+
+	if !g.Children("pc").Contains("oa", "ua") {
+		t.Fatalf("failed to lookup children after reopening")
+	}
+
+	associations, err := g.SourceAssociations("ua")
+	if err != nil {
+		t.Fatalf("error thrown at getting source associations")
+	}
+
+	if !associations["oa"].Contains("read", "write") {
+		t.Fatalf("failed to get right associations after reopening:  read/write")
+	}
+}
+
+func TestAssignCycle(t *testing.T) {
+	g := testGraph(t)
+	g.CreatePolicyClass("pc", nil)
+	g.CreateNode("oa1", gg.OA, nil, "pc")
+	g.CreateNode("oa2", gg.OA, nil, "oa1")
+
+	err := g.Assign("oa1", "oa2")
+	cycleErr, ok := err.(*gg.CycleError)
+	if !ok {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if cycleErr.Error() != "assigning oa1 to oa2 would create the cycle oa1 -> oa2 -> oa1" {
+		t.Errorf("unexpected error %s", cycleErr)
+	}
+	if g.IsAssigned("oa1", "oa2") {
+		t.Errorf("expected the assignment to be rejected")
+	}
+}
@@ -119,3 +119,21 @@ type Graph interface {
 	 */
 	TargetAssociations(target string) (map[string]operations.OperationSet, error)
 }
+
+// A graph that can give a consistent view of itself to long reads, like the traversals of a decision.
+type Snapshotter interface {
+	/**
+	 * Get a read-only copy of the graph as it is now.  The changes made to the graph afterwards don't show in the
+	 * snapshot, and reading the snapshot doesn't block them.
+	 */
+	Snapshot() Graph
+}
+
+// Snapshot returns a snapshot of the graph if it implements Snapshotter, the graph itself otherwise.
+func Snapshot(g Graph) Graph {
+	if s, ok := g.(Snapshotter); ok {
+		return s.Snapshot()
+	}
+
+	return g
+}
@@ -0,0 +1,153 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The kinds of problems found by Validate.
+const (
+	UNREACHABLE_NODE              = "unreachable_node"
+	MISSING_POLICY_CLASS_PROPERTY = "missing_policy_class_property"
+	INVALID_ASSIGNMENT            = "invalid_assignment"
+	INVALID_ASSOCIATION           = "invalid_association"
+
+	SEVERITY_ERROR   = "error"
+	SEVERITY_WARNING = "warning"
+)
+
+// the properties GraphAdmin.CreatePolicyClass sets on a policy class
+var policyClassProperties = []string{REP_PROPERTY, "default_ua", "default_oa"}
+
+// Problem is a structural problem found in the policy.
+type Problem struct {
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	// the node, prohibition or obligation with the problem
+	Element string `json:"element"`
+	// the other nodes involved, like the parent of an invalid assignment
+	Nodes []string `json:"nodes,omitempty"`
+	// where the obligation names the node, as a JSON pointer
+	Pointer string `json:"pointer,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s %s: %s", p.Severity, p.Kind, p.Message)
+}
+
+// Report lists the problems found in the policy, sorted by kind and element.
+type Report struct {
+	Problems []*Problem `json:"problems"`
+}
+
+func NewReport() *Report {
+	return &Report{Problems: make([]*Problem, 0)}
+}
+
+// Add adds an error to the report.
+func (r *Report) Add(kind, element string, nodes []string, format string, a ...interface{}) *Problem {
+	problem := &Problem{Kind: kind, Severity: SEVERITY_ERROR, Element: element, Nodes: nodes, Message: fmt.Sprintf(format, a...)}
+	r.Problems = append(r.Problems, problem)
+	return problem
+}
+
+// OK tells if the report has no errors, warnings aside.
+func (r *Report) OK() bool {
+	for _, problem := range r.Problems {
+		if problem.Severity == SEVERITY_ERROR {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Sort sorts the problems by kind, element and nodes.
+func (r *Report) Sort() {
+	sort.SliceStable(r.Problems, func(i, j int) bool {
+		a, b := r.Problems[i], r.Problems[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Element != b.Element {
+			return a.Element < b.Element
+		}
+		return strings.Join(a.Nodes, ",") < strings.Join(b.Nodes, ",")
+	})
+}
+
+func (r *Report) String() string {
+	problems := make([]string, len(r.Problems))
+	for i, problem := range r.Problems {
+		problems[i] = problem.String()
+	}
+
+	return strings.Join(problems, "\n")
+}
+
+// Validate checks the structure of the graph, typically after an import or a migration. It reports the nodes that
+// don't reach any policy class, the policy classes missing the rep, default_ua or default_oa property, and the
+// assignments and associations between nodes of types that can't be related that way.
+func Validate(g Graph) *Report {
+	report := NewReport()
+
+	// the nodes reaching a policy class are the descendants of the policy classes
+	reached := make(map[string]bool)
+	queue := make([]string, 0)
+	for pc := range g.PolicyClasses().Iter() {
+		reached[pc.(string)] = true
+		queue = append(queue, pc.(string))
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for child := range g.Children(name).Iter() {
+			if !reached[child.(string)] {
+				reached[child.(string)] = true
+				queue = append(queue, child.(string))
+			}
+		}
+	}
+
+	for n := range g.Nodes().Iter() {
+		node := n.(*Node)
+		if !reached[node.Name] {
+			report.Add(UNREACHABLE_NODE, node.Name, nil, "%s doesn't reach any policy class", node.Name)
+		}
+
+		if node.Type == PC {
+			for _, property := range policyClassProperties {
+				if _, ok := node.Properties[property]; !ok {
+					report.Add(MISSING_POLICY_CLASS_PROPERTY, node.Name, nil, "policy class %s has no %s property", node.Name, property)
+				}
+			}
+		}
+
+		for parent := range g.Parents(node.Name).Iter() {
+			p, err := g.Node(parent.(string))
+			if err != nil {
+				report.Add(INVALID_ASSIGNMENT, node.Name, []string{parent.(string)}, "%s is assigned to %s: %s", node.Name, parent, err)
+			} else if err := CheckAssignment(node.Type, p.Type); err != nil {
+				report.Add(INVALID_ASSIGNMENT, node.Name, []string{p.Name}, "%s is assigned to %s: %s", node.Name, p.Name, err)
+			}
+		}
+
+		associations, err := g.SourceAssociations(node.Name)
+		if err != nil {
+			continue
+		}
+		for target := range associations {
+			t, err := g.Node(target)
+			if err != nil {
+				report.Add(INVALID_ASSOCIATION, node.Name, []string{target}, "%s is associated with %s: %s", node.Name, target, err)
+			} else if err := CheckAssociation(node.Type, t.Type); err != nil {
+				report.Add(INVALID_ASSOCIATION, node.Name, []string{target}, "%s is associated with %s: %s", node.Name, target, err)
+			}
+		}
+	}
+
+	report.Sort()
+	return report
+}
@@ -5,47 +5,243 @@ import (
 	"github.com/jtejido/ngac/internal/set"
 	"github.com/jtejido/ngac/pkg/operations"
 	g "github.com/jtejido/ngac/pkg/pip/graph"
+	"log"
+	"sync"
 )
 
-var _ g.Graph = &graph{}
+var (
+	_ g.Graph       = &graph{}
+	_ g.Snapshotter = &graph{}
+	_ g.Querier     = &graph{}
+)
 
 const (
 	node_not_found_msg = "node %s does not exist in the graph"
+	read_only_msg      = "the graph snapshot is read-only"
+
+	// the nodes are spread over the shards so a write after a snapshot only copies the shards it touches
+	shard_count = 64
 )
 
-// This is an in-memory dag implementation.
+// names of nodes, the graph lock guards them
+type names map[string]struct{}
+
+// shard holds the nodes hashed to it, with their outgoing (from) and incoming (to) edges and the indexes of their
+// properties. A shard seen by a snapshot is never changed again, the graph copies it before its next write.
+type shard struct {
+	gen     uint64 // generation of the graph the shard was copied for
+	nodes   map[string]*g.Node
+	from    map[string]map[string]g.Edge
+	to      map[string]map[string]g.Edge
+	indexes map[string]map[string]names // property key -> value -> names of the nodes
+}
+
+func newShard(gen uint64, keys []string) *shard {
+	s := &shard{
+		gen:     gen,
+		nodes:   make(map[string]*g.Node),
+		from:    make(map[string]map[string]g.Edge),
+		to:      make(map[string]map[string]g.Edge),
+		indexes: make(map[string]map[string]names, len(keys)),
+	}
+	for _, key := range keys {
+		s.indexes[key] = make(map[string]names)
+	}
+
+	return s
+}
+
+func (s *shard) clone(gen uint64) *shard {
+	c := &shard{
+		gen:     gen,
+		nodes:   make(map[string]*g.Node, len(s.nodes)),
+		from:    make(map[string]map[string]g.Edge, len(s.from)),
+		to:      make(map[string]map[string]g.Edge, len(s.to)),
+		indexes: make(map[string]map[string]names, len(s.indexes)),
+	}
+	for name, node := range s.nodes {
+		c.nodes[name] = node
+	}
+	for name, edges := range s.from {
+		c.from[name] = cloneEdges(edges)
+	}
+	for name, edges := range s.to {
+		c.to[name] = cloneEdges(edges)
+	}
+	for key, values := range s.indexes {
+		c.indexes[key] = make(map[string]names, len(values))
+		for value, ns := range values {
+			c.indexes[key][value] = make(names, len(ns))
+			for name := range ns {
+				c.indexes[key][value][name] = struct{}{}
+			}
+		}
+	}
+
+	return c
+}
+
+// index adds the node to the indexes of the properties it has.
+func (s *shard) index(n *g.Node) {
+	for key, values := range s.indexes {
+		value, ok := n.Properties[key]
+		if !ok {
+			continue
+		}
+		ns, ok := values[value]
+		if !ok {
+			ns = make(names)
+			values[value] = ns
+		}
+		ns[n.Name] = struct{}{}
+	}
+}
+
+// unindex removes the node from the indexes, n must have the properties it was indexed with.
+func (s *shard) unindex(n *g.Node) {
+	for key, values := range s.indexes {
+		value, ok := n.Properties[key]
+		if !ok {
+			continue
+		}
+		if ns, ok := values[value]; ok {
+			delete(ns, n.Name)
+			if len(ns) == 0 {
+				delete(values, value)
+			}
+		}
+	}
+}
+
+// candidates returns the names of the nodes of the shard having the indexed property that narrows the search down the
+// most, or false if none of the properties is indexed.
+func (s *shard) candidates(properties g.PropertyMap) (names, bool) {
+	var candidates names
+	for key, value := range properties {
+		values, ok := s.indexes[key]
+		if !ok {
+			continue
+		}
+		ns, ok := values[value]
+		if !ok {
+			return names{}, true
+		}
+		if candidates == nil || len(ns) < len(candidates) {
+			candidates = ns
+		}
+	}
+
+	return candidates, candidates != nil
+}
+
+// cloneNode returns a copy of the node with its own properties, changing them doesn't change the graph or its snapshots.
+func cloneNode(n *g.Node) *g.Node {
+	properties := make(g.PropertyMap, len(n.Properties))
+	for k, v := range n.Properties {
+		properties[k] = v
+	}
+
+	return &g.Node{Name: n.Name, Type: n.Type, Properties: properties}
+}
+
+func cloneEdges(edges map[string]g.Edge) map[string]g.Edge {
+	c := make(map[string]g.Edge, len(edges))
+	for k, e := range edges {
+		c[k] = e
+	}
+	return c
+}
+
+// This is an in-memory dag implementation, safe for concurrent use. The writers are serialized and the reads of the
+// graph wait for the current write, while Snapshot returns a read-only copy that is read without locking the graph.
+// Taking a snapshot is cheap, the shards of the graph are copied on the first write to each of them afterwards.
 type graph struct {
-	nodes map[string]*g.Node // contains all nodes
-	from  map[string]map[string]g.Edge
-	to    map[string]map[string]g.Edge
-	pcs   set.Set // contains all policies
+	sync.RWMutex
+	gen      uint64 // incremented by each snapshot
+	shards   [shard_count]*shard
+	pcs      set.Set // contains all policies
+	readOnly bool
 }
 
 func New() g.Graph {
-	return &graph{
-		nodes: make(map[string]*g.Node),
-		from:  make(map[string]map[string]g.Edge),
-		to:    make(map[string]map[string]g.Edge),
-		pcs:   set.NewSet(),
+	return NewWithIndexes()
+}
+
+// NewWithIndexes returns a graph indexing the nodes by the values of the given property keys (e.g. namespace), so
+// Search and NodeFromDetails only look at the nodes having the searched value of an indexed property instead of scanning
+// the whole graph. The indexes are kept up to date by CreateNode, UpdateNode and RemoveNode, which store a copy of the
+// given properties.
+func NewWithIndexes(keys ...string) g.Graph {
+	mg := &graph{pcs: set.NewSet()}
+	for i := range mg.shards {
+		mg.shards[i] = newShard(0, keys)
+	}
+
+	return mg
+}
+
+// Snapshot returns a read-only copy of the graph as it is now, the later writes to the graph don't change it.
+func (mg *graph) Snapshot() g.Graph {
+	if mg.readOnly {
+		return mg
+	}
+
+	mg.Lock()
+	defer mg.Unlock()
+	snapshot := &graph{gen: mg.gen, shards: mg.shards, pcs: mg.pcs.Clone(), readOnly: true}
+	mg.gen++
+
+	return snapshot
+}
+
+func shardIndex(name string) int {
+	// fnv-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619
 	}
+
+	return int(h % shard_count)
+}
+
+func (mg *graph) shard(name string) *shard {
+	return mg.shards[shardIndex(name)]
+}
+
+// writable returns the shard of the node, copied first if a snapshot may still see it. The write lock must be held.
+func (mg *graph) writable(name string) *shard {
+	i := shardIndex(name)
+	if mg.shards[i].gen != mg.gen {
+		mg.shards[i] = mg.shards[i].clone(mg.gen)
+	}
+
+	return mg.shards[i]
 }
 
 func (mg *graph) addNode(n *g.Node) {
-	if _, exists := mg.nodes[n.Name]; exists {
+	if mg.node(n.Name) != nil {
 		panic(fmt.Sprintf("simple: node collision: %s", n.Name))
 	}
 
-	mg.nodes[n.Name] = n
-	mg.from[n.Name] = make(map[string]g.Edge)
-	mg.to[n.Name] = make(map[string]g.Edge)
+	s := mg.writable(n.Name)
+	s.nodes[n.Name] = n
+	s.from[n.Name] = make(map[string]g.Edge)
+	s.to[n.Name] = make(map[string]g.Edge)
+	s.index(n)
 
 }
 
 func (mg *graph) node(name string) (n *g.Node) {
-	n, _ = mg.nodes[name]
+	n, _ = mg.shard(name).nodes[name]
 	return
 }
 
+func (mg *graph) exists(name string) bool {
+	_, exists := mg.shard(name).nodes[name]
+	return exists
+}
+
 func (mg *graph) setEdge(e g.Edge) error {
 	var (
 		sid = e.From()
@@ -55,51 +251,49 @@ func (mg *graph) setEdge(e g.Edge) error {
 	if sid == tid {
 		return fmt.Errorf("adding self edge")
 	}
-	var found1, found2 bool
-	_, found1 = mg.nodes[sid]
-	_, found2 = mg.nodes[tid]
-	if !found1 {
+	if !mg.exists(sid) {
 		return fmt.Errorf("source vertex not in the g.")
 	}
 
-	if !found2 {
+	if !mg.exists(tid) {
 		return fmt.Errorf("target vertex not in the g.")
 	}
 
-	mg.from[sid][tid] = e
-	mg.to[tid][sid] = e
+	mg.writable(sid).from[sid][tid] = e
+	mg.writable(tid).to[tid][sid] = e
 
 	return nil
 }
 
 func (mg *graph) removeNode(name string) {
-	var found bool
-	_, found = mg.nodes[name]
-	if !found {
+	if !mg.exists(name) {
 		return
 	}
 
-	delete(mg.nodes, name)
+	s := mg.writable(name)
+	s.unindex(s.nodes[name])
+	delete(s.nodes, name)
 
-	for from := range mg.from[name] {
-		delete(mg.to[from], name)
+	for to := range s.from[name] {
+		delete(mg.writable(to).to[to], name)
 	}
-	delete(mg.from, name)
+	delete(s.from, name)
 
-	for to := range mg.to[name] {
-		delete(mg.from[to], name)
+	for from := range s.to[name] {
+		delete(mg.writable(from).from[from], name)
 	}
-	delete(mg.to, name)
+	delete(s.to, name)
 
 }
 
 func (mg *graph) incomingEdgesOf(name string) []g.Edge {
 	var edges []g.Edge
-	if _, ok := mg.to[name]; !ok {
+	to, ok := mg.shard(name).to[name]
+	if !ok {
 		return []g.Edge{}
 	}
 
-	for _, edge := range mg.to[name] {
+	for _, edge := range to {
 		edges = append(edges, edge)
 	}
 	if len(edges) == 0 {
@@ -111,11 +305,12 @@ func (mg *graph) incomingEdgesOf(name string) []g.Edge {
 
 func (mg *graph) outgoingEdgesOf(name string) []g.Edge {
 	var edges []g.Edge
-	if _, ok := mg.from[name]; !ok {
+	from, ok := mg.shard(name).from[name]
+	if !ok {
 		return []g.Edge{}
 	}
 
-	for _, edge := range mg.from[name] {
+	for _, edge := range from {
 		edges = append(edges, edge)
 	}
 
@@ -126,31 +321,52 @@ func (mg *graph) outgoingEdgesOf(name string) []g.Edge {
 	return edges
 }
 
+// parents returns the names of the nodes the node is assigned to.
+func (mg *graph) parents(name string) []string {
+	parents := make([]string, 0)
+	for _, rel := range mg.outgoingEdgesOf(name) {
+		if _, ok := rel.(*g.Assignment); ok {
+			parents = append(parents, rel.To())
+		}
+	}
+
+	return parents
+}
+
 func (mg *graph) hasEdgeFromTo(u, v string) bool {
 	var found bool
-	_, found = mg.from[u][v]
+	_, found = mg.shard(u).from[u][v]
 
 	return found
 }
 
 func (mg *graph) removeEdge(fid, tid string) error {
-	if _, ok := mg.nodes[fid]; !ok {
+	if mg.readOnly {
+		return fmt.Errorf(read_only_msg)
+	}
+	if !mg.exists(fid) {
 		return fmt.Errorf("source vertex not in the g.")
 	}
-	if _, ok := mg.nodes[tid]; !ok {
+	if !mg.exists(tid) {
 		return fmt.Errorf("target vertex not in the g.")
 	}
 
-	delete(mg.from[fid], tid)
-	delete(mg.to[tid], fid)
+	delete(mg.writable(fid).from[fid], tid)
+	delete(mg.writable(tid).to[tid], fid)
 
 	return nil
 }
 
 func (mg *graph) CreatePolicyClass(name string, properties g.PropertyMap) (*g.Node, error) {
+	if mg.readOnly {
+		return nil, fmt.Errorf(read_only_msg)
+	}
+
+	mg.Lock()
+	defer mg.Unlock()
 	if len(name) == 0 {
 		return nil, fmt.Errorf("no name was provided when creating a node in the in-memory graph")
-	} else if mg.Exists(name) {
+	} else if mg.exists(name) {
 		return nil, fmt.Errorf("the name %s already exists in the graph", name)
 	}
 
@@ -162,20 +378,27 @@ func (mg *graph) CreatePolicyClass(name string, properties g.PropertyMap) (*g.No
 		properties = g.NewPropertyMap()
 	}
 
-	node := &g.Node{name, g.PC, properties}
+	// the graph keeps its own copy of the properties, the caller's map may change without updating the indexes
+	node := cloneNode(&g.Node{Name: name, Type: g.PC, Properties: properties})
 	mg.addNode(node)
 
-	return node, nil
+	return cloneNode(node), nil
 }
 
 func (mg *graph) CreateNode(name string, t g.NodeType, properties g.PropertyMap, initialParent string, additionalParents ...string) (*g.Node, error) {
+	if mg.readOnly {
+		return nil, fmt.Errorf(read_only_msg)
+	}
+
+	mg.Lock()
+	defer mg.Unlock()
 	//check for null values
 
 	if t == g.PC {
 		return nil, fmt.Errorf("use CreatePolicyClass to create a policy class node")
 	} else if len(name) == 0 {
 		return nil, fmt.Errorf("no name was provided when creating a node in the in-memory graph")
-	} else if mg.Exists(name) {
+	} else if mg.exists(name) {
 		return nil, fmt.Errorf("the name %s already exists in the graph", name)
 	}
 
@@ -184,42 +407,57 @@ func (mg *graph) CreateNode(name string, t g.NodeType, properties g.PropertyMap,
 		properties = g.NewPropertyMap()
 	}
 
-	node := &g.Node{name, t, properties}
+	node := cloneNode(&g.Node{Name: name, Type: t, Properties: properties})
 
 	mg.addNode(node)
 
 	// assign the new node the to given parent nodes
-	if err := mg.Assign(name, initialParent); err != nil {
+	if err := mg.assign(name, initialParent); err != nil {
 		return nil, err
 	}
 
 	for _, parent := range additionalParents {
-		if err := mg.Assign(name, parent); err != nil {
+		if err := mg.assign(name, parent); err != nil {
 			return nil, err
 		}
 	}
 	//return the Node
-	return node, nil
+	return cloneNode(node), nil
 }
 
 func (mg *graph) UpdateNode(name string, properties g.PropertyMap) error {
-	n, exists := mg.nodes[name]
-	if !exists {
+	if mg.readOnly {
+		return fmt.Errorf(read_only_msg)
+	}
+
+	mg.Lock()
+	defer mg.Unlock()
+	n := mg.node(name)
+	if n == nil {
 		return fmt.Errorf("node with the name %s could not be found to update", name)
 	}
 
-	// update the properties
+	// update the properties, on a copy of the node the snapshots may still see
 	if properties != nil {
-		n.Properties = properties
+		s := mg.writable(name)
+		updated := cloneNode(&g.Node{Name: n.Name, Type: n.Type, Properties: properties}) // don't change the stored edges
+		s.unindex(n)
+		s.nodes[name] = updated
+		s.index(updated)
 	}
 
-	mg.nodes[name] = n // don't change the stored edges
 	return nil
 }
 
 func (mg *graph) RemoveNode(name string) {
-	_, exists := mg.nodes[name]
-	if !exists {
+	if mg.readOnly {
+		log.Println(read_only_msg)
+		return
+	}
+
+	mg.Lock()
+	defer mg.Unlock()
+	if !mg.exists(name) {
 		return
 	}
 
@@ -231,30 +469,39 @@ func (mg *graph) RemoveNode(name string) {
 }
 
 func (mg *graph) Exists(name string) bool {
-	_, exists := mg.nodes[name]
-	return exists
+	mg.RLock()
+	defer mg.RUnlock()
+	return mg.exists(name)
 }
 
 func (mg *graph) PolicyClasses() set.Set {
-	return mg.pcs
+	mg.RLock()
+	defer mg.RUnlock()
+	return mg.pcs.Clone()
 }
 
 func (mg *graph) Nodes() set.Set {
+	mg.RLock()
+	defer mg.RUnlock()
 	s := set.NewSet()
-	for _, v := range mg.nodes {
-		s.Add(v)
+	for _, shard := range mg.shards {
+		for _, v := range shard.nodes {
+			s.Add(cloneNode(v))
+		}
 	}
 
 	return s
 }
 
 func (mg *graph) Node(name string) (*g.Node, error) {
+	mg.RLock()
+	defer mg.RUnlock()
 	node := mg.node(name)
 	if node == nil {
 		return nil, fmt.Errorf("a node with the name %s does not exist", name)
 	}
 
-	return node, nil
+	return cloneNode(node), nil
 }
 
 func (mg *graph) NodeFromDetails(t g.NodeType, properties g.PropertyMap) (*g.Node, error) {
@@ -267,34 +514,74 @@ func (mg *graph) NodeFromDetails(t g.NodeType, properties g.PropertyMap) (*g.Nod
 }
 
 func (mg *graph) Search(t g.NodeType, properties g.PropertyMap) set.Set {
+	mg.RLock()
+	defer mg.RUnlock()
 	if properties == nil {
 		properties = g.NewPropertyMap()
 	}
 
-	results := set.NewSet()
-	// iterate over the nodes to find ones that match the search parameters
-	for _, node := range mg.nodes {
+	match := func(node *g.Node) bool {
 		if node.Type != t && t != g.NOOP {
-			continue
+			return false
 		}
 
-		match := true
 		for k, v := range properties {
 			if node.Properties[k] != v {
-				match = false
+				return false
 			}
 		}
 
-		if match {
-			results.Add(node)
+		return true
+	}
+
+	results := set.NewSet()
+	for _, shard := range mg.shards {
+		// only check the nodes having the value of an indexed property
+		if candidates, ok := shard.candidates(properties); ok {
+			for name := range candidates {
+				if node, found := shard.nodes[name]; found && match(node) {
+					results.Add(cloneNode(node))
+				}
+			}
+			continue
+		}
+
+		// iterate over the nodes to find ones that match the search parameters
+		for _, node := range shard.nodes {
+			if match(node) {
+				results.Add(cloneNode(node))
+			}
 		}
 	}
 
 	return results
 }
 
+func (mg *graph) Query(q *g.Query) ([]*g.Node, error) {
+	match, err := q.Matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	mg.RLock()
+	results := make([]*g.Node, 0)
+	for _, shard := range mg.shards {
+		for _, node := range shard.nodes {
+			if match(node) {
+				results = append(results, cloneNode(node))
+			}
+		}
+	}
+	mg.RUnlock()
+
+	q.Sort(results)
+	return q.Page(results), nil
+}
+
 func (mg *graph) Children(name string) set.Set {
-	if !mg.Exists(name) {
+	mg.RLock()
+	defer mg.RUnlock()
+	if !mg.exists(name) {
 		panic(fmt.Errorf(node_not_found_msg, name))
 	}
 
@@ -311,7 +598,9 @@ func (mg *graph) Children(name string) set.Set {
 }
 
 func (mg *graph) Parents(name string) set.Set {
-	if !mg.Exists(name) {
+	mg.RLock()
+	defer mg.RUnlock()
+	if !mg.exists(name) {
 		panic(fmt.Errorf(node_not_found_msg, name))
 	}
 
@@ -328,9 +617,19 @@ func (mg *graph) Parents(name string) set.Set {
 }
 
 func (mg *graph) Assign(child, parent string) error {
-	if !mg.Exists(child) {
+	if mg.readOnly {
+		return fmt.Errorf(read_only_msg)
+	}
+
+	mg.Lock()
+	defer mg.Unlock()
+	return mg.assign(child, parent)
+}
+
+func (mg *graph) assign(child, parent string) error {
+	if !mg.exists(child) {
 		return fmt.Errorf(node_not_found_msg, child)
-	} else if !mg.Exists(parent) {
+	} else if !mg.exists(parent) {
 		return fmt.Errorf(node_not_found_msg, parent)
 	}
 
@@ -344,6 +643,9 @@ func (mg *graph) Assign(child, parent string) error {
 	if err := g.CheckAssignment(c.Type, p.Type); err != nil {
 		return err
 	}
+	if err := g.CheckCycle(child, parent, mg.parents); err != nil {
+		return err
+	}
 
 	a := new(g.Assignment)
 	a.Source = child
@@ -353,17 +655,27 @@ func (mg *graph) Assign(child, parent string) error {
 }
 
 func (mg *graph) Deassign(child, parent string) error {
+	mg.Lock()
+	defer mg.Unlock()
 	return mg.removeEdge(child, parent)
 }
 
 func (mg *graph) IsAssigned(child, parent string) bool {
+	mg.RLock()
+	defer mg.RUnlock()
 	return mg.hasEdgeFromTo(child, parent)
 }
 
 func (mg *graph) Associate(ua, target string, ops operations.OperationSet) error {
-	if !mg.Exists(ua) {
+	if mg.readOnly {
+		return fmt.Errorf(read_only_msg)
+	}
+
+	mg.Lock()
+	defer mg.Unlock()
+	if !mg.exists(ua) {
 		return fmt.Errorf(node_not_found_msg, ua)
-	} else if !mg.Exists(target) {
+	} else if !mg.exists(target) {
 		return fmt.Errorf(node_not_found_msg, target)
 	}
 
@@ -377,34 +689,25 @@ func (mg *graph) Associate(ua, target string, ops operations.OperationSet) error
 
 	// if no edge exists create an association
 	// if an assignment exists create a new edge for the association
-	// if an association exists update it
-	edge, found := mg.from[ua][target]
-	var isAssign bool
-	if found {
-		_, isAssign = edge.(*g.Assignment)
-	}
-
-	if !found || isAssign {
-		e := new(g.Association)
-		e.Source = ua
-		e.Target = target
-		e.Operations = ops
-		if err := mg.setEdge(e); err != nil {
-			return err
-		}
-	} else if assoc, ok := edge.(*g.Association); ok {
-		assoc.Operations = ops
-	}
+	// if an association exists replace it, the snapshots may still see the old one
+	e := new(g.Association)
+	e.Source = ua
+	e.Target = target
+	e.Operations = ops
 
-	return nil
+	return mg.setEdge(e)
 }
 
 func (mg *graph) Dissociate(ua, target string) error {
+	mg.Lock()
+	defer mg.Unlock()
 	return mg.removeEdge(ua, target)
 }
 
 func (mg *graph) SourceAssociations(source string) (map[string]operations.OperationSet, error) {
-	if !mg.Exists(source) {
+	mg.RLock()
+	defer mg.RUnlock()
+	if !mg.exists(source) {
 		return nil, fmt.Errorf(node_not_found_msg, source)
 	}
 
@@ -418,7 +721,9 @@ func (mg *graph) SourceAssociations(source string) (map[string]operations.Operat
 }
 
 func (mg *graph) TargetAssociations(target string) (map[string]operations.OperationSet, error) {
-	if !mg.Exists(target) {
+	mg.RLock()
+	defer mg.RUnlock()
+	if !mg.exists(target) {
 		return nil, fmt.Errorf(node_not_found_msg, target)
 	}
 
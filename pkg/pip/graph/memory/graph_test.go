@@ -1,8 +1,11 @@
 package memory
 
 import (
+	"fmt"
 	"github.com/jtejido/ngac/pkg/operations"
 	gg "github.com/jtejido/ngac/pkg/pip/graph"
+	"sort"
+	"sync"
 	"testing"
 )
 
@@ -383,3 +386,332 @@ func TestNode(t *testing.T) {
 		t.Fatalf("incorrect node type")
 	}
 }
+
+func TestSnapshot(t *testing.T) {
+	g := New()
+	pc, _ := g.CreatePolicyClass("pc", nil)
+	g.CreateNode("oa", gg.OA, gg.ToProperties(gg.PropertyPair{"k", "v"}), pc.Name)
+	g.CreateNode("ua", gg.UA, nil, pc.Name)
+	g.Associate("ua", "oa", operations.NewOperationSet("read"))
+
+	snapshot := g.(gg.Snapshotter).Snapshot()
+
+	g.CreateNode("o", gg.O, nil, "oa")
+	g.UpdateNode("oa", gg.ToProperties(gg.PropertyPair{"k", "v2"}))
+	g.Associate("ua", "oa", operations.NewOperationSet("read", "write"))
+	g.RemoveNode("ua")
+	g.CreatePolicyClass("pc2", nil)
+
+	if snapshot.Exists("o") || snapshot.Children("oa").Len() != 0 {
+		t.Errorf("expected the snapshot not to see the new node")
+	}
+	if node, _ := snapshot.Node("oa"); node.Properties["k"] != "v" {
+		t.Errorf("expected the snapshot to keep the old properties, got %v", node.Properties)
+	}
+	if assocs, err := snapshot.SourceAssociations("ua"); err != nil || !assocs["oa"].Contains("read") || assocs["oa"].Contains("write") {
+		t.Errorf("expected the snapshot to keep the old association, got %v %v", assocs, err)
+	}
+	if snapshot.PolicyClasses().Len() != 1 {
+		t.Errorf("expected the snapshot to have 1 policy class")
+	}
+
+	if !g.Exists("o") || g.Exists("ua") || g.PolicyClasses().Len() != 2 {
+		t.Errorf("expected the graph to see its changes")
+	}
+	if _, err := snapshot.CreateNode("o2", gg.O, nil, "oa"); err == nil {
+		t.Errorf("expected the snapshot to be read-only")
+	}
+	if err := snapshot.Assign("oa", "pc"); err == nil {
+		t.Errorf("expected the snapshot to be read-only")
+	}
+
+	// the nodes returned by the graph don't share their properties with the snapshots
+	latest := g.(gg.Snapshotter).Snapshot()
+	node, _ := g.Node("oa")
+	node.Properties["k"] = "v3"
+	if node, _ := latest.Node("oa"); node.Properties["k"] != "v2" {
+		t.Errorf("expected the snapshot to keep the old properties, got %v", node.Properties)
+	}
+	if node, _ := g.Node("oa"); node.Properties["k"] != "v2" {
+		t.Errorf("expected the graph to change only on UpdateNode, got %v", node.Properties)
+	}
+
+	// RemoveNode can't return the error, it leaves the snapshot as it is
+	snapshot.RemoveNode("oa")
+	if !snapshot.Exists("oa") {
+		t.Errorf("expected the snapshot to keep the node")
+	}
+}
+
+func TestConcurrentSnapshots(t *testing.T) {
+	g := New()
+	pc, _ := g.CreatePolicyClass("pc", nil)
+	g.CreateNode("oa", gg.OA, nil, pc.Name)
+
+	const writes = 200
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < writes; i++ {
+			name := fmt.Sprintf("o%d", i)
+			g.CreateNode(name, gg.O, nil, "oa")
+			g.UpdateNode(name, gg.ToProperties(gg.PropertyPair{"i", name}))
+			if i%2 == 0 {
+				g.RemoveNode(name)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				// every child of oa in a snapshot exists in it, with its parent
+				snapshot := g.(gg.Snapshotter).Snapshot()
+				for child := range snapshot.Children("oa").Iter() {
+					if !snapshot.Parents(child.(string)).Contains("oa") {
+						t.Errorf("inconsistent snapshot: %s", child)
+						return
+					}
+				}
+				snapshot.Search(gg.O, nil)
+				g.Exists("o1")
+			}
+		}()
+	}
+
+	wg.Wait()
+	if n := g.Children("oa").Len(); n != writes/2 {
+		t.Errorf("expected %d children, got %d", writes/2, n)
+	}
+}
+
+func TestAssignCycle(t *testing.T) {
+	g := New()
+	g.CreatePolicyClass("pc", nil)
+	g.CreateNode("oa1", gg.OA, nil, "pc")
+	g.CreateNode("oa2", gg.OA, nil, "oa1")
+	g.CreateNode("oa3", gg.OA, nil, "oa2")
+
+	err := g.Assign("oa1", "oa3")
+	cycleErr, ok := err.(*gg.CycleError)
+	if !ok {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if path := fmt.Sprint(cycleErr.Path); path != "[oa1 oa3 oa2 oa1]" {
+		t.Errorf("unexpected path %s", path)
+	}
+	if g.IsAssigned("oa1", "oa3") {
+		t.Errorf("expected the assignment to be rejected")
+	}
+
+	if _, ok := g.Assign("oa2", "oa2").(*gg.CycleError); !ok {
+		t.Errorf("expected a self assignment to be rejected")
+	}
+
+	// associations aren't part of the dag
+	g.CreateNode("ua1", gg.UA, nil, "pc")
+	g.CreateNode("ua2", gg.UA, nil, "pc")
+	g.Associate("ua1", "ua2", operations.NewOperationSet("read"))
+	if err := g.Assign("ua2", "ua1"); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	g := New()
+
+	g.CreatePolicyClass("pc", nil)
+	g.CreateNode("oa1", gg.OA, gg.ToProperties(gg.PropertyPair{"env", "prod"}, gg.PropertyPair{"size", "10"}), "pc")
+	g.CreateNode("oa2", gg.OA, gg.ToProperties(gg.PropertyPair{"env", "dev"}, gg.PropertyPair{"size", "2"}), "pc")
+	g.CreateNode("oa3", gg.OA, gg.ToProperties(gg.PropertyPair{"env", "prod-eu"}, gg.PropertyPair{"size", "large"}), "pc")
+	g.CreateNode("ua1", gg.UA, gg.ToProperties(gg.PropertyPair{"env", "prod"}), "pc")
+	g.CreateNode("ua2", gg.UA, nil, "pc")
+
+	names := func(q *gg.Query) string {
+		nodes, err := gg.Find(g, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := make([]string, len(nodes))
+		for i, node := range nodes {
+			s[i] = node.Name
+		}
+		return fmt.Sprint(s)
+	}
+
+	tests := []struct {
+		q        *gg.Query
+		expected string
+	}{
+		{&gg.Query{}, "[oa1 oa2 oa3 pc ua1 ua2]"},
+		{&gg.Query{Types: []gg.NodeType{gg.OA, gg.UA}, Name: "?a*"}, "[oa1 oa2 oa3 ua1 ua2]"},
+		{&gg.Query{Name: "oa?"}, "[oa1 oa2 oa3]"},
+		{&gg.Query{Where: gg.Equals("env", "prod")}, "[oa1 ua1]"},
+		{&gg.Query{Where: gg.Or(gg.Equals("env", "dev"), gg.HasSuffix("env", "-eu"))}, "[oa2 oa3]"},
+		{&gg.Query{Where: gg.And(gg.HasPrefix("env", "prod"), gg.Not(gg.Equals("env", "prod")))}, "[oa3]"},
+		{&gg.Query{Types: []gg.NodeType{gg.UA}, Where: gg.Not(gg.HasKey("env"))}, "[ua2]"},
+		{&gg.Query{Where: gg.MatchesRegex("env", "prod|dev")}, "[oa1 oa2 ua1]"},
+		{&gg.Query{Where: gg.GreaterThan("size", 2)}, "[oa1]"},
+		{&gg.Query{Where: gg.AtLeast("size", 2)}, "[oa1 oa2]"},
+		{&gg.Query{Where: gg.LessThan("size", 10)}, "[oa2]"},
+		{&gg.Query{Where: gg.AtMost("size", 10)}, "[oa1 oa2]"},
+		{&gg.Query{Types: []gg.NodeType{gg.OA}, OrderBy: "env"}, "[oa2 oa1 oa3]"},
+		{&gg.Query{Where: gg.HasKey("env"), OrderBy: "env", Desc: true}, "[oa3 oa1 ua1 oa2]"},
+		{&gg.Query{OrderBy: "size"}, "[oa1 oa2 oa3 pc ua1 ua2]"},
+		{&gg.Query{Desc: true, Limit: 2}, "[ua2 ua1]"},
+		{&gg.Query{Offset: 2, Limit: 2}, "[oa3 pc]"},
+		{&gg.Query{Offset: 10}, "[]"},
+	}
+	for _, test := range tests {
+		if actual := names(test.q); actual != test.expected {
+			t.Errorf("query %+v: expected %s, got %s", test.q, test.expected, actual)
+		}
+	}
+
+	invalid := []*gg.Query{
+		{Where: gg.MatchesRegex("env", "(")},
+		{Where: &gg.Condition{Op: "like", Key: "env"}},
+		{Where: gg.Equals("", "prod")},
+		{Where: &gg.Condition{Op: gg.NOT}},
+		{Limit: -1},
+	}
+	for _, q := range invalid {
+		if _, err := gg.Find(g, q); err == nil {
+			t.Errorf("query %+v should be invalid", q)
+		}
+	}
+}
+
+func TestIndexedSearch(t *testing.T) {
+	g := NewWithIndexes("namespace", "bucket")
+
+	g.CreatePolicyClass("pc", nil)
+	g.CreateNode("oa1", gg.OA, gg.ToProperties(gg.PropertyPair{"namespace", "ns1"}, gg.PropertyPair{"bucket", "b1"}), "pc")
+	g.CreateNode("o1", gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns1"}, gg.PropertyPair{"bucket", "b1"}), "oa1")
+	g.CreateNode("o2", gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns1"}, gg.PropertyPair{"bucket", "b2"}), "oa1")
+	g.CreateNode("o3", gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns2"}, gg.PropertyPair{"path", "/a"}), "oa1")
+
+	search := func(t gg.NodeType, properties gg.PropertyMap) string {
+		names := make([]string, 0)
+		for n := range g.Search(t, properties).Iter() {
+			names = append(names, n.(*gg.Node).Name)
+		}
+		sort.Strings(names)
+		return fmt.Sprint(names)
+	}
+
+	tests := []struct {
+		t          gg.NodeType
+		properties gg.PropertyMap
+		expected   string
+	}{
+		{gg.NOOP, gg.ToProperties(gg.PropertyPair{"namespace", "ns1"}), "[o1 o2 oa1]"},
+		{gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns1"}), "[o1 o2]"},
+		{gg.NOOP, gg.ToProperties(gg.PropertyPair{"namespace", "ns1"}, gg.PropertyPair{"bucket", "b1"}), "[o1 oa1]"},
+		{gg.NOOP, gg.ToProperties(gg.PropertyPair{"namespace", "ns2"}, gg.PropertyPair{"path", "/a"}), "[o3]"},
+		{gg.NOOP, gg.ToProperties(gg.PropertyPair{"namespace", "ns3"}), "[]"},
+		{gg.NOOP, gg.ToProperties(gg.PropertyPair{"path", "/a"}), "[o3]"},
+	}
+	for _, test := range tests {
+		if actual := search(test.t, test.properties); actual != test.expected {
+			t.Errorf("search %v: expected %s, got %s", test.properties, test.expected, actual)
+		}
+	}
+
+	// the indexes follow the updates and removals, the snapshots keep seeing the nodes they had
+	snapshot := gg.Snapshot(g)
+	if err := g.UpdateNode("o1", gg.ToProperties(gg.PropertyPair{"namespace", "ns2"})); err != nil {
+		t.Fatal(err)
+	}
+	g.RemoveNode("o2")
+
+	if actual := search(gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns1"})); actual != "[]" {
+		t.Errorf("expected no object in ns1, got %s", actual)
+	}
+	if actual := search(gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns2"})); actual != "[o1 o3]" {
+		t.Errorf("expected o1 and o3 in ns2, got %s", actual)
+	}
+	if n, err := g.NodeFromDetails(gg.O, gg.ToProperties(gg.PropertyPair{"bucket", "b1"})); err == nil {
+		t.Errorf("expected no object in b1, got %s", n.Name)
+	}
+	if nodes := snapshot.Search(gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns1"})); nodes.Len() != 2 {
+		t.Errorf("expected the snapshot to find 2 objects in ns1, got %d", nodes.Len())
+	}
+
+	// a node created again with the name of a removed node is only found by its new properties
+	g.CreateNode("o2", gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns2"}), "oa1")
+	if actual := search(gg.O, gg.ToProperties(gg.PropertyPair{"bucket", "b2"})); actual != "[]" {
+		t.Errorf("expected no object in b2, got %s", actual)
+	}
+	if actual := search(gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns2"})); actual != "[o1 o2 o3]" {
+		t.Errorf("expected o1, o2 and o3 in ns2, got %s", actual)
+	}
+
+	// a node updated through the properties map it was created with is only indexed by its new value
+	properties := gg.ToProperties(gg.PropertyPair{"namespace", "ns4"})
+	g.CreateNode("o4", gg.O, properties, "oa1")
+	properties["namespace"] = "ns5"
+	if err := g.UpdateNode("o4", properties); err != nil {
+		t.Fatal(err)
+	}
+	if actual := search(gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns4"})); actual != "[]" {
+		t.Errorf("expected no object in ns4, got %s", actual)
+	}
+	if ns, ok := g.(*graph).shard("o4").indexes["namespace"]["ns4"]; ok {
+		t.Errorf("expected the index of ns4 to be removed, got %v", ns)
+	}
+	if actual := search(gg.O, gg.ToProperties(gg.PropertyPair{"namespace", "ns5"})); actual != "[o4]" {
+		t.Errorf("expected o4 in ns5, got %s", actual)
+	}
+}
+
+// BenchmarkSearch searches 100 objects among 100000 spread over 1000 namespaces, with and without an index.
+func BenchmarkSearch(b *testing.B) {
+	for _, indexes := range [][]string{nil, {"namespace"}} {
+		b.Run(fmt.Sprintf("indexes=%v", indexes), func(b *testing.B) {
+			g := NewWithIndexes(indexes...)
+			g.CreatePolicyClass("pc", nil)
+			g.CreateNode("oa", gg.OA, nil, "pc")
+			for i := 0; i < 100000; i++ {
+				g.CreateNode(fmt.Sprintf("o%d", i), gg.O, gg.ToProperties(gg.PropertyPair{"namespace", fmt.Sprintf("ns%d", i%1000)}), "oa")
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if nodes := g.Search(gg.O, gg.ToProperties(gg.PropertyPair{"namespace", fmt.Sprintf("ns%d", i%1000)})); nodes.Len() != 100 {
+					b.Fatalf("expected 100 nodes, got %d", nodes.Len())
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkNodeFromDetails looks up an object by its unique path among 100000, with and without an index.
+func BenchmarkNodeFromDetails(b *testing.B) {
+	for _, indexes := range [][]string{nil, {"path"}} {
+		b.Run(fmt.Sprintf("indexes=%v", indexes), func(b *testing.B) {
+			g := NewWithIndexes(indexes...)
+			g.CreatePolicyClass("pc", nil)
+			g.CreateNode("oa", gg.OA, nil, "pc")
+			for i := 0; i < 100000; i++ {
+				g.CreateNode(fmt.Sprintf("o%d", i), gg.O, gg.ToProperties(gg.PropertyPair{"path", fmt.Sprintf("/o%d", i)}), "oa")
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := g.NodeFromDetails(gg.O, gg.ToProperties(gg.PropertyPair{"path", fmt.Sprintf("/o%d", i%100000)})); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
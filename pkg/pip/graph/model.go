@@ -224,3 +224,53 @@ func CheckAssignment(childType, parentType NodeType) error {
 
 	return nil
 }
+
+// CycleError is returned when an assignment would make the graph cyclic.
+type CycleError struct {
+	Child, Parent string
+	// the cycle the assignment would close, from the child through the parent back to the child
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("assigning %s to %s would create the cycle %s", e.Child, e.Parent, strings.Join(e.Path, " -> "))
+}
+
+// CheckCycle returns a *CycleError if assigning the child to the parent would close a cycle, that is if the child can
+// already be reached from the parent by following the assignments. parents returns the names of the nodes a node is
+// assigned to.
+func CheckCycle(child, parent string, parents func(name string) []string) error {
+	if child == parent {
+		return &CycleError{child, parent, []string{child, child}}
+	}
+
+	// breadth first, so the shortest cycle is reported
+	prev := map[string]string{parent: ""}
+	queue := []string{parent}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, p := range parents(node) {
+			if _, seen := prev[p]; seen {
+				continue
+			}
+			prev[p] = node
+
+			if p == child {
+				path := []string{child}
+				for n := node; n != ""; n = prev[n] {
+					path = append(path, n)
+				}
+				path = append(path, child)
+				// the path was built from the end, the child is at both ends
+				for i, j := 1, len(path)-2; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return &CycleError{child, parent, path}
+			}
+			queue = append(queue, p)
+		}
+	}
+
+	return nil
+}
@@ -273,7 +273,8 @@ func (ng *graph) Nodes() set.Set {
 	})
 
 	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-		records, err := tx.Run("MATCH (n) return n.name, n.type, apoc.convert.getJsonPropertyMap(n, 'properties') as properties", nil)
+		// only match graph nodes, other stores (e.g. prohibitions) may share the same database
+		records, err := tx.Run("MATCH (n) WHERE n:PC OR n:UA OR n:OA OR n:U OR n:O return n.name, n.type, apoc.convert.getJsonPropertyMap(n, 'properties') as properties", nil)
 		nodes := set.NewSet()
 		for records.Next() {
 			n := &g.Node{
@@ -520,6 +521,9 @@ func (ng *graph) Assign(child, parent string) error {
 	if err := g.CheckAssignment(c.Type, p.Type); err != nil {
 		return err
 	}
+	if child == parent {
+		return &g.CycleError{Child: child, Parent: parent, Path: []string{child, child}}
+	}
 
 	session := ng.driver.NewSession(neo4j.SessionConfig{
 		AccessMode:   neo4j.AccessModeWrite,
@@ -527,7 +531,26 @@ func (ng *graph) Assign(child, parent string) error {
 	})
 
 	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-		_, err := tx.Run("MATCH (a {name:$child}), (b {name:$parent}) MERGE (a)-[:ASSIGNED_TO]->(b)", map[string]interface{}{
+		// the assignment closes a cycle if the child can already be reached from the parent
+		records, err := tx.Run("MATCH p = shortestPath((a {name:$parent})-[:ASSIGNED_TO*]->(b {name:$child})) RETURN [n IN nodes(p) | n.name]", map[string]interface{}{
+			"child":  child,
+			"parent": parent,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if records.Next() {
+			path := []string{child}
+			for _, name := range records.Record().Values[0].([]interface{}) {
+				path = append(path, name.(string))
+			}
+			return nil, &g.CycleError{Child: child, Parent: parent, Path: path}
+		}
+		if err = records.Err(); err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Run("MATCH (a {name:$child}), (b {name:$parent}) MERGE (a)-[:ASSIGNED_TO]->(b)", map[string]interface{}{
 			"child":  child,
 			"parent": parent,
 		})
@@ -0,0 +1,168 @@
+package neo4j
+
+import (
+	"fmt"
+	g "github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"strings"
+)
+
+var _ g.Querier = &graph{}
+
+// Query compiles the query to Cypher, the properties stored as JSON are read with apoc.
+func (ng *graph) Query(q *g.Query) ([]*g.Node, error) {
+	cypher, params, err := compileQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	session := ng.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeRead,
+		DatabaseName: ng.config.Database,
+	})
+	defer session.Close()
+
+	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		records, err := tx.Run(cypher, params)
+		if err != nil {
+			return nil, err
+		}
+
+		nodes := make([]*g.Node, 0)
+		for records.Next() {
+			n := &g.Node{
+				Name:       records.Record().Values[0].(string),
+				Type:       g.ToNodeType(records.Record().Values[1].(string)),
+				Properties: g.NewPropertyMap(),
+			}
+			if m, ok := records.Record().Values[2].(map[string]interface{}); ok {
+				for key, value := range m {
+					v, ok := value.(string)
+					if !ok {
+						return nil, fmt.Errorf("Illegal type for property value found")
+					}
+					n.Properties[key] = v
+				}
+			}
+			nodes = append(nodes, n)
+		}
+
+		if err = records.Err(); err != nil {
+			return nil, err
+		}
+
+		return nodes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*g.Node), nil
+}
+
+// compileQuery returns the Cypher statement running the query and its parameters.
+func compileQuery(q *g.Query) (string, map[string]interface{}, error) {
+	// the query is checked the same way as in the other graphs
+	if _, err := q.Matcher(); err != nil {
+		return "", nil, err
+	}
+
+	params := make(map[string]interface{})
+	var b strings.Builder
+	// only match graph nodes, other stores (e.g. prohibitions) may share the same database
+	b.WriteString("MATCH (n) WHERE (n:PC OR n:UA OR n:OA OR n:U OR n:O)")
+	if len(q.Types) > 0 {
+		types := make([]string, len(q.Types))
+		for i, t := range q.Types {
+			types[i] = t.String()
+		}
+		params["types"] = types
+		b.WriteString(" AND n.type IN $types")
+	}
+	if len(q.Name) > 0 {
+		params["name"] = q.NameRegex()
+		b.WriteString(" AND n.name =~ $name")
+	}
+
+	b.WriteString(" WITH n, apoc.convert.getJsonPropertyMap(n, 'properties') AS p")
+	if q.Where != nil {
+		b.WriteString(" WHERE ")
+		b.WriteString(compileCondition(q.Where, params, new(int)))
+	}
+	b.WriteString(" RETURN n.name, n.type, p")
+
+	desc := ""
+	if q.Desc {
+		desc = " DESC"
+	}
+	if len(q.OrderBy) > 0 {
+		params["orderBy"] = q.OrderBy
+		b.WriteString(" ORDER BY p[$orderBy] IS NULL, p[$orderBy]" + desc + ", n.name")
+	} else {
+		b.WriteString(" ORDER BY n.name" + desc)
+	}
+
+	if q.Offset > 0 {
+		params["offset"] = q.Offset
+		b.WriteString(" SKIP $offset")
+	}
+	if q.Limit > 0 {
+		params["limit"] = q.Limit
+		b.WriteString(" LIMIT $limit")
+	}
+
+	return b.String(), params, nil
+}
+
+// compileCondition returns the Cypher expression of the condition on the properties p. A missing property or a value
+// that isn't a number makes the test false rather than null, so a negated test matches them as in the other graphs.
+func compileCondition(c *g.Condition, params map[string]interface{}, n *int) string {
+	switch c.Op {
+	case g.AND, g.OR:
+		if len(c.Conditions) == 0 {
+			return fmt.Sprint(c.Op == g.AND)
+		}
+		expressions := make([]string, len(c.Conditions))
+		for i, condition := range c.Conditions {
+			expressions[i] = compileCondition(condition, params, n)
+		}
+		return "(" + strings.Join(expressions, " "+strings.ToUpper(c.Op)+" ") + ")"
+	case g.NOT:
+		return "NOT " + compileCondition(c.Conditions[0], params, n)
+	}
+
+	key, value := fmt.Sprintf("k%d", *n), fmt.Sprintf("v%d", *n)
+	*n++
+	params[key] = c.Key
+
+	var test string
+	switch c.Op {
+	case g.EQUALS:
+		test = "p[$%s] = $%s"
+	case g.PREFIX:
+		test = "p[$%s] STARTS WITH $%s"
+	case g.SUFFIX:
+		test = "p[$%s] ENDS WITH $%s"
+	case g.REGEX:
+		test = "p[$%s] =~ $%s"
+	case g.EXISTS:
+		return fmt.Sprintf("p[$%s] IS NOT NULL", key)
+	case g.LESS:
+		test = "toFloat(p[$%s]) < $%s"
+	case g.LESS_EQ:
+		test = "toFloat(p[$%s]) <= $%s"
+	case g.GREATER:
+		test = "toFloat(p[$%s]) > $%s"
+	case g.GREATER_EQ:
+		test = "toFloat(p[$%s]) >= $%s"
+	}
+
+	switch c.Op {
+	case g.LESS, g.LESS_EQ, g.GREATER, g.GREATER_EQ:
+		params[value] = c.Number
+	default:
+		params[value] = c.Value
+	}
+
+	return "coalesce(" + fmt.Sprintf(test, key, value) + ", false)"
+}
@@ -0,0 +1,52 @@
+package neo4j
+
+import (
+	g "github.com/jtejido/ngac/pkg/pip/graph"
+	"reflect"
+	"testing"
+)
+
+func TestCompileQuery(t *testing.T) {
+	q := &g.Query{
+		Types: []g.NodeType{g.O, g.OA},
+		Name:  "report-*.pdf",
+		Where: g.And(
+			g.Or(g.HasPrefix("path", "/finance"), g.MatchesRegex("owner", "a.*")),
+			g.Not(g.Equals("status", "archived")),
+			g.AtLeast("size", 10),
+			g.HasKey("owner"),
+		),
+		OrderBy: "size",
+		Desc:    true,
+		Offset:  20,
+		Limit:   10,
+	}
+
+	cypher, params, err := compileQuery(q)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := "MATCH (n) WHERE (n:PC OR n:UA OR n:OA OR n:U OR n:O) AND n.type IN $types AND n.name =~ $name" +
+		" WITH n, apoc.convert.getJsonPropertyMap(n, 'properties') AS p" +
+		" WHERE ((coalesce(p[$k0] STARTS WITH $v0, false) OR coalesce(p[$k1] =~ $v1, false))" +
+		" AND NOT coalesce(p[$k2] = $v2, false) AND coalesce(toFloat(p[$k3]) >= $v3, false) AND p[$k4] IS NOT NULL)" +
+		" RETURN n.name, n.type, p ORDER BY p[$orderBy] IS NULL, p[$orderBy] DESC, n.name SKIP $offset LIMIT $limit"
+	if cypher != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, cypher)
+	}
+
+	expectedParams := map[string]interface{}{
+		"types": []string{"O", "OA"}, "name": `^report-.*\.pdf$`,
+		"k0": "path", "v0": "/finance", "k1": "owner", "v1": "a.*", "k2": "status", "v2": "archived",
+		"k3": "size", "v3": float64(10), "k4": "owner",
+		"orderBy": "size", "offset": 20, "limit": 10,
+	}
+	if !reflect.DeepEqual(params, expectedParams) {
+		t.Errorf("expected %v, got %v", expectedParams, params)
+	}
+
+	if _, _, err := compileQuery(&g.Query{Where: g.MatchesRegex("k", "(")}); err == nil {
+		t.Errorf("expected an invalid regular expression to be rejected")
+	}
+}
@@ -0,0 +1,313 @@
+package graph
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The operators of a query condition.
+const (
+	AND        = "and"
+	OR         = "or"
+	NOT        = "not"
+	EQUALS     = "equals"
+	PREFIX     = "prefix"
+	SUFFIX     = "suffix"
+	REGEX      = "regex"
+	EXISTS     = "exists"
+	LESS       = "less"
+	LESS_EQ    = "less_eq"
+	GREATER    = "greater"
+	GREATER_EQ = "greater_eq"
+)
+
+// Condition is a condition on the properties of a node. And, Or and Not combine the conditions, the others test the
+// value of a property. The numeric comparisons parse the value as a number and don't match a value that isn't one.
+type Condition struct {
+	Op         string       `json:"op"`
+	Key        string       `json:"key,omitempty"`
+	Value      string       `json:"value,omitempty"`
+	Number     float64      `json:"number,omitempty"`
+	Conditions []*Condition `json:"conditions,omitempty"`
+}
+
+func And(conditions ...*Condition) *Condition {
+	return &Condition{Op: AND, Conditions: conditions}
+}
+
+func Or(conditions ...*Condition) *Condition {
+	return &Condition{Op: OR, Conditions: conditions}
+}
+
+func Not(condition *Condition) *Condition {
+	return &Condition{Op: NOT, Conditions: []*Condition{condition}}
+}
+
+func Equals(key, value string) *Condition {
+	return &Condition{Op: EQUALS, Key: key, Value: value}
+}
+
+func HasPrefix(key, prefix string) *Condition {
+	return &Condition{Op: PREFIX, Key: key, Value: prefix}
+}
+
+func HasSuffix(key, suffix string) *Condition {
+	return &Condition{Op: SUFFIX, Key: key, Value: suffix}
+}
+
+// MatchesRegex matches the values the regular expression matches in full.
+func MatchesRegex(key, expr string) *Condition {
+	return &Condition{Op: REGEX, Key: key, Value: expr}
+}
+
+func HasKey(key string) *Condition {
+	return &Condition{Op: EXISTS, Key: key}
+}
+
+func LessThan(key string, n float64) *Condition {
+	return &Condition{Op: LESS, Key: key, Number: n}
+}
+
+func AtMost(key string, n float64) *Condition {
+	return &Condition{Op: LESS_EQ, Key: key, Number: n}
+}
+
+func GreaterThan(key string, n float64) *Condition {
+	return &Condition{Op: GREATER, Key: key, Number: n}
+}
+
+func AtLeast(key string, n float64) *Condition {
+	return &Condition{Op: GREATER_EQ, Key: key, Number: n}
+}
+
+// matcher compiles the condition to a function matching the properties of a node.
+func (c *Condition) matcher() (func(PropertyMap) bool, error) {
+	switch c.Op {
+	case AND, OR:
+		matchers := make([]func(PropertyMap) bool, len(c.Conditions))
+		for i, condition := range c.Conditions {
+			m, err := condition.matcher()
+			if err != nil {
+				return nil, err
+			}
+			matchers[i] = m
+		}
+		and := c.Op == AND
+		return func(properties PropertyMap) bool {
+			for _, m := range matchers {
+				if m(properties) != and {
+					return !and
+				}
+			}
+			return and
+		}, nil
+	case NOT:
+		if len(c.Conditions) != 1 {
+			return nil, fmt.Errorf("not takes one condition but has %d", len(c.Conditions))
+		}
+		m, err := c.Conditions[0].matcher()
+		if err != nil {
+			return nil, err
+		}
+		return func(properties PropertyMap) bool { return !m(properties) }, nil
+	}
+
+	if len(c.Key) == 0 {
+		return nil, fmt.Errorf("no property key provided for the %s condition", c.Op)
+	}
+
+	var test func(string) bool
+	switch c.Op {
+	case EQUALS:
+		test = func(v string) bool { return v == c.Value }
+	case PREFIX:
+		test = func(v string) bool { return strings.HasPrefix(v, c.Value) }
+	case SUFFIX:
+		test = func(v string) bool { return strings.HasSuffix(v, c.Value) }
+	case REGEX:
+		re, err := regexp.Compile("^(?:" + c.Value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", c.Value, err)
+		}
+		test = re.MatchString
+	case EXISTS:
+		test = func(string) bool { return true }
+	case LESS, LESS_EQ, GREATER, GREATER_EQ:
+		op, n := c.Op, c.Number
+		test = func(v string) bool {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return false
+			}
+			switch op {
+			case LESS:
+				return f < n
+			case LESS_EQ:
+				return f <= n
+			case GREATER:
+				return f > n
+			}
+			return f >= n
+		}
+	default:
+		return nil, fmt.Errorf("unknown condition operator %q", c.Op)
+	}
+
+	key := c.Key
+	return func(properties PropertyMap) bool {
+		v, ok := properties[key]
+		return ok && test(v)
+	}, nil
+}
+
+// Query selects nodes by type, name and properties, sorted and a page at a time.
+type Query struct {
+	// the types of the nodes, any type when empty
+	Types []NodeType `json:"types,omitempty"`
+	// a pattern the name must match, "*" matching any characters and "?" a single one. Any name when empty.
+	Name string `json:"name,omitempty"`
+	// the condition on the properties, any properties when nil
+	Where *Condition `json:"where,omitempty"`
+	// the property to sort by, the name when empty. The nodes without the property come last, the ties are sorted by
+	// name.
+	OrderBy string `json:"orderBy,omitempty"`
+	Desc    bool   `json:"desc,omitempty"`
+	// the number of nodes to skip
+	Offset int `json:"offset,omitempty"`
+	// the maximum number of nodes, no limit when 0
+	Limit int `json:"limit,omitempty"`
+}
+
+// NameRegex returns the regular expression of the name pattern, matching the whole name.
+func (q *Query) NameRegex() string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range q.Name {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+
+	return b.String()
+}
+
+// Matcher compiles the query to a function matching the nodes it selects, an error is returned if the query is invalid.
+func (q *Query) Matcher() (func(*Node) bool, error) {
+	if q.Offset < 0 || q.Limit < 0 {
+		return nil, fmt.Errorf("the offset and limit of a query can't be negative")
+	}
+
+	types := make(map[NodeType]bool)
+	for _, t := range q.Types {
+		if t == NOOP {
+			return nil, fmt.Errorf("invalid node type in the query")
+		}
+		types[t] = true
+	}
+
+	var name *regexp.Regexp
+	if len(q.Name) > 0 {
+		name = regexp.MustCompile(q.NameRegex())
+	}
+
+	where := func(PropertyMap) bool { return true }
+	if q.Where != nil {
+		var err error
+		if where, err = q.Where.matcher(); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(node *Node) bool {
+		if len(types) > 0 && !types[node.Type] {
+			return false
+		}
+		if name != nil && !name.MatchString(node.Name) {
+			return false
+		}
+		return where(node.Properties)
+	}, nil
+}
+
+// Sort sorts the nodes in the order of the query.
+func (q *Query) Sort(nodes []*Node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if len(q.OrderBy) > 0 {
+			va, oka := a.Properties[q.OrderBy]
+			vb, okb := b.Properties[q.OrderBy]
+			if oka != okb {
+				return oka
+			}
+			if va != vb {
+				return (va < vb) != q.Desc
+			}
+		} else if a.Name != b.Name {
+			return (a.Name < b.Name) != q.Desc
+		}
+
+		return a.Name < b.Name
+	})
+}
+
+// Page returns the page of the sorted nodes given by the offset and limit of the query.
+func (q *Query) Page(nodes []*Node) []*Node {
+	if q.Offset >= len(nodes) {
+		return []*Node{}
+	}
+	nodes = nodes[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(nodes) {
+		nodes = nodes[:q.Limit]
+	}
+
+	return nodes
+}
+
+// Apply selects the nodes matching the query, sorted and paged.
+func (q *Query) Apply(nodes []*Node) ([]*Node, error) {
+	match, err := q.Matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*Node, 0)
+	for _, node := range nodes {
+		if match(node) {
+			results = append(results, node)
+		}
+	}
+	q.Sort(results)
+
+	return q.Page(results), nil
+}
+
+// A graph that runs queries natively.
+type Querier interface {
+	/**
+	 * Find the nodes matching the query, in the order of the query and paged by its offset and limit.
+	 */
+	Query(q *Query) ([]*Node, error)
+}
+
+// Find runs the query on the graph if it implements Querier, otherwise the query is applied to all the nodes.
+func Find(g Graph, q *Query) ([]*Node, error) {
+	if querier, ok := g.(Querier); ok {
+		return querier.Query(q)
+	}
+
+	nodes := make([]*Node, 0)
+	for n := range g.Nodes().Iter() {
+		nodes = append(nodes, n.(*Node))
+	}
+
+	return q.Apply(nodes)
+}
@@ -11,6 +11,7 @@ type Action interface {
 	SetCondition(condition *Condition)
 	NegatedCondition() *NegatedCondition
 	SetNegatedCondition(*NegatedCondition)
+	MarshalJSON() ([]byte, error)
 	UnmarshalJSON(b []byte) error
 }
 
@@ -39,6 +40,119 @@ func (a *action) SetNegatedCondition(n *NegatedCondition) {
 	a.negatedCondition = n
 }
 
+// The schema keys the negated condition as "condition!", "not_condition" is still read for documents written by
+// earlier versions.
+var negatedConditionKeys = []string{"condition!", "not_condition"}
+
+// unmarshalConditions reads the conditions guarding an action.
+func (a *action) unmarshalConditions(raw map[string]interface{}) error {
+	if v, ok := raw["condition"]; ok {
+		a.condition = new(Condition)
+		if err := remarshal(v, a.condition); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range negatedConditionKeys {
+		if v, ok := raw[key]; ok {
+			a.negatedCondition = new(NegatedCondition)
+			if err := remarshal(v, a.negatedCondition); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+// remarshal decodes a value taken from a generic JSON document into u.
+func remarshal(v interface{}, u json.Unmarshaler) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return u.UnmarshalJSON(b)
+}
+
+// toStrings converts a JSON array of strings.
+func toStrings(v interface{}, what string) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list of %s", what)
+	}
+
+	ret := make([]string, len(list))
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a list of %s", what)
+		}
+		ret[i] = s
+	}
+
+	return ret, nil
+}
+
+// toLabels converts a JSON array whose items are either labels or objects holding a label.
+func toLabels(v interface{}, what string) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list of %s", what)
+	}
+
+	ret := make([]string, len(list))
+	for i, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			item = m["label"]
+		}
+
+		label, ok := item.(string)
+		if !ok || len(label) == 0 {
+			return nil, fmt.Errorf("no label provided for %s", what)
+		}
+		ret[i] = label
+	}
+
+	return ret, nil
+}
+
+// toEvrNode decodes a required node of an action.
+func toEvrNode(raw map[string]interface{}, key string) (*EvrNode, error) {
+	v, ok := raw[key]
+	if !ok || v == nil {
+		return nil, fmt.Errorf("no %s provided", key)
+	}
+
+	node := new(EvrNode)
+	if err := remarshal(v, node); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", key, err)
+	}
+
+	return node, nil
+}
+
+// toObject returns the object held under the action key.
+func toObject(raw map[string]interface{}, key string) (map[string]interface{}, error) {
+	v, ok := raw[key].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object for %s action", key)
+	}
+
+	return v, nil
+}
+
+// toList returns the list held under the given key.
+func toList(raw map[string]interface{}, key string) ([]interface{}, error) {
+	v, ok := raw[key].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list for %s", key)
+	}
+
+	return v, nil
+}
+
 // AssignAction.java
 type AssignAction struct {
 	action
@@ -52,10 +166,51 @@ func NewAssignAction() *AssignAction {
 }
 
 func (a *AssignAction) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	if err := a.unmarshalConditions(raw); err != nil {
+		return err
+	}
+
+	assignments, err := toAssignments(raw, "assign")
+	if err != nil {
+		return err
+	}
+	a.Assignments = assignments
 
 	return nil
 }
 
+// toAssignments decodes a list of {what, where} nodes.
+func toAssignments(raw map[string]interface{}, key string) ([]*ActionAssignment, error) {
+	list, err := toList(raw, key)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]*ActionAssignment, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an assignment in %s", key)
+		}
+
+		assignment := new(ActionAssignment)
+		if assignment.What, err = toEvrNode(m, "what"); err != nil {
+			return nil, err
+		}
+		if assignment.Where, err = toEvrNode(m, "where"); err != nil {
+			return nil, err
+		}
+		assignments[i] = assignment
+	}
+
+	return assignments, nil
+}
+
 type ActionAssignment struct {
 	What, Where *EvrNode
 }
@@ -76,36 +231,47 @@ func NewCreateAction() *CreateAction {
 
 func (a *CreateAction) UnmarshalJSON(b []byte) error {
 	var raw map[string]interface{}
-	json.Unmarshal(b, &raw)
-	if v, ok := raw["condition"]; ok {
-		a.condition = new(Condition)
-		b, err := json.Marshal(v.(interface{}))
-		if err != nil {
-			return err
-		}
-		err = a.condition.UnmarshalJSON(b)
-		if err != nil {
-			return err
-		}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
 	}
 
-	if v, ok := raw["not_condition"]; ok {
-		a.negatedCondition = new(NegatedCondition)
-		b, err := json.Marshal(v.(interface{}))
-		if err != nil {
-			return err
+	if err := a.unmarshalConditions(raw); err != nil {
+		return err
+	}
+
+	list, err := toList(raw, "create")
+	if err != nil {
+		return err
+	}
+
+	a.CreateNodesList = make([]*ActionCreateNode, 0)
+	a.Rules = make([]*Rule, 0)
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected a rule or a node to create")
 		}
-		err = a.negatedCondition.UnmarshalJSON(b)
-		if err != nil {
-			return err
+
+		// nodes are described by what and where, anything else is a rule
+		if _, ok := m["what"]; ok {
+			node := new(ActionCreateNode)
+			if node.What, err = toEvrNode(m, "what"); err != nil {
+				return err
+			}
+			if node.Where, err = toEvrNode(m, "where"); err != nil {
+				return err
+			}
+			a.CreateNodesList = append(a.CreateNodesList, node)
+		} else {
+			rule := new(Rule)
+			if err := remarshal(m, rule); err != nil {
+				return err
+			}
+			a.Rules = append(a.Rules, rule)
 		}
 	}
 
-	// if v, ok := raw["create"]; ok {
-	// 	// TO-DO
-	// }
-
-	return fmt.Errorf("invalid action received")
+	return nil
 }
 
 type ActionCreateNode struct {
@@ -121,6 +287,90 @@ type DeleteAction struct {
 }
 
 func (a *DeleteAction) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	if err := a.unmarshalConditions(raw); err != nil {
+		return err
+	}
+
+	del, err := toObject(raw, "delete")
+	if err != nil {
+		return err
+	}
+
+	if _, ok := del["nodes"]; ok {
+		list, err := toList(del, "nodes")
+		if err != nil {
+			return err
+		}
+
+		a.Nodes = make([]*EvrNode, len(list))
+		for i, item := range list {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("expected a node to delete")
+			}
+
+			// the schema describes the node under "what", the node itself is accepted as well
+			if _, ok := m["what"]; ok {
+				a.Nodes[i], err = toEvrNode(m, "what")
+			} else {
+				a.Nodes[i] = new(EvrNode)
+				err = remarshal(m, a.Nodes[i])
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, ok := del["assignments"]; ok {
+		assignments, err := toAssignments(del, "assignments")
+		if err != nil {
+			return err
+		}
+		a.Assignments = NewAssignAction()
+		a.Assignments.Assignments = assignments
+	}
+
+	if _, ok := del["associations"]; ok {
+		list, err := toList(del, "associations")
+		if err != nil {
+			return err
+		}
+
+		a.Associations = make([]*GrantAction, len(list))
+		for i, item := range list {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("expected an association to delete")
+			}
+
+			grant := NewGrantAction()
+			if grant.Subject, err = toEvrNode(m, "subject"); err != nil {
+				return err
+			}
+			if grant.Target, err = toEvrNode(m, "target"); err != nil {
+				return err
+			}
+			a.Associations[i] = grant
+		}
+	}
+
+	if v, ok := del["prohibitions"]; ok {
+		if a.Prohibitions, err = toLabels(v, "prohibitions"); err != nil {
+			return err
+		}
+	}
+
+	if v, ok := del["rules"]; ok {
+		if a.Rules, err = toLabels(v, "rules"); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -135,8 +385,45 @@ type DenyAction struct {
 }
 
 func (a *DenyAction) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
 
-	return nil
+	if err := a.unmarshalConditions(raw); err != nil {
+		return err
+	}
+
+	deny, err := toObject(raw, "deny")
+	if err != nil {
+		return err
+	}
+
+	if v, ok := deny["label"]; ok {
+		if a.Label, ok = v.(string); !ok {
+			return fmt.Errorf("deny label must be a string")
+		}
+	}
+
+	// the subject is a node, a function returning the subject or a process
+	if a.Subject, err = toEvrNode(deny, "subject"); err != nil {
+		return err
+	}
+
+	v, ok := deny["operations"]
+	if !ok {
+		return fmt.Errorf("no operations provided for deny")
+	}
+	if a.Operations, err = toStrings(v, "operations"); err != nil {
+		return err
+	}
+
+	target, ok := deny["target"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("no target provided for deny")
+	}
+	a.Target = new(ActionTarget)
+	return a.Target.unmarshal(target)
 }
 
 type ActionTarget struct {
@@ -144,6 +431,50 @@ type ActionTarget struct {
 	Containers               []*ActionContainer
 }
 
+func (t *ActionTarget) unmarshal(raw map[string]interface{}) (err error) {
+	if t.Complement, err = toBool(raw, "complement"); err != nil {
+		return
+	}
+	if t.Intersection, err = toBool(raw, "intersection"); err != nil {
+		return
+	}
+
+	list, err := toList(raw, "containers")
+	if err != nil {
+		return err
+	}
+
+	t.Containers = make([]*ActionContainer, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected a container")
+		}
+
+		t.Containers[i] = new(ActionContainer)
+		if err = t.Containers[i].unmarshal(m); err != nil {
+			return
+		}
+	}
+
+	return nil
+}
+
+// toBool reads an optional boolean.
+func toBool(raw map[string]interface{}, key string) (bool, error) {
+	v, ok := raw[key]
+	if !ok || v == nil {
+		return false, nil
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s must be a boolean", key)
+	}
+
+	return b, nil
+}
+
 type ActionContainer struct {
 	Name, Type string
 	Properties graph.PropertyMap
@@ -151,6 +482,27 @@ type ActionContainer struct {
 	Complement bool
 }
 
+func (c *ActionContainer) unmarshal(raw map[string]interface{}) (err error) {
+	if c.Complement, err = toBool(raw, "complement"); err != nil {
+		return
+	}
+
+	if v, ok := raw["function"]; ok {
+		c.Function = new(Function)
+		return remarshal(v, c.Function)
+	}
+
+	node := new(EvrNode)
+	if err = remarshal(raw, node); err != nil {
+		return
+	}
+	c.Name = node.Name
+	c.Type = node.Type
+	c.Properties = node.Properties
+
+	return nil
+}
+
 func NewActionContainerFromFunction(function *Function) *ActionContainer {
 	return &ActionContainer{Function: function}
 }
@@ -178,28 +530,8 @@ func NewFunctionAction(function *Function) *FunctionAction {
 func (a *FunctionAction) UnmarshalJSON(b []byte) error {
 	var raw map[string]interface{}
 	json.Unmarshal(b, &raw)
-	if v, ok := raw["condition"]; ok {
-		a.condition = new(Condition)
-		b, err := json.Marshal(v.(interface{}))
-		if err != nil {
-			return err
-		}
-		err = a.condition.UnmarshalJSON(b)
-		if err != nil {
-			return err
-		}
-	}
-
-	if v, ok := raw["not_condition"]; ok {
-		a.negatedCondition = new(NegatedCondition)
-		b, err := json.Marshal(v.(interface{}))
-		if err != nil {
-			return err
-		}
-		err = a.negatedCondition.UnmarshalJSON(b)
-		if err != nil {
-			return err
-		}
+	if err := a.unmarshalConditions(raw); err != nil {
+		return err
 	}
 
 	if v, ok := raw["function"]; ok {
@@ -233,6 +565,35 @@ func NewGrantAction() *GrantAction {
 }
 
 func (a *GrantAction) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	if err := a.unmarshalConditions(raw); err != nil {
+		return err
+	}
+
+	grant, err := toObject(raw, "grant")
+	if err != nil {
+		return err
+	}
+
+	if a.Subject, err = toEvrNode(grant, "subject"); err != nil {
+		return err
+	}
+
+	v, ok := grant["operations"]
+	if !ok {
+		return fmt.Errorf("no operations provided for grant")
+	}
+	if a.Operations, err = toStrings(v, "operations"); err != nil {
+		return err
+	}
+
+	if a.Target, err = toEvrNode(grant, "target"); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -28,9 +28,9 @@ func (f *Function) UnmarshalJSON(b []byte) error {
 			if s, ok := val.(string); ok {
 				args = append(args, NewArg(s))
 			} else if m, ok := val.(map[string]interface{}); ok {
-				if _, ok = m["function"]; ok {
+				if fn, ok := m["function"]; ok {
 					nf := new(Function)
-					b, err := json.Marshal(m)
+					b, err := json.Marshal(fn)
 					if err != nil {
 						return err
 					}
@@ -0,0 +1,366 @@
+package obligations
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// The MarshalJSON methods below write the obligation model back out in the same document
+// format that the UnmarshalJSON methods (and api/obligations.json) read.
+
+func (ob *Obligation) MarshalJSON() ([]byte, error) {
+	rules := ob.Rules
+	if rules == nil {
+		rules = make([]*Rule, 0)
+	}
+
+	raw := map[string]interface{}{
+		"label": ob.Label,
+		"rules": rules,
+	}
+
+	if ob.OnError != nil {
+		raw["onError"] = ob.OnError
+	}
+
+	return json.Marshal(raw)
+}
+
+func (r *Rule) MarshalJSON() ([]byte, error) {
+	raw := map[string]interface{}{
+		"label": r.Label,
+	}
+
+	if r.EventPattern != nil {
+		raw["event"] = r.EventPattern
+	}
+
+	if r.ResponsePattern != nil {
+		raw["response"] = r.ResponsePattern
+	}
+
+	if r.Disabled {
+		raw["disabled"] = true
+	}
+
+	if r.OnError != nil {
+		raw["onError"] = r.OnError
+	}
+
+	return json.Marshal(raw)
+}
+
+func (e *EventPattern) MarshalJSON() ([]byte, error) {
+	raw := make(map[string]interface{})
+	if e.Subject != nil {
+		raw["subject"] = e.Subject
+	}
+
+	if e.PolicyClass != nil {
+		raw["policyClass"] = e.PolicyClass
+	}
+
+	if e.Operations != nil {
+		raw["operations"] = e.Operations
+	}
+
+	if e.Target != nil {
+		raw["target"] = e.Target
+	}
+
+	if e.Schedule != nil {
+		raw["schedule"] = e.Schedule
+	}
+
+	return json.Marshal(raw)
+}
+
+func (s *Subject) MarshalJSON() ([]byte, error) {
+	if len(s.User) > 0 {
+		return json.Marshal(map[string]interface{}{"user": s.User})
+	} else if s.Process != nil {
+		return json.Marshal(map[string]interface{}{"process": marshalProcess(s.Process)})
+	}
+
+	anyUser := s.AnyUser
+	if anyUser == nil {
+		anyUser = make([]string, 0)
+	}
+
+	return json.Marshal(map[string]interface{}{"anyUser": anyUser})
+}
+
+func (pc *PolicyClass) MarshalJSON() ([]byte, error) {
+	if len(pc.EachOf) > 0 {
+		return json.Marshal(map[string]interface{}{"eachOf": pc.EachOf})
+	}
+
+	anyOf := pc.AnyOf
+	if anyOf == nil {
+		anyOf = make([]string, 0)
+	}
+
+	return json.Marshal(map[string]interface{}{"anyOf": anyOf})
+}
+
+func (t *Target) MarshalJSON() ([]byte, error) {
+	if t.Containers != nil {
+		return json.Marshal(map[string]interface{}{"containers": t.Containers})
+	} else if t.PolicyElements != nil {
+		return json.Marshal(map[string]interface{}{"policyElements": t.PolicyElements})
+	}
+
+	return json.Marshal(map[string]interface{}{})
+}
+
+func (evr *EvrNode) MarshalJSON() ([]byte, error) {
+	if evr.Function != nil {
+		return json.Marshal(map[string]interface{}{"function": evr.Function})
+	} else if evr.Process != nil {
+		return json.Marshal(map[string]interface{}{"process": marshalProcess(evr.Process)})
+	}
+
+	raw := map[string]interface{}{
+		"name": evr.Name,
+		"type": evr.Type,
+	}
+
+	if len(evr.Properties) > 0 {
+		raw["properties"] = propertiesToArgs(evr.Properties)
+	}
+
+	return json.Marshal(raw)
+}
+
+// marshalProcess writes a process as the integer the schema expects, falling back to the raw value.
+func marshalProcess(p *EvrProcess) interface{} {
+	if id, err := strconv.ParseInt(p.Value, 10, 64); err == nil {
+		return id
+	}
+
+	return p.Value
+}
+
+// propertiesToArgs converts a property map to the "key=value" list used by the obligation format. Keys are
+// sorted so the output is stable.
+func propertiesToArgs(properties map[string]string) []string {
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, len(keys))
+	for i, k := range keys {
+		args[i] = fmt.Sprintf("%s=%s", k, properties[k])
+	}
+
+	return args
+}
+
+func (r *ResponsePattern) MarshalJSON() ([]byte, error) {
+	raw := make(map[string]interface{})
+	if r.Condition != nil {
+		raw["condition"] = r.Condition
+	}
+
+	if r.NegatedCondition != nil {
+		raw["condition!"] = r.NegatedCondition
+	}
+
+	actions := r.Actions
+	if actions == nil {
+		actions = make([]Action, 0)
+	}
+	raw["actions"] = actions
+
+	return json.Marshal(raw)
+}
+
+func marshalConditionFunctions(functions []*Function) ([]byte, error) {
+	raw := make([]map[string]interface{}, len(functions))
+	for i, f := range functions {
+		raw[i] = map[string]interface{}{"function": f}
+	}
+
+	return json.Marshal(raw)
+}
+
+func (c *Condition) MarshalJSON() ([]byte, error) {
+	return marshalConditionFunctions(c.Condition)
+}
+
+func (c *NegatedCondition) MarshalJSON() ([]byte, error) {
+	return marshalConditionFunctions(c.Condition)
+}
+
+func (f *Function) MarshalJSON() ([]byte, error) {
+	args := make([]interface{}, len(f.Args))
+	for i, arg := range f.Args {
+		if arg.Function != nil {
+			args[i] = map[string]interface{}{"function": arg.Function}
+		} else {
+			args[i] = arg.Value
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"name": f.Name,
+		"args": args,
+	})
+}
+
+// marshalAction writes an action as a single object holding its conditions (if any) and the given action key.
+func marshalAction(a *action, key string, value interface{}) ([]byte, error) {
+	raw := map[string]interface{}{
+		key: value,
+	}
+
+	if a.condition != nil {
+		raw["condition"] = a.condition
+	}
+
+	if a.negatedCondition != nil {
+		raw["condition!"] = a.negatedCondition
+	}
+
+	return json.Marshal(raw)
+}
+
+func (aa *ActionAssignment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"what":  aa.What,
+		"where": aa.Where,
+	})
+}
+
+func (a *AssignAction) MarshalJSON() ([]byte, error) {
+	assignments := a.Assignments
+	if assignments == nil {
+		assignments = make([]*ActionAssignment, 0)
+	}
+
+	return marshalAction(&a.action, "assign", assignments)
+}
+
+func (cn *ActionCreateNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"what":  cn.What,
+		"where": cn.Where,
+	})
+}
+
+func (a *CreateAction) MarshalJSON() ([]byte, error) {
+	create := make([]interface{}, 0, len(a.Rules)+len(a.CreateNodesList))
+	for _, rule := range a.Rules {
+		create = append(create, rule)
+	}
+
+	for _, node := range a.CreateNodesList {
+		create = append(create, node)
+	}
+
+	return marshalAction(&a.action, "create", create)
+}
+
+func (a *DeleteAction) MarshalJSON() ([]byte, error) {
+	raw := make(map[string]interface{})
+	if a.Nodes != nil {
+		raw["nodes"] = a.Nodes
+	}
+
+	if a.Assignments != nil {
+		raw["assignments"] = a.Assignments.Assignments
+	}
+
+	if a.Associations != nil {
+		associations := make([]map[string]interface{}, len(a.Associations))
+		for i, grant := range a.Associations {
+			associations[i] = map[string]interface{}{
+				"subject": grant.Subject,
+				"target":  grant.Target,
+			}
+		}
+		raw["associations"] = associations
+	}
+
+	if a.Prohibitions != nil {
+		raw["prohibitions"] = a.Prohibitions
+	}
+
+	if a.Rules != nil {
+		raw["rules"] = a.Rules
+	}
+
+	return marshalAction(&a.action, "delete", raw)
+}
+
+func (c *ActionContainer) MarshalJSON() ([]byte, error) {
+	raw := map[string]interface{}{
+		"complement": c.Complement,
+	}
+
+	if c.Function != nil {
+		raw["function"] = c.Function
+	} else {
+		raw["name"] = c.Name
+		raw["type"] = c.Type
+		if len(c.Properties) > 0 {
+			raw["properties"] = propertiesToArgs(c.Properties)
+		}
+	}
+
+	return json.Marshal(raw)
+}
+
+func (t *ActionTarget) MarshalJSON() ([]byte, error) {
+	containers := t.Containers
+	if containers == nil {
+		containers = make([]*ActionContainer, 0)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"complement":   t.Complement,
+		"intersection": t.Intersection,
+		"containers":   containers,
+	})
+}
+
+func (a *DenyAction) MarshalJSON() ([]byte, error) {
+	raw := map[string]interface{}{
+		"label":      a.Label,
+		"operations": a.Operations,
+	}
+
+	if a.Subject != nil {
+		raw["subject"] = a.Subject
+	}
+
+	if a.Target != nil {
+		raw["target"] = a.Target
+	}
+
+	return marshalAction(&a.action, "deny", raw)
+}
+
+func (a *FunctionAction) MarshalJSON() ([]byte, error) {
+	return marshalAction(&a.action, "function", a.Function)
+}
+
+func (a *GrantAction) MarshalJSON() ([]byte, error) {
+	raw := map[string]interface{}{
+		"operations": a.Operations,
+	}
+
+	if a.Subject != nil {
+		raw["subject"] = a.Subject
+	}
+
+	if a.Target != nil {
+		raw["target"] = a.Target
+	}
+
+	return marshalAction(&a.action, "grant", raw)
+}
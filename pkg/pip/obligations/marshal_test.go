@@ -0,0 +1,232 @@
+package obligations
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"testing"
+)
+
+var fixtures = []string{
+	"../../../test/test_event.json",
+	"../../../test/test_event.yaml",
+	"../../../test/test_actions.json",
+	"../../../test/test_actions.yaml",
+}
+
+func TestMarshalJSONRoundTrip(t *testing.T) {
+	for _, file := range fixtures {
+		obligation, err := Parse("super", file)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		obligation.Source = ""
+
+		b, err := json.Marshal(obligation)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		parsed, err := ParseBytes("super", b)
+		if err != nil {
+			t.Fatalf("failed to parse %s after marshaling: %s", file, err)
+		}
+		if !reflect.DeepEqual(obligation, parsed) {
+			t.Errorf("%s changed after a json round trip", file)
+		}
+	}
+}
+
+func TestMarshalYAMLRoundTrip(t *testing.T) {
+	for _, file := range fixtures {
+		obligation, err := Parse("super", file)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		obligation.Source = ""
+
+		b, err := yaml.Marshal(obligation)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		parsed, err := ParseYAMLBytes("super", b)
+		if err != nil {
+			t.Fatalf("failed to parse %s after marshaling: %s\n%s", file, err, b)
+		}
+		if !reflect.DeepEqual(obligation, parsed) {
+			t.Errorf("%s changed after a yaml round trip\n%s", file, b)
+		}
+	}
+}
+
+// the model built in code, rather than parsed, is written in the documented format
+func TestMarshalBuiltObligation(t *testing.T) {
+	grant := NewGrantAction()
+	grant.Subject = NewEvrNode("ua1", "UA", nil)
+	grant.Operations = []string{"read", "*"}
+	grant.Target = NewEvrNode("", "OA", map[string]string{"k": "v", "a": "b"})
+	grant.SetNegatedCondition(&NegatedCondition{[]*Function{NewFunction("current_process", nil)}})
+
+	deny := &DenyAction{
+		Label:      "deny",
+		Subject:    NewEvrNodeFromProcess(NewEvrProcess("42")),
+		Operations: []string{"write"},
+		Target: &ActionTarget{
+			Complement: true,
+			Containers: []*ActionContainer{
+				NewActionContainer("oa1", "OA", nil),
+				NewActionContainerFromFunction(NewFunction("current_target", nil)),
+			},
+		},
+	}
+
+	rule := NewRule()
+	rule.Label = "rule"
+	rule.EventPattern.Subject = NewSubjectFromProcess(NewEvrProcess("42"))
+	rule.EventPattern.PolicyClass = &PolicyClass{EachOf: []string{"pc1", "pc2"}}
+	rule.EventPattern.Operations = []string{"read"}
+	rule.EventPattern.Target = &Target{Containers: []*EvrNode{NewEvrNode("oa1", "OA", nil)}}
+	rule.ResponsePattern.Actions = []Action{grant, deny, &DeleteAction{Prohibitions: []string{"deny"}, Rules: []string{"rule"}}}
+
+	obligation := NewObligation("super")
+	obligation.Label = "built"
+	obligation.Rules = []*Rule{rule}
+
+	b, err := json.Marshal(obligation)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	parsed, err := ParseBytes("super", b)
+	if err != nil {
+		t.Fatalf("the marshaled obligation should be valid: %s\n%s", err, b)
+	}
+
+	r := parsed.Rules[0]
+	if r.EventPattern.Subject.Process.Value != "42" || !reflect.DeepEqual(r.EventPattern.PolicyClass.EachOf, []string{"pc1", "pc2"}) {
+		t.Errorf("unexpected event pattern")
+	}
+	if len(r.EventPattern.Target.Containers) != 1 || r.EventPattern.Target.Containers[0].Name != "oa1" {
+		t.Errorf("unexpected target")
+	}
+
+	g := r.ResponsePattern.Actions[0].(*GrantAction)
+	if !reflect.DeepEqual(g.Target.Properties, map[string]string{"k": "v", "a": "b"}) || g.NegatedCondition() == nil {
+		t.Errorf("unexpected grant %+v", g)
+	}
+
+	d := r.ResponsePattern.Actions[1].(*DenyAction)
+	if d.Subject.Process.Value != "42" || !d.Target.Complement || d.Target.Containers[1].Function.Name != "current_target" {
+		t.Errorf("unexpected deny %+v", d)
+	}
+
+	del := r.ResponsePattern.Actions[2].(*DeleteAction)
+	if !reflect.DeepEqual(del.Prohibitions, []string{"deny"}) || !reflect.DeepEqual(del.Rules, []string{"rule"}) {
+		t.Errorf("unexpected delete %+v", del)
+	}
+
+	b2, err := json.Marshal(parsed)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if string(b) != string(b2) {
+		t.Errorf("expected the same document after a round trip\n%s\n%s", b, b2)
+	}
+}
+
+func TestMarshalYAMLAction(t *testing.T) {
+	action := NewAssignAction()
+	action.Assignments = append(action.Assignments, &ActionAssignment{NewEvrNode("o1", "O", nil), NewEvrNode("oa1", "OA", nil)})
+
+	b, err := yaml.Marshal(action)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := `assign:
+    - what:
+        name: o1
+        type: O
+      where:
+        name: oa1
+        type: OA
+`
+	if string(b) != expected {
+		t.Errorf("unexpected yaml\n%s", b)
+	}
+}
+
+func TestMarshalDisabledRule(t *testing.T) {
+	obligation, err := Parse("super", fixtures[0])
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := obligation.SetRuleEnable(obligation.Rules[0].Label, false); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	b, err := json.Marshal(obligation)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	parsed, err := ParseBytes("super", b)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !parsed.Rules[0].Disabled {
+		t.Errorf("the rule should stay disabled after a json round trip")
+	}
+
+	b, err = yaml.Marshal(obligation)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if parsed, err = ParseYAMLBytes("super", b); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !parsed.Rules[0].Disabled {
+		t.Errorf("the rule should stay disabled after a yaml round trip")
+	}
+}
+
+func TestMarshalSchedule(t *testing.T) {
+	doc := `{"label": "l", "rules": [
+		{"label": "cron", "event": {"schedule": {"cron": "0 2 * * *"}}, "response": {"actions": []}},
+		{"label": "after", "event": {"operations": ["assign"], "schedule": {"after": "24h"}}, "response": {"actions": []}}
+	]}`
+	obligation, err := ParseBytes("super", []byte(doc))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if s := obligation.Rules[0].EventPattern.Schedule; s == nil || s.Cron != "0 2 * * *" || s.After != "" {
+		t.Fatalf("unexpected cron schedule %+v", s)
+	}
+
+	b, err := json.Marshal(obligation)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	parsed, err := ParseBytes("super", b)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !reflect.DeepEqual(obligation, parsed) {
+		t.Errorf("the schedules changed after a json round trip\n%s", b)
+	}
+
+	b, err = yaml.Marshal(obligation)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if parsed, err = ParseYAMLBytes("super", b); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !reflect.DeepEqual(obligation, parsed) {
+		t.Errorf("the schedules changed after a yaml round trip\n%s", b)
+	}
+
+	if _, err := ParseBytes("super", []byte(`{"label": "l", "rules": [{"label": "r", "event": {"schedule": {"cron": "@daily", "after": "1h"}}, "response": {"actions": []}}]}`)); err == nil {
+		t.Errorf("expected a schedule with both a cron expression and a duration to be rejected")
+	}
+}
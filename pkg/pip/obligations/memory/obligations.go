@@ -1,21 +1,25 @@
 package memory
 
 import (
+    "fmt"
     ob "github.com/jtejido/ngac/pkg/pip/obligations"
     "sync"
 )
 
 var (
     _ ob.Obligations = &obligations{}
+    _ ob.TimerStore  = &obligations{}
 )
 
 type obligations struct {
     obligations map[string]*ob.Obligation
     sync.RWMutex
+    // pending timers of the scheduled rules
+    ob.TimerStore
 }
 
 func New() ob.Obligations {
-    return &obligations{obligations: make(map[string]*ob.Obligation)}
+    return &obligations{obligations: make(map[string]*ob.Obligation), TimerStore: ob.NewTimerStore()}
 }
 
 func (o *obligations) Add(obligation *ob.Obligation, enable bool) {
@@ -23,8 +27,9 @@ func (o *obligations) Add(obligation *ob.Obligation, enable bool) {
         panic("a nil obligation was received when creating a obligation")
     }
     o.Lock()
-    obligation.Enabled = true
-    o.obligations[obligation.Label] = obligation.Clone()
+    obligation = obligation.Clone()
+    obligation.Enabled = enable
+    o.obligations[obligation.Label] = obligation
     o.Unlock()
 }
 
@@ -50,13 +55,19 @@ func (o *obligations) Update(label string, obligation *ob.Obligation) {
         panic("a null obligation was provided when updating a obligation")
     }
     updatedLabel := obligation.Label
+    if len(updatedLabel) == 0 {
+        updatedLabel = label
+    }
+
+    obligation = obligation.Clone()
+    obligation.Label = updatedLabel
 
     o.Lock()
     if updatedLabel != label {
         delete(o.obligations, label)
     }
 
-    o.obligations[label] = obligation.Clone()
+    o.obligations[updatedLabel] = obligation
     o.Unlock()
 }
 
@@ -70,7 +81,6 @@ func (o *obligations) SetEnable(label string, enabled bool) {
     o.Lock()
     if obligation, ok := o.obligations[label]; ok {
         obligation.Enabled = enabled
-        o.Update(label, obligation)
     }
     o.Unlock()
 }
@@ -86,3 +96,55 @@ func (o *obligations) GetEnabled() []*ob.Obligation {
     o.RUnlock()
     return obs
 }
+
+// updateRules applies the change to a copy of the obligation's rules, the obligations handed out are never modified.
+func (o *obligations) updateRules(label string, change func(*ob.Obligation) error) error {
+    o.Lock()
+    defer o.Unlock()
+    obligation, ok := o.obligations[label]
+    if !ok {
+        return fmt.Errorf("obligation %s does not exist", label)
+    }
+
+    updated := obligation.Clone()
+    if err := change(updated); err != nil {
+        return err
+    }
+    o.obligations[label] = updated
+    return nil
+}
+
+func (o *obligations) AddRule(label string, rule *ob.Rule) error {
+    return o.updateRules(label, func(obligation *ob.Obligation) error {
+        return obligation.AddRule(rule)
+    })
+}
+
+func (o *obligations) RemoveRule(label, ruleLabel string) error {
+    return o.updateRules(label, func(obligation *ob.Obligation) error {
+        return obligation.RemoveRule(ruleLabel)
+    })
+}
+
+func (o *obligations) ReplaceRule(label, ruleLabel string, rule *ob.Rule) error {
+    return o.updateRules(label, func(obligation *ob.Obligation) error {
+        return obligation.ReplaceRule(ruleLabel, rule)
+    })
+}
+
+func (o *obligations) SetRuleEnable(label, ruleLabel string, enabled bool) error {
+    return o.updateRules(label, func(obligation *ob.Obligation) error {
+        return obligation.SetRuleEnable(ruleLabel, enabled)
+    })
+}
+
+func (o *obligations) Rules(label string) ([]*ob.Rule, error) {
+    o.RLock()
+    defer o.RUnlock()
+    obligation, ok := o.obligations[label]
+    if !ok {
+        return nil, fmt.Errorf("obligation %s does not exist", label)
+    }
+
+    return append([]*ob.Rule{}, obligation.Rules...), nil
+}
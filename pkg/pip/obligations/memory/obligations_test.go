@@ -51,3 +51,103 @@ func TestAddGetConcurrent(t *testing.T) {
 		}
 	}
 }
+
+func TestSetEnable(t *testing.T) {
+	s := New()
+	o1 := ob.NewObligation("u1")
+	o1.Label = "o1"
+	s.Add(o1, false)
+	o2 := ob.NewObligation("u1")
+	o2.Label = "o2"
+	s.Add(o2, true)
+
+	if enabled := s.GetEnabled(); len(enabled) != 1 || enabled[0].Label != "o2" {
+		t.Fatalf("expected only o2 to be enabled")
+	}
+
+	s.SetEnable("o1", true)
+	if !s.Get("o1").Enabled {
+		t.Errorf("o1 should be enabled")
+	}
+	if len(s.GetEnabled()) != 2 {
+		t.Errorf("expected 2 enabled obligations")
+	}
+
+	s.SetEnable("o2", false)
+	if s.Get("o2").Enabled {
+		t.Errorf("o2 should be disabled")
+	}
+}
+
+func TestUpdateLabel(t *testing.T) {
+	s := New()
+	o1 := ob.NewObligation("u1")
+	o1.Label = "o1"
+	s.Add(o1, true)
+
+	updated := ob.NewObligation("u1")
+	updated.Label = "o2"
+	s.Update("o1", updated)
+
+	if s.Get("o1") != nil {
+		t.Errorf("o1 should not exist after updating its label")
+	}
+	if s.Get("o2") == nil {
+		t.Errorf("o2 should exist after updating the label of o1")
+	}
+}
+
+func TestRules(t *testing.T) {
+	s := New()
+	o1 := ob.NewObligation("u1")
+	o1.Label = "o1"
+	s.Add(o1, true)
+	before := s.Get("o1")
+
+	for _, label := range []string{"r1", "r2", "r3"} {
+		rule := ob.NewRule()
+		rule.Label = label
+		if err := s.AddRule("o1", rule); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	if err := s.AddRule("o1", &ob.Rule{Label: "r1"}); err == nil {
+		t.Errorf("expected a duplicate rule label to be rejected")
+	}
+	if err := s.AddRule("o2", &ob.Rule{Label: "r1"}); err == nil {
+		t.Errorf("expected a rule not to be added to a missing obligation")
+	}
+	if len(before.Rules) != 0 {
+		t.Errorf("adding rules should not change an obligation already returned")
+	}
+
+	if err := s.RemoveRule("o1", "r2"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := s.RemoveRule("o1", "r2"); err == nil {
+		t.Errorf("expected removing a missing rule to fail")
+	}
+	if err := s.ReplaceRule("o1", "r1", &ob.Rule{Label: "r4"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := s.ReplaceRule("o1", "r4", &ob.Rule{Label: "r3"}); err == nil {
+		t.Errorf("expected replacing a rule with the label of another to fail")
+	}
+	if err := s.SetRuleEnable("o1", "r3", false); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	rules, err := s.Rules("o1")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(rules) != 2 || rules[0].Label != "r4" || rules[1].Label != "r3" {
+		t.Fatalf("expected rules r4 and r3, got %v", rules)
+	}
+	if rules[0].Disabled || !rules[1].Disabled {
+		t.Errorf("expected only r3 to be disabled")
+	}
+	if enabled := s.Get("o1").EnabledRules(); len(enabled) != 1 || enabled[0].Label != "r4" {
+		t.Errorf("expected r4 to be the only enabled rule")
+	}
+}
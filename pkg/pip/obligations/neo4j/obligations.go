@@ -0,0 +1,455 @@
+package neo4j
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jtejido/ngac/pkg/config"
+	ob "github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"log"
+)
+
+var (
+	_ ob.Obligations = &obligations{}
+	_ ob.TimerStore  = &obligations{}
+)
+
+// Obligations are stored as (:Obligation) nodes. The label is kept under "id" so that obligations never match the
+// graph's (n{name:...}) lookups when both share a database. The rules are stored as the JSON document read by
+// obligations.Parse, and the enabled flag is indexed so GetEnabled doesn't have to decode every obligation.
+const (
+	obligation_label  = "Obligation"
+	obligation_return = "o.id, o.user, o.enabled, o.source, o.definition"
+
+	// pending timers of scheduled rules are (:Timer) nodes holding the timer's JSON under "definition"
+	timer_label = "Timer"
+)
+
+type obligations struct {
+	config *config.Config
+	driver neo4j.Driver
+}
+
+// Accepts the config file's location for Neo4j
+func New(cfg string) (ob.Obligations, error) {
+	conf, err := config.LoadConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(obligations)
+	ret.config = conf
+	ret.driver = nil
+	return ret, nil
+}
+
+func (no *obligations) Start() (err error) {
+	if no.driver == nil {
+		no.driver, err = neo4j.NewDriver(no.config.Uri, neo4j.BasicAuth(no.config.Username, no.config.Password, ""))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (no *obligations) Close() (err error) {
+	return no.driver.Close()
+}
+
+// CreateIndexes creates the indexes used to look up obligations by label and enabled flag.
+func (no *obligations) CreateIndexes() error {
+	return no.write(func(tx neo4j.Transaction) error {
+		for _, cypher := range []string{
+			fmt.Sprintf("CREATE INDEX obligation_id IF NOT EXISTS FOR (o:%s) ON (o.id)", obligation_label),
+			fmt.Sprintf("CREATE INDEX obligation_enabled IF NOT EXISTS FOR (o:%s) ON (o.enabled)", obligation_label),
+			fmt.Sprintf("CREATE INDEX timer_id IF NOT EXISTS FOR (t:%s) ON (t.id)", timer_label),
+		} {
+			result, err := tx.Run(cypher, nil)
+			if err != nil {
+				return err
+			}
+			if _, err = result.Consume(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (no *obligations) Add(obligation *ob.Obligation, enable bool) {
+	if obligation == nil {
+		panic("a nil obligation was received when creating a obligation")
+	}
+
+	params, err := obligationParams(obligation)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	params["enabled"] = enable
+
+	// adding an existing label replaces the stored obligation
+	err = no.write(func(tx neo4j.Transaction) error {
+		result, err := tx.Run(fmt.Sprintf("MERGE (o:%s {id: $label}) SET o.user = $user, o.enabled = $enabled, o.source = $source, o.definition = $definition", obligation_label), params)
+		if err != nil {
+			return err
+		}
+
+		_, err = result.Consume()
+		return err
+	})
+
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (no *obligations) Get(label string) *ob.Obligation {
+	obs := no.query(fmt.Sprintf("MATCH (o:%s {id: $label}) RETURN %s", obligation_label, obligation_return), map[string]interface{}{
+		"label": label,
+	})
+
+	if len(obs) == 0 {
+		return nil
+	}
+
+	return obs[0]
+}
+
+func (no *obligations) All() []*ob.Obligation {
+	return no.query(fmt.Sprintf("MATCH (o:%s) RETURN %s", obligation_label, obligation_return), nil)
+}
+
+func (no *obligations) Update(label string, obligation *ob.Obligation) {
+	if obligation == nil {
+		panic("a null obligation was provided when updating a obligation")
+	}
+
+	params, err := obligationParams(obligation)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	if len(obligation.Label) == 0 {
+		params["label"] = label
+	}
+	params["oldLabel"] = label
+	params["enabled"] = obligation.Enabled
+
+	err = no.write(func(tx neo4j.Transaction) error {
+		result, err := tx.Run(fmt.Sprintf("MATCH (o:%s {id: $oldLabel}) SET o.id = $label, o.user = $user, o.enabled = $enabled, o.source = $source, o.definition = $definition", obligation_label), params)
+		if err != nil {
+			return err
+		}
+
+		_, err = result.Consume()
+		return err
+	})
+
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (no *obligations) Remove(label string) {
+	err := no.write(func(tx neo4j.Transaction) error {
+		result, err := tx.Run(fmt.Sprintf("MATCH (o:%s {id: $label}) DELETE o", obligation_label), map[string]interface{}{
+			"label": label,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = result.Consume()
+		return err
+	})
+
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (no *obligations) SetEnable(label string, enabled bool) {
+	// only the flag is written, the stored rules are left untouched
+	err := no.write(func(tx neo4j.Transaction) error {
+		result, err := tx.Run(fmt.Sprintf("MATCH (o:%s {id: $label}) SET o.enabled = $enabled", obligation_label), map[string]interface{}{
+			"label":   label,
+			"enabled": enabled,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = result.Consume()
+		return err
+	})
+
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (no *obligations) GetEnabled() []*ob.Obligation {
+	return no.query(fmt.Sprintf("MATCH (o:%s {enabled: true}) RETURN %s", obligation_label, obligation_return), nil)
+}
+
+// updateRules reads the obligation and writes back its rules changed in the same transaction, so concurrent rule
+// changes don't overwrite each other.
+func (no *obligations) updateRules(label string, change func(*ob.Obligation) error) error {
+	return no.write(func(tx neo4j.Transaction) error {
+		records, err := tx.Run(fmt.Sprintf("MATCH (o:%s {id: $label}) RETURN %s", obligation_label, obligation_return), map[string]interface{}{
+			"label": label,
+		})
+		if err != nil {
+			return err
+		}
+		record, err := records.Single()
+		if err != nil {
+			return fmt.Errorf("obligation %s does not exist", label)
+		}
+		obligation, err := toObligation(record.Values)
+		if err != nil {
+			return err
+		}
+
+		if err = change(obligation); err != nil {
+			return err
+		}
+		params, err := obligationParams(obligation)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Run(fmt.Sprintf("MATCH (o:%s {id: $label}) SET o.definition = $definition", obligation_label), params)
+		if err != nil {
+			return err
+		}
+
+		_, err = result.Consume()
+		return err
+	})
+}
+
+func (no *obligations) AddRule(label string, rule *ob.Rule) error {
+	return no.updateRules(label, func(obligation *ob.Obligation) error {
+		return obligation.AddRule(rule)
+	})
+}
+
+func (no *obligations) RemoveRule(label, ruleLabel string) error {
+	return no.updateRules(label, func(obligation *ob.Obligation) error {
+		return obligation.RemoveRule(ruleLabel)
+	})
+}
+
+func (no *obligations) ReplaceRule(label, ruleLabel string, rule *ob.Rule) error {
+	return no.updateRules(label, func(obligation *ob.Obligation) error {
+		return obligation.ReplaceRule(ruleLabel, rule)
+	})
+}
+
+func (no *obligations) SetRuleEnable(label, ruleLabel string, enabled bool) error {
+	return no.updateRules(label, func(obligation *ob.Obligation) error {
+		return obligation.SetRuleEnable(ruleLabel, enabled)
+	})
+}
+
+func (no *obligations) Rules(label string) ([]*ob.Rule, error) {
+	obligation := no.Get(label)
+	if obligation == nil {
+		return nil, fmt.Errorf("obligation %s does not exist", label)
+	}
+
+	return obligation.Rules, nil
+}
+
+func (no *obligations) AddTimer(timer *ob.Timer) {
+	definition, err := json.Marshal(timer)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	err = no.write(func(tx neo4j.Transaction) error {
+		result, err := tx.Run(fmt.Sprintf("MERGE (t:%s {id: $id}) SET t.definition = $definition", timer_label), map[string]interface{}{
+			"id":         timer.ID,
+			"definition": string(definition),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = result.Consume()
+		return err
+	})
+
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (no *obligations) RemoveTimer(id string) {
+	err := no.write(func(tx neo4j.Transaction) error {
+		result, err := tx.Run(fmt.Sprintf("MATCH (t:%s {id: $id}) DELETE t", timer_label), map[string]interface{}{
+			"id": id,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = result.Consume()
+		return err
+	})
+
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (no *obligations) PendingTimers() []*ob.Timer {
+	session := no.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeRead,
+		DatabaseName: no.config.Database,
+	})
+	defer session.Close()
+
+	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		records, err := tx.Run(fmt.Sprintf("MATCH (t:%s) RETURN t.definition", timer_label), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		timers := make([]*ob.Timer, 0)
+		for records.Next() {
+			definition, _ := records.Record().Values[0].(string)
+			timer := new(ob.Timer)
+			if err := json.Unmarshal([]byte(definition), timer); err != nil {
+				return nil, err
+			}
+			timers = append(timers, timer)
+		}
+
+		return timers, records.Err()
+	})
+
+	if err != nil {
+		log.Println(err.Error())
+		return make([]*ob.Timer, 0)
+	}
+
+	timers := result.([]*ob.Timer)
+	ob.SortTimers(timers)
+	return timers
+}
+
+func (no *obligations) write(work func(tx neo4j.Transaction) error) error {
+	session := no.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: no.config.Database,
+	})
+	defer session.Close()
+
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		return nil, work(tx)
+	})
+
+	return err
+}
+
+func (no *obligations) query(cypher string, params map[string]interface{}) []*ob.Obligation {
+	session := no.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeRead,
+		DatabaseName: no.config.Database,
+	})
+
+	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		records, err := tx.Run(cypher, params)
+		if err != nil {
+			return nil, err
+		}
+
+		obs := make([]*ob.Obligation, 0)
+		for records.Next() {
+			obligation, err := toObligation(records.Record().Values)
+			if err != nil {
+				return nil, err
+			}
+			obs = append(obs, obligation)
+		}
+
+		if err = records.Err(); err != nil {
+			return nil, err
+		}
+
+		return obs, nil
+	})
+
+	session.Close()
+	if err != nil {
+		log.Println(err.Error())
+		return make([]*ob.Obligation, 0)
+	}
+
+	return result.([]*ob.Obligation)
+}
+
+func obligationParams(obligation *ob.Obligation) (map[string]interface{}, error) {
+	definition, err := json.Marshal(obligation)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"label":      obligation.Label,
+		"user":       obligation.User,
+		"source":     obligation.Source,
+		"definition": string(definition),
+	}, nil
+}
+
+// toObligation builds an obligation from the values returned in the order of obligation_return.
+func toObligation(values []interface{}) (*ob.Obligation, error) {
+	label, ok := values[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid obligation label found")
+	}
+
+	user, _ := values[1].(string)
+	obligation := ob.NewObligation(user)
+
+	if v, ok := values[4].(string); ok && len(v) > 0 {
+		if err := json.Unmarshal([]byte(v), obligation); err != nil {
+			return nil, err
+		}
+	}
+
+	obligation.Label = label
+	obligation.Enabled, _ = values[2].(bool)
+	obligation.Source, _ = values[3].(string)
+
+	return obligation, nil
+}
+
+// testing only
+func (no *obligations) reset() error {
+	return no.write(func(tx neo4j.Transaction) error {
+		result, err := tx.Run(fmt.Sprintf("MATCH (o:%s) DELETE o", obligation_label), nil)
+		if err != nil {
+			return err
+		}
+		if _, err = result.Consume(); err != nil {
+			return err
+		}
+
+		result, err = tx.Run(fmt.Sprintf("MATCH (t:%s) DELETE t", timer_label), nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = result.Consume()
+		return err
+	})
+}
@@ -0,0 +1,223 @@
+package neo4j
+
+import (
+	"encoding/json"
+	ob "github.com/jtejido/ngac/pkg/pip/obligations"
+	"testing"
+	"time"
+)
+
+const testObligation = `{
+  "label": "test",
+  "rules": [{
+    "label": "rule1",
+    "event": {
+      "subject": {"user": "u1"},
+      "operations": ["assign to"],
+      "target": {"policyElements": [{"name": "oa1", "type": "OA"}]}
+    },
+    "response": {
+      "actions": [{
+        "function": {
+          "name": "create_node",
+          "args": ["pc1", "PC", "new", "OA", {"function": {"name": "to_props", "args": ["k=v"]}}]
+        }
+      }]
+    }
+  }]
+}`
+
+// testObligations connects to the database described in test_config.yaml and clears the stored obligations. The
+// test is skipped when no database is reachable.
+func testObligations(t *testing.T) *obligations {
+	oo, err := New(`test_config.yaml`)
+	if err != nil {
+		t.Fatalf("failed to create obligations: %s", err.Error())
+	}
+
+	no := oo.(*obligations)
+	if err = no.Start(); err != nil {
+		t.Skipf("neo4j is not available: %s", err.Error())
+	}
+
+	if err = no.driver.VerifyConnectivity(); err != nil {
+		no.Close()
+		t.Skipf("neo4j is not available: %s", err.Error())
+	}
+
+	if err = no.CreateIndexes(); err != nil {
+		no.Close()
+		t.Fatalf("failed to create indexes: %s", err.Error())
+	}
+
+	if err = no.reset(); err != nil {
+		no.Close()
+		t.Fatalf("failed to reset obligations: %s", err.Error())
+	}
+
+	t.Cleanup(func() { no.Close() })
+	return no
+}
+
+func newObligation(t *testing.T, label string) *ob.Obligation {
+	obligation := ob.NewObligation("u1")
+	if err := json.Unmarshal([]byte(testObligation), obligation); err != nil {
+		t.Fatalf("%s", err)
+	}
+	obligation.Label = label
+	obligation.Source = "test.json"
+
+	return obligation
+}
+
+func TestAddGet(t *testing.T) {
+	s := testObligations(t)
+	s.Add(newObligation(t, "o1"), true)
+
+	obligation := s.Get("o1")
+	if obligation == nil {
+		t.Fatalf("o1 should exist")
+	}
+	if obligation.User != "u1" || obligation.Source != "test.json" || !obligation.Enabled {
+		t.Errorf("unexpected obligation %+v", obligation)
+	}
+	if len(obligation.Rules) != 1 || obligation.Rules[0].Label != "rule1" {
+		t.Fatalf("expected rule1 to be stored")
+	}
+	action, ok := obligation.Rules[0].ResponsePattern.Actions[0].(*ob.FunctionAction)
+	if !ok {
+		t.Fatalf("expected a function action")
+	}
+	if f := action.Function.Args[4].Function; f == nil || f.Name != "to_props" {
+		t.Errorf("expected the nested to_props function to be stored")
+	}
+
+	if s.Get("o2") != nil {
+		t.Errorf("o2 should not exist")
+	}
+}
+
+func TestSetEnable(t *testing.T) {
+	s := testObligations(t)
+	s.Add(newObligation(t, "o1"), false)
+	s.Add(newObligation(t, "o2"), true)
+
+	if enabled := s.GetEnabled(); len(enabled) != 1 || enabled[0].Label != "o2" {
+		t.Fatalf("expected only o2 to be enabled")
+	}
+
+	s.SetEnable("o1", true)
+	if !s.Get("o1").Enabled {
+		t.Errorf("o1 should be enabled")
+	}
+	if len(s.GetEnabled()) != 2 {
+		t.Errorf("expected 2 enabled obligations")
+	}
+	if len(s.Get("o1").Rules) != 1 {
+		t.Errorf("enabling an obligation should keep its rules")
+	}
+}
+
+func TestUpdateRemove(t *testing.T) {
+	s := testObligations(t)
+	s.Add(newObligation(t, "o1"), true)
+
+	updated := newObligation(t, "o2")
+	updated.User = "u2"
+	s.Update("o1", updated)
+
+	if s.Get("o1") != nil {
+		t.Errorf("o1 should not exist after updating its label")
+	}
+	obligation := s.Get("o2")
+	if obligation == nil {
+		t.Fatalf("o2 should exist after updating the label of o1")
+	}
+	if obligation.User != "u2" {
+		t.Errorf("user was not updated")
+	}
+	if len(s.All()) != 1 {
+		t.Errorf("update should not add an obligation")
+	}
+
+	s.Remove("o2")
+	if len(s.All()) != 0 {
+		t.Errorf("o2 should not exist after removing it")
+	}
+}
+
+func TestDefinitionRoundTrip(t *testing.T) {
+	obligation := newObligation(t, "o1")
+	params, err := obligationParams(obligation)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	stored, err := toObligation([]interface{}{params["label"], params["user"], true, params["source"], params["definition"]})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if stored.Label != "o1" || stored.User != "u1" || stored.Source != "test.json" || !stored.Enabled {
+		t.Errorf("unexpected obligation %+v", stored)
+	}
+
+	b1, _ := json.Marshal(obligation)
+	b2, _ := json.Marshal(stored)
+	if string(b1) != string(b2) {
+		t.Errorf("expected the same rules after a round trip\n%s\n%s", b1, b2)
+	}
+}
+
+func TestRules(t *testing.T) {
+	s := testObligations(t)
+	s.Add(newObligation(t, "o1"), true)
+
+	rule := ob.NewRule()
+	rule.Label = "rule2"
+	if err := s.AddRule("o1", rule); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := s.SetRuleEnable("o1", "rule1", false); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	rules, err := s.Rules("o1")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(rules) != 2 || !rules[0].Disabled || rules[1].Label != "rule2" {
+		t.Fatalf("expected rule1 disabled and rule2, got %v", rules)
+	}
+	if !s.Get("o1").Enabled {
+		t.Errorf("changing the rules should keep the obligation enabled")
+	}
+
+	if err := s.RemoveRule("o1", "rule1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := s.RemoveRule("o2", "rule1"); err == nil {
+		t.Errorf("expected removing a rule of a missing obligation to fail")
+	}
+	if rules, _ := s.Rules("o1"); len(rules) != 1 || rules[0].Label != "rule2" {
+		t.Errorf("expected only rule2 to remain")
+	}
+}
+
+func TestTimers(t *testing.T) {
+	s := testObligations(t)
+	due := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	s.AddTimer(&ob.Timer{ID: "t2", Obligation: "o1", Rule: "rule1", Due: due.Add(time.Hour), Cron: "0 * * * *"})
+	s.AddTimer(&ob.Timer{ID: "t1", Obligation: "o1", Rule: "rule1", Due: due, Target: "oa1", User: "u1"})
+
+	pending := s.PendingTimers()
+	if len(pending) != 2 || pending[0].ID != "t1" || !pending[0].Due.Equal(due) || pending[0].Target != "oa1" || pending[1].Cron != "0 * * * *" {
+		t.Fatalf("expected timers t1 and t2 in due order, got %v", pending)
+	}
+
+	// adding a timer with the same ID replaces it
+	s.AddTimer(&ob.Timer{ID: "t2", Obligation: "o1", Rule: "rule1", Due: due.Add(2 * time.Hour), Cron: "0 * * * *"})
+	s.RemoveTimer("t1")
+	if pending := s.PendingTimers(); len(pending) != 1 || !pending[0].Due.Equal(due.Add(2*time.Hour)) {
+		t.Errorf("expected only the moved timer t2, got %v", pending)
+	}
+}
@@ -3,51 +3,55 @@ package obligations
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/xeipuuv/gojsonschema"
+	"io"
 	"io/ioutil"
+	"math"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
-func validateSchema(schema, file string) error {
-	path, err := filepath.Abs(schema)
-	if err != nil {
-		return err
+// Parse reads the obligation in the given file. Files ending in .yaml or .yml are read as yaml, anything else as
+// json.
+func Parse(user, file string) (*Obligation, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return ParseYAML(user, file)
 	}
 
-	fp, err := filepath.Abs(file)
+	b, err := ioutil.ReadFile(file)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	schemaLoader := gojsonschema.NewReferenceLoader("file:///" + path)
-	documentLoader := gojsonschema.NewReferenceLoader("file:///" + fp)
 
-	result, err := gojsonschema.Validate(schemaLoader, documentLoader)
+	obligation, err := ParseBytes(user, b)
 	if err != nil {
-		return err
-	}
-
-	if !result.Valid() {
-		for _, desc := range result.Errors() {
-			return fmt.Errorf("- %s\n", desc)
-		}
+		return nil, err
 	}
+	obligation.Source = file
 
-	return nil
+	return obligation, nil
 }
 
-func Parse(user, file string) (*Obligation, error) {
-	obligation := NewObligation(user)
-	obligation.Source = file
-	if err := validateSchema("../../../api/obligations.json", file); err != nil {
+// ParseReader reads a json obligation from r.
+func ParseReader(user string, r io.Reader) (*Obligation, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
 		return nil, err
 	}
-	jsonFile, err := ioutil.ReadFile(file)
-	if err != nil {
+
+	return ParseBytes(user, b)
+}
+
+// ParseBytes reads a json obligation. The document is validated against the schema embedded in the api package, the
+// violations are returned as a *ValidationError.
+func ParseBytes(user string, b []byte) (*Obligation, error) {
+	if err := validateSchema(b); err != nil {
 		return nil, err
 	}
-	err = json.Unmarshal(jsonFile, obligation)
 
-	if err != nil {
+	obligation := NewObligation(user)
+	if err := json.Unmarshal(b, obligation); err != nil {
 		return nil, err
 	}
 
@@ -60,6 +64,41 @@ type Obligation struct {
 	Label   string  `json:"label" yaml:"label"`
 	Rules   []*Rule `json:"rules, omitempty" yaml:"rules, omitempty"`
 	Source  string
+	// OnError is the error policy of the rules that don't set their own
+	OnError *ErrorPolicy `json:"onError,omitempty" yaml:"onError,omitempty"`
+}
+
+const (
+	// ABORT fails the obligation and the operation that raised the event
+	ABORT = "abort"
+	// SKIP rolls back the failing action and continues with the next one
+	SKIP = "skip"
+	// RETRY runs the response again after a delay, doubled after each attempt
+	RETRY = "retry"
+)
+
+// ErrorPolicy tells what happens when a response fails. The failures tolerated by the skip and retry policies are kept
+// as dead letters by the EPP.
+type ErrorPolicy struct {
+	Policy  string `json:"policy" yaml:"policy"`
+	Retries int    `json:"retries,omitempty" yaml:"retries,omitempty"`
+	Delay   string `json:"delay,omitempty" yaml:"delay,omitempty"`
+}
+
+func toErrorPolicy(v interface{}) *ErrorPolicy {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	policy := new(ErrorPolicy)
+	policy.Policy, _ = m["policy"].(string)
+	if retries, ok := m["retries"].(float64); ok {
+		policy.Retries = int(retries)
+	}
+	policy.Delay, _ = m["delay"].(string)
+
+	return policy
 }
 
 func NewObligation(user string) *Obligation {
@@ -93,17 +132,142 @@ func (ob *Obligation) UnmarshalJSON(b []byte) error {
 			}
 		}
 	}
+
+	ob.OnError = toErrorPolicy(raw["onError"])
 	return nil
 }
 
 func (ob *Obligation) Clone() *Obligation {
-	return &Obligation{ob.User, ob.Enabled, ob.Label, append([]*Rule{}, ob.Rules...), ob.Source}
+	return &Obligation{
+		User:    ob.User,
+		Enabled: ob.Enabled,
+		Label:   ob.Label,
+		Rules:   append([]*Rule{}, ob.Rules...),
+		Source:  ob.Source,
+		OnError: ob.OnError,
+	}
+}
+
+// ErrorPolicy returns the error policy of the rule, the obligation's when the rule has none and abort when neither
+// sets one.
+func (ob *Obligation) ErrorPolicy(rule *Rule) *ErrorPolicy {
+	if rule != nil && rule.OnError != nil {
+		return rule.OnError
+	}
+	if ob.OnError != nil {
+		return ob.OnError
+	}
+
+	return &ErrorPolicy{Policy: ABORT}
+}
+
+// Rule returns the rule with the given label, nil if there is none.
+func (ob *Obligation) Rule(label string) *Rule {
+	for _, rule := range ob.Rules {
+		if rule.Label == label {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// AddRule appends the rule, its label must be unique in the obligation.
+func (ob *Obligation) AddRule(rule *Rule) error {
+	if rule == nil {
+		return fmt.Errorf("a nil rule was provided to obligation %s", ob.Label)
+	}
+	if len(rule.Label) == 0 {
+		return fmt.Errorf("no label provided for rule")
+	}
+	if ob.Rule(rule.Label) != nil {
+		return fmt.Errorf("obligation %s already has a rule %s", ob.Label, rule.Label)
+	}
+
+	ob.Rules = append(ob.Rules[:len(ob.Rules):len(ob.Rules)], rule)
+	return nil
+}
+
+// RemoveRule removes the rule with the given label.
+func (ob *Obligation) RemoveRule(label string) error {
+	i, err := ob.ruleIndex(label)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]*Rule, 0, len(ob.Rules)-1)
+	ob.Rules = append(append(rules, ob.Rules[:i]...), ob.Rules[i+1:]...)
+	return nil
+}
+
+// ReplaceRule puts the rule in place of the rule with the given label, keeping the label if the rule has none.
+func (ob *Obligation) ReplaceRule(label string, rule *Rule) error {
+	if rule == nil {
+		return fmt.Errorf("a nil rule was provided to obligation %s", ob.Label)
+	}
+	i, err := ob.ruleIndex(label)
+	if err != nil {
+		return err
+	}
+
+	replacement := *rule
+	if len(replacement.Label) == 0 {
+		replacement.Label = label
+	}
+	if replacement.Label != label && ob.Rule(replacement.Label) != nil {
+		return fmt.Errorf("obligation %s already has a rule %s", ob.Label, replacement.Label)
+	}
+
+	ob.Rules = append([]*Rule{}, ob.Rules...)
+	ob.Rules[i] = &replacement
+	return nil
+}
+
+// SetRuleEnable enables or disables the rule with the given label.
+func (ob *Obligation) SetRuleEnable(label string, enabled bool) error {
+	i, err := ob.ruleIndex(label)
+	if err != nil {
+		return err
+	}
+
+	// the rules can be shared with clones of the obligation
+	rule := *ob.Rules[i]
+	rule.Disabled = !enabled
+	ob.Rules = append([]*Rule{}, ob.Rules...)
+	ob.Rules[i] = &rule
+	return nil
+}
+
+// EnabledRules returns the rules that are not disabled.
+func (ob *Obligation) EnabledRules() []*Rule {
+	rules := make([]*Rule, 0, len(ob.Rules))
+	for _, rule := range ob.Rules {
+		if !rule.Disabled {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+func (ob *Obligation) ruleIndex(label string) (int, error) {
+	for i, rule := range ob.Rules {
+		if rule.Label == label {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("obligation %s has no rule %s", ob.Label, label)
 }
 
 type Rule struct {
 	Label           string           `json:"label" yaml:"label"`
 	EventPattern    *EventPattern    `json:"event" yaml:"event"`
 	ResponsePattern *ResponsePattern `json:"response" yaml:"response"`
+	// Disabled rules are ignored when processing events
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// OnError overrides the error policy of the obligation
+	OnError *ErrorPolicy `json:"onError,omitempty" yaml:"onError,omitempty"`
 }
 
 func (r *Rule) UnmarshalJSON(b []byte) error {
@@ -120,6 +284,12 @@ func (r *Rule) UnmarshalJSON(b []byte) error {
 		return fmt.Errorf("no label provided for rule")
 	}
 
+	if v, ok := raw["disabled"].(bool); ok {
+		r.Disabled = v
+	}
+
+	r.OnError = toErrorPolicy(raw["onError"])
+
 	if v, ok := raw["event"]; ok {
 		r.EventPattern = new(EventPattern)
 		b, err := json.Marshal(v.(interface{}))
@@ -152,7 +322,7 @@ func (r *Rule) UnmarshalJSON(b []byte) error {
 }
 
 func NewRule() *Rule {
-	return &Rule{"", new(EventPattern), NewResponsePattern()}
+	return &Rule{EventPattern: new(EventPattern), ResponsePattern: NewResponsePattern()}
 }
 
 type EventPattern struct {
@@ -160,6 +330,15 @@ type EventPattern struct {
 	PolicyClass *PolicyClass `json:"policyClass, omitempty" yaml:"policyClass, omitempty"`
 	Operations  []string     `json:"operations, omitempty" yaml:"operations, omitempty"`
 	Target      *Target      `json:"target, omitempty" yaml:"target, omitempty"`
+	// Schedule makes the rule fire on a cron schedule or some time after a matching event
+	Schedule *Schedule `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+}
+
+// Schedule holds either a cron expression, the rule firing on each of its times, or a duration like "30m" after which
+// the rule fires for an event matching the rest of the pattern.
+type Schedule struct {
+	Cron  string `json:"cron,omitempty" yaml:"cron,omitempty"`
+	After string `json:"after,omitempty" yaml:"after,omitempty"`
 }
 
 func (e *EventPattern) UnmarshalJSON(b []byte) error {
@@ -197,6 +376,12 @@ func (e *EventPattern) UnmarshalJSON(b []byte) error {
 		}
 	}
 
+	if v, ok := raw["schedule"].(map[string]interface{}); ok {
+		e.Schedule = new(Schedule)
+		e.Schedule.Cron, _ = v["cron"].(string)
+		e.Schedule.After, _ = v["after"].(string)
+	}
+
 	if v, ok := raw["target"]; ok {
 		e.Target = new(Target)
 		b, err := json.Marshal(v.(interface{}))
@@ -238,33 +423,38 @@ func (r *ResponsePattern) UnmarshalJSON(b []byte) error {
 		}
 	}
 
-	if v, ok := raw["not_condition"]; ok {
-		r.NegatedCondition = new(NegatedCondition)
-		b, err := json.Marshal(v.(interface{}))
-		if err != nil {
-			return err
-		}
-		err = r.NegatedCondition.UnmarshalJSON(b)
-		if err != nil {
-			return err
+	for _, key := range negatedConditionKeys {
+		if v, ok := raw[key]; ok {
+			r.NegatedCondition = new(NegatedCondition)
+			if err := remarshal(v, r.NegatedCondition); err != nil {
+				return err
+			}
+			break
 		}
 	}
 
 	if v, ok := raw["actions"]; ok {
 		r.Actions = make([]Action, len(v.([]interface{})))
 		for i, act := range v.([]interface{}) {
-			if _, ok2 := act.(map[string]interface{})["function"]; ok2 {
+			m, ok := act.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("invalid action received")
+			}
+
+			if _, ok2 := m["function"]; ok2 {
 				r.Actions[i] = new(FunctionAction)
-			} else if _, ok2 = act.(map[string]interface{})["create"]; ok2 {
+			} else if _, ok2 = m["create"]; ok2 {
 				r.Actions[i] = new(CreateAction)
-			} else if _, ok2 = act.(map[string]interface{})["assign"]; ok2 {
+			} else if _, ok2 = m["assign"]; ok2 {
 				r.Actions[i] = new(AssignAction)
-			} else if _, ok2 = act.(map[string]interface{})["deny"]; ok2 {
+			} else if _, ok2 = m["deny"]; ok2 {
 				r.Actions[i] = new(DenyAction)
-			} else if _, ok2 = act.(map[string]interface{})["grant"]; ok2 {
+			} else if _, ok2 = m["grant"]; ok2 {
 				r.Actions[i] = new(GrantAction)
-			} else if _, ok2 = act.(map[string]interface{})["delete"]; ok2 {
+			} else if _, ok2 = m["delete"]; ok2 {
 				r.Actions[i] = new(DeleteAction)
+			} else {
+				return fmt.Errorf("invalid action received")
 			}
 
 			b, err := json.Marshal(act)
@@ -286,52 +476,64 @@ type Condition struct {
 	Condition []*Function `json:"function"`
 }
 
-func (c *Condition) UnmarshalJSON(b []byte) error {
-	var raw map[string]interface{}
-	json.Unmarshal(b, &raw)
-	c.Condition = make([]*Function, 0)
-	if v, ok := raw["function"]; ok {
-		for i, _ := range v.([]interface{}) {
-			b, err := json.Marshal(v.([]interface{})[i])
+func (c *Condition) UnmarshalJSON(b []byte) (err error) {
+	c.Condition, err = unmarshalConditionFunctions(b)
+	return
+}
+
+// unmarshalConditionFunctions reads the functions of a condition. The schema form is a list of
+// {"function": ...} objects, the older form is a single object holding a list under "function".
+func unmarshalConditionFunctions(b []byte) ([]*Function, error) {
+	functions := make([]*Function, 0)
+
+	var list []map[string]interface{}
+	if err := json.Unmarshal(b, &list); err == nil {
+		for _, item := range list {
+			v, ok := item["function"]
+			if !ok {
+				return nil, fmt.Errorf("expected a function in condition")
+			}
+			b, err := json.Marshal(v)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			nf := new(Function)
-			err = nf.UnmarshalJSON(b)
-			if err != nil {
-				return err
+			if err = nf.UnmarshalJSON(b); err != nil {
+				return nil, err
 			}
-			c.Condition = append(c.Condition, nf)
+			functions = append(functions, nf)
 		}
-	}
-
-	return nil
-}
 
-type NegatedCondition struct {
-	Condition []*Function `json:"function"`
-}
+		return functions, nil
+	}
 
-func (c *NegatedCondition) UnmarshalJSON(b []byte) error {
 	var raw map[string]interface{}
 	json.Unmarshal(b, &raw)
-	c.Condition = make([]*Function, 0)
 	if v, ok := raw["function"]; ok {
 		for i, _ := range v.([]interface{}) {
 			b, err := json.Marshal(v.([]interface{})[i])
 			if err != nil {
-				return err
+				return nil, err
 			}
 			nf := new(Function)
 			err = nf.UnmarshalJSON(b)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			c.Condition = append(c.Condition, nf)
+			functions = append(functions, nf)
 		}
 	}
 
-	return nil
+	return functions, nil
+}
+
+type NegatedCondition struct {
+	Condition []*Function `json:"function"`
+}
+
+func (c *NegatedCondition) UnmarshalJSON(b []byte) (err error) {
+	c.Condition, err = unmarshalConditionFunctions(b)
+	return
 }
 
 type Subject struct {
@@ -370,7 +572,11 @@ func (s *Subject) UnmarshalJSON(b []byte) error {
 		}
 		return nil
 	} else if v, ok = raw["process"]; ok {
-		s.Process = NewEvrProcess(v.(string))
+		process, err := toProcess(v)
+		if err != nil {
+			return err
+		}
+		s.Process = process
 		return nil
 	} else {
 		return fmt.Errorf("invalid subject specification")
@@ -388,6 +594,24 @@ func NewEvrProcess(process string) *EvrProcess {
 	return &EvrProcess{Value: process}
 }
 
+// toProcess reads a process identifier. The schema describes it as an integer, strings are accepted as well.
+func toProcess(v interface{}) (*EvrProcess, error) {
+	switch p := v.(type) {
+	case float64:
+		if p != math.Trunc(p) {
+			return nil, fmt.Errorf("invalid process %v", p)
+		}
+		return NewEvrProcess(strconv.FormatInt(int64(p), 10)), nil
+	case string:
+		if len(p) == 0 {
+			return nil, fmt.Errorf("process cannot be empty")
+		}
+		return NewEvrProcess(p), nil
+	}
+
+	return nil, fmt.Errorf("invalid process %v", v)
+}
+
 func (evr *EvrProcess) Equals(i interface{}) bool {
 	if v, ok := i.(*EvrProcess); ok {
 		if len(v.Value) == 0 {
@@ -515,7 +739,9 @@ func (evr *EvrNode) Equals(i interface{}) bool {
 
 func (evr *EvrNode) UnmarshalJSON(b []byte) error {
 	var raw map[string]interface{}
-	json.Unmarshal(b, &raw)
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
 
 	if v, ok := raw["function"]; ok {
 		evr.Function = new(Function)
@@ -529,24 +755,57 @@ func (evr *EvrNode) UnmarshalJSON(b []byte) error {
 		}
 
 		return nil
-	} else {
-		v, _ := raw["name"]
-		if len(v.(string)) == 0 || v == nil {
-			return fmt.Errorf("name cannot be empty")
+	} else if v, ok := raw["process"]; ok {
+		process, err := toProcess(v)
+		if err != nil {
+			return err
 		}
+		evr.Process = process
+
+		return nil
+	}
 
-		evr.Name = v.(string)
-		v, _ = raw["type"]
-		if len(v.(string)) == 0 || v == nil {
-			return fmt.Errorf("type cannot be empty")
+	if v, ok := raw["properties"]; ok {
+		properties, err := toProperties(v)
+		if err != nil {
+			return err
 		}
+		evr.Properties = properties
+	}
 
-		evr.Type = v.(string)
+	// a node without a name is looked up by its type and properties
+	name, _ := raw["name"].(string)
+	if len(name) == 0 && len(evr.Properties) == 0 {
+		return fmt.Errorf("name cannot be empty")
+	}
+	evr.Name = name
 
-		return nil
+	t, _ := raw["type"].(string)
+	if len(t) == 0 {
+		return fmt.Errorf("type cannot be empty")
+	}
+	evr.Type = t
+
+	return nil
+}
+
+// toProperties reads node properties given as a list of "key=value" strings.
+func toProperties(v interface{}) (map[string]string, error) {
+	pairs, err := toStrings(v, "properties")
+	if err != nil {
+		return nil, err
+	}
+
+	properties := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			return nil, fmt.Errorf("invalid property %s, expected key=value", pair)
+		}
+		properties[kv[0]] = kv[1]
 	}
 
-	return fmt.Errorf("invalid EVR node")
+	return properties, nil
 }
 
 type Containers struct {
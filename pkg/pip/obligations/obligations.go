@@ -30,4 +30,26 @@ type Obligations interface {
 	 * Returns all enabled obligations
 	 */
 	GetEnabled() []*Obligation
+	/**
+	 * Add the rule to the end of the obligation with the given label.  An error is returned if there is no such
+	 * obligation or if it already has a rule with the same label.
+	 */
+	AddRule(string, *Rule) error
+	/**
+	 * Remove the rule with the given label from the obligation with the given label.
+	 */
+	RemoveRule(string, string) error
+	/**
+	 * Replace the rule with the given label, the new rule takes its place in the obligation.  If the label of the
+	 * provided rule is empty, the label of the replaced rule is kept.
+	 */
+	ReplaceRule(string, string, *Rule) error
+	/**
+	 * Set the enable flag of a single rule of the obligation, a disabled rule is ignored when processing events.
+	 */
+	SetRuleEnable(string, string, bool) error
+	/**
+	 * Returns the rules of the obligation with the given label, in order.
+	 */
+	Rules(string) ([]*Rule, error)
 }
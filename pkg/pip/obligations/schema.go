@@ -0,0 +1,116 @@
+package obligations
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/api"
+	"github.com/xeipuuv/gojsonschema"
+	"strings"
+	"sync"
+)
+
+var (
+	schemaOnce sync.Once
+	schema     *gojsonschema.Schema
+	schemaErr  error
+)
+
+// loadSchema compiles the embedded obligation schema once.
+func loadSchema() (*gojsonschema.Schema, error) {
+	schemaOnce.Do(func() {
+		schema, schemaErr = gojsonschema.NewSchema(gojsonschema.NewBytesLoader(api.ObligationsSchema))
+	})
+
+	return schema, schemaErr
+}
+
+// Violation is a single schema violation. Pointer is the json pointer (RFC 6901) of the offending value, empty for
+// the whole document.
+type Violation struct {
+	Pointer     string
+	Description string
+}
+
+func (v Violation) String() string {
+	pointer := v.Pointer
+	if len(pointer) == 0 {
+		pointer = "/"
+	}
+
+	return fmt.Sprintf("%s: %s", pointer, v.Description)
+}
+
+// ValidationError holds every violation found in a document.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = "- " + v.String()
+	}
+
+	return fmt.Sprintf("invalid obligation:\n%s", strings.Join(lines, "\n"))
+}
+
+// validateSchema validates the json document against the embedded schema. Schema violations are returned as a
+// *ValidationError.
+func validateSchema(b []byte) error {
+	s, err := loadSchema()
+	if err != nil {
+		return err
+	}
+
+	result, err := s.Validate(gojsonschema.NewBytesLoader(b))
+	if err != nil {
+		return err
+	}
+
+	if result.Valid() {
+		return nil
+	}
+
+	violations := make([]Violation, len(result.Errors()))
+	for i, desc := range result.Errors() {
+		violations[i] = Violation{
+			Pointer:     toPointer(desc.Context()),
+			Description: desc.Description(),
+		}
+	}
+
+	return &ValidationError{violations}
+}
+
+// contextFields returns the fields of the context, the root first. The context only joins its fields with a
+// separator, which may also appear in a field since any string is a valid property name. It is joined with two
+// different separators: the fields are the same in both and the separators are where the two differ.
+func contextFields(context *gojsonschema.JsonContext) []string {
+	a, b := context.String("\x00"), context.String("\x01")
+	fields := make([]string, 0)
+	start := 0
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			fields = append(fields, a[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(fields, a[start:])
+}
+
+// toPointer converts the location of a violation to a json pointer, escaping "~" and "/" in its fields (RFC 6901).
+func toPointer(context *gojsonschema.JsonContext) string {
+	if context == nil {
+		return ""
+	}
+
+	// the first field is always the root
+	fields := contextFields(context)[1:]
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString("/")
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(field))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,144 @@
+package obligations
+
+import (
+	"bytes"
+	"errors"
+	"github.com/xeipuuv/gojsonschema"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const invalidObligation = `{
+  "label": 1,
+  "rules": [{
+    "label": "rule",
+    "event": {
+      "subject": {"process": "not a number"},
+      "operations": ["read"]
+    },
+    "response": {
+      "actions": [{
+        "grant": {
+          "subject": {"name": "ua1", "type": "UA"},
+          "operations": [],
+          "target": {"name": "oa1", "type": "NOPE"}
+        }
+      }]
+    }
+  }]
+}`
+
+func TestParseBytesOutsideRepo(t *testing.T) {
+	b, err := ioutil.ReadFile("../../../test/test_actions.json")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	expected, err := Parse("super", "../../../test/test_actions.json")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	expected.Source = ""
+
+	// the schema is embedded, so the working directory doesn't matter
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.Chdir(wd)
+
+	obligation, err := ParseBytes("super", b)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !reflect.DeepEqual(expected, obligation) {
+		t.Errorf("ParseBytes decodes differently from Parse")
+	}
+
+	obligation, err = ParseReader("super", bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !reflect.DeepEqual(expected, obligation) {
+		t.Errorf("ParseReader decodes differently from Parse")
+	}
+}
+
+func TestParseYAMLReader(t *testing.T) {
+	f, err := os.Open("../../../test/test_event.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer f.Close()
+
+	obligation, err := ParseYAMLReader("super", f)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if obligation.Label != "event test" || len(obligation.Rules) != 2 {
+		t.Errorf("unexpected obligation %+v", obligation)
+	}
+}
+
+func TestViolations(t *testing.T) {
+	_, err := ParseBytes("super", []byte(invalidObligation))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	pointers := make(map[string]bool)
+	for _, v := range verr.Violations {
+		pointers[v.Pointer] = true
+	}
+
+	for _, pointer := range []string{
+		"/label",
+		"/rules/0/event/subject/process",
+		"/rules/0/response/actions/0/grant/operations",
+		"/rules/0/response/actions/0/grant/target/type",
+	} {
+		if !pointers[pointer] {
+			t.Errorf("expected a violation at %s, got %v", pointer, verr.Violations)
+		}
+		if !strings.Contains(verr.Error(), pointer+": ") {
+			t.Errorf("expected %s in the error message", pointer)
+		}
+	}
+
+	// the yaml path reports the same violations
+	_, err = ParseYAMLBytes("super", []byte(invalidObligation))
+	var yerr *ValidationError
+	if !errors.As(err, &yerr) || !reflect.DeepEqual(verr, yerr) {
+		t.Errorf("expected the same violations from yaml, got %v", err)
+	}
+}
+
+func TestToPointer(t *testing.T) {
+	root := gojsonschema.NewJsonContext("(root)", nil)
+	if p := toPointer(root); p != "" {
+		t.Errorf("expected the root to be the empty pointer, got %q", p)
+	}
+
+	context := gojsonschema.NewJsonContext("a/b~c", gojsonschema.NewJsonContext("rules", root))
+	if p := toPointer(context); p != "/rules/a~1b~0c" {
+		t.Errorf("unexpected pointer %q", p)
+	}
+
+	// any character can appear in a property name, the separators included
+	context = gojsonschema.NewJsonContext("c\x00d\x01.e", gojsonschema.NewJsonContext("rules", root))
+	if p := toPointer(context); p != "/rules/c\x00d\x01.e" {
+		t.Errorf("unexpected pointer %q", p)
+	}
+}
+
+func TestParseBytesInvalidJSON(t *testing.T) {
+	if _, err := ParseBytes("super", []byte(`{"label": `)); err == nil {
+		t.Errorf("expected an error parsing invalid json")
+	}
+}
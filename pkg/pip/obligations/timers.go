@@ -0,0 +1,85 @@
+package obligations
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timer is a pending firing of a scheduled rule.
+type Timer struct {
+	ID         string    `json:"id"`
+	Obligation string    `json:"obligation"`
+	Rule       string    `json:"rule"`
+	Due        time.Time `json:"due"`
+	// the expression the due time of a cron timer was computed from
+	Cron string `json:"cron,omitempty"`
+	// the event that started the timer of an "after" schedule, empty for a cron schedule
+	Event   string `json:"event,omitempty"`
+	Target  string `json:"target,omitempty"`
+	User    string `json:"user,omitempty"`
+	Process string `json:"process,omitempty"`
+}
+
+// TimerStore is implemented by the obligation stores keeping the pending timers of scheduled rules, so they survive
+// a restart.
+type TimerStore interface {
+	/**
+	 * Add the timer, replacing the timer with the same ID.
+	 */
+	AddTimer(*Timer)
+	/**
+	 * Remove the timer with the given ID.
+	 */
+	RemoveTimer(string)
+	/**
+	 * Returns the pending timers, the earliest due first.
+	 */
+	PendingTimers() []*Timer
+}
+
+type timerStore struct {
+	sync.RWMutex
+	timers map[string]*Timer
+}
+
+// NewTimerStore returns a TimerStore keeping the timers in memory.
+func NewTimerStore() TimerStore {
+	return &timerStore{timers: make(map[string]*Timer)}
+}
+
+func (ts *timerStore) AddTimer(timer *Timer) {
+	t := *timer
+	ts.Lock()
+	ts.timers[t.ID] = &t
+	ts.Unlock()
+}
+
+func (ts *timerStore) RemoveTimer(id string) {
+	ts.Lock()
+	delete(ts.timers, id)
+	ts.Unlock()
+}
+
+func (ts *timerStore) PendingTimers() []*Timer {
+	ts.RLock()
+	timers := make([]*Timer, 0, len(ts.timers))
+	for _, t := range ts.timers {
+		timer := *t
+		timers = append(timers, &timer)
+	}
+	ts.RUnlock()
+
+	SortTimers(timers)
+	return timers
+}
+
+// SortTimers sorts the timers by due time, then by ID.
+func SortTimers(timers []*Timer) {
+	sort.Slice(timers, func(i, j int) bool {
+		if !timers[i].Due.Equal(timers[j].Due) {
+			return timers[i].Due.Before(timers[j].Due)
+		}
+		return timers[i].ID < timers[j].ID
+	})
+}
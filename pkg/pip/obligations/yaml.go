@@ -0,0 +1,207 @@
+package obligations
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+)
+
+// ParseYAML reads an obligation written in the yaml syntax described in README.md.
+func ParseYAML(user, file string) (*Obligation, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	obligation, err := ParseYAMLBytes(user, b)
+	if err != nil {
+		return nil, err
+	}
+	obligation.Source = file
+
+	return obligation, nil
+}
+
+// ParseYAMLReader reads a yaml obligation from r.
+func ParseYAMLReader(user string, r io.Reader) (*Obligation, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseYAMLBytes(user, b)
+}
+
+// ParseYAMLBytes reads a yaml obligation. The document is validated against the same schema as the json format and
+// decodes to the same structure.
+func ParseYAMLBytes(user string, b []byte) (*Obligation, error) {
+	doc, err := yamlToJSON(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseBytes(user, doc)
+}
+
+// UnmarshalYAML lets an obligation be decoded with yaml.Unmarshal.
+func (ob *Obligation) UnmarshalYAML(value *yaml.Node) error {
+	var v interface{}
+	if err := value.Decode(&v); err != nil {
+		return err
+	}
+
+	doc, err := toJSONValue(v)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return ob.UnmarshalJSON(b)
+}
+
+// yamlToJSON converts a yaml document to the equivalent json document.
+func yamlToJSON(b []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	doc, err := toJSONValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(doc)
+}
+
+// toJSONValue converts the values decoded from yaml to those json uses. Mappings with non-string keys are not
+// representable in json.
+func toJSONValue(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			jv, err := toJSONValue(val)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = jv
+		}
+		return m, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid key %v, keys must be strings", k)
+			}
+			jv, err := toJSONValue(val)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = jv
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, val := range t {
+			jv, err := toJSONValue(val)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = jv
+		}
+		return l, nil
+	}
+
+	return v, nil
+}
+
+// marshalYAML writes a value in the yaml syntax by converting its json form, so both formats always describe the
+// same document.
+func marshalYAML(v json.Marshaler) (interface{}, error) {
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	// json is valid yaml, the node keeps the key order and the type of every value
+	node := new(yaml.Node)
+	if err := yaml.Unmarshal(b, node); err != nil {
+		return nil, err
+	}
+	toBlockStyle(node)
+
+	if node.Kind == yaml.DocumentNode && len(node.Content) == 1 {
+		return node.Content[0], nil
+	}
+
+	return node, nil
+}
+
+// toBlockStyle clears the flow style json documents are read with.
+func toBlockStyle(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		node.Style = 0
+	} else if node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
+		node.Style = 0
+	}
+
+	for _, n := range node.Content {
+		toBlockStyle(n)
+	}
+}
+
+func (ob *Obligation) MarshalYAML() (interface{}, error) {
+	return marshalYAML(ob)
+}
+
+func (r *Rule) MarshalYAML() (interface{}, error) {
+	return marshalYAML(r)
+}
+
+func (e *EventPattern) MarshalYAML() (interface{}, error) {
+	return marshalYAML(e)
+}
+
+func (r *ResponsePattern) MarshalYAML() (interface{}, error) {
+	return marshalYAML(r)
+}
+
+func (f *Function) MarshalYAML() (interface{}, error) {
+	return marshalYAML(f)
+}
+
+func (evr *EvrNode) MarshalYAML() (interface{}, error) {
+	return marshalYAML(evr)
+}
+
+func (a *AssignAction) MarshalYAML() (interface{}, error) {
+	return marshalYAML(a)
+}
+
+func (a *CreateAction) MarshalYAML() (interface{}, error) {
+	return marshalYAML(a)
+}
+
+func (a *DeleteAction) MarshalYAML() (interface{}, error) {
+	return marshalYAML(a)
+}
+
+func (a *DenyAction) MarshalYAML() (interface{}, error) {
+	return marshalYAML(a)
+}
+
+func (a *FunctionAction) MarshalYAML() (interface{}, error) {
+	return marshalYAML(a)
+}
+
+func (a *GrantAction) MarshalYAML() (interface{}, error) {
+	return marshalYAML(a)
+}
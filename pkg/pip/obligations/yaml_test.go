@@ -0,0 +1,96 @@
+package obligations
+
+import (
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	for _, name := range []string{"test_event", "test_actions"} {
+		fromJSON, err := Parse("super", "../../../test/"+name+".json")
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		fromYAML, err := Parse("super", "../../../test/"+name+".yaml")
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		if fromYAML.Source != "../../../test/"+name+".yaml" {
+			t.Errorf("unexpected source %s", fromYAML.Source)
+		}
+
+		fromYAML.Source = fromJSON.Source
+		if !reflect.DeepEqual(fromJSON, fromYAML) {
+			t.Errorf("%s decodes differently from yaml and json", name)
+		}
+	}
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	b, err := ioutil.ReadFile("../../../test/test_actions.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	fromYAML := NewObligation("super")
+	if err := yaml.Unmarshal(b, fromYAML); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	fromJSON, err := Parse("super", "../../../test/test_actions.json")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	fromJSON.Source = ""
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Errorf("test_actions decodes differently from yaml and json")
+	}
+}
+
+func TestParseInvalidYAML(t *testing.T) {
+	invalid := map[string]string{
+		"schema.yaml": `
+label: invalid
+rules:
+  - label: rule
+    event:
+      subject:
+        process: not a number
+    response:
+      actions: []
+`,
+		"keys.yml": `
+label: invalid
+rules:
+  - label: rule
+    event:
+      operations:
+        - read
+      target:
+        policyElements:
+          - 1: oa1
+    response:
+      actions: []
+`,
+		"syntax.yaml": "label: [invalid",
+	}
+
+	dir := t.TempDir()
+	for name, doc := range invalid {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(doc), 0600); err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		if _, err := Parse("super", file); err == nil {
+			t.Errorf("expected an error parsing %s", name)
+		}
+	}
+}
@@ -21,6 +21,11 @@ func New() p.Prohibitions {
 
 func (mp *prohibitions) Add(prohibition *p.Prohibition) {
 	mp.Lock()
+	defer mp.Unlock()
+	mp.add(prohibition)
+}
+
+func (mp *prohibitions) add(prohibition *p.Prohibition) {
 	if prohibition == nil {
 		panic("a nil prohibition was received when creating a prohibition")
 	}
@@ -39,7 +44,6 @@ func (mp *prohibitions) Add(prohibition *p.Prohibition) {
 	exPros = append(exPros, prohibition)
 
 	mp.prohibitions[subject] = exPros
-	mp.Unlock()
 }
 
 func (mp *prohibitions) All() []*p.Prohibition {
@@ -87,14 +91,19 @@ func (mp *prohibitions) Update(prohibitionName string, prohibition *p.Prohibitio
 	prohibition.Name = prohibitionName
 
 	mp.Lock()
-	mp.Remove(prohibition.Name)
+	defer mp.Unlock()
+	mp.remove(prohibition.Name)
 	// add the updated prohibition
-	mp.Add(prohibition.Clone())
-	mp.Unlock()
+	mp.add(prohibition)
 }
 
 func (mp *prohibitions) Remove(prohibitionName string) {
 	mp.Lock()
+	mp.remove(prohibitionName)
+	mp.Unlock()
+}
+
+func (mp *prohibitions) remove(prohibitionName string) {
 	for subject, ps := range mp.prohibitions {
 		for i := 0; i < len(ps); i++ {
 			if ps[i].Name == prohibitionName {
@@ -103,5 +112,4 @@ func (mp *prohibitions) Remove(prohibitionName string) {
 			}
 		}
 	}
-	mp.Unlock()
 }
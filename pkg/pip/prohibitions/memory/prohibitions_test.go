@@ -135,3 +135,30 @@ func TestGetProhibition(t *testing.T) {
 	}
 
 }
+
+func TestUpdateProhibition(t *testing.T) {
+	prohibs := New()
+
+	builder := p.NewBuilder("prohibition1", "123", operations.NewOperationSet("read"))
+	builder.AddContainer("1234", true)
+	prohibs.Add(builder.Build())
+
+	builder = p.NewBuilder("ignored", "321", operations.NewOperationSet("read", "write"))
+	builder.AddContainer("4321", false)
+	prohibs.Update("prohibition1", builder.Build())
+
+	if len(prohibs.All()) != 1 {
+		t.Fatalf("update should not add a prohibition")
+	}
+
+	prohibition := prohibs.Get("prohibition1")
+	if prohibition.Subject != "321" {
+		t.Errorf("subject was not updated")
+	}
+	if len(prohibs.ProhibitionsFor("123")) != 0 {
+		t.Errorf("123 should not be the subject of a prohibition after the update")
+	}
+	if _, ok := prohibition.Containers()["4321"]; !ok {
+		t.Errorf("\\'4321\\' should be in containers")
+	}
+}
@@ -0,0 +1,284 @@
+package neo4j
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jtejido/ngac/pkg/config"
+	"github.com/jtejido/ngac/pkg/operations"
+	p "github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"log"
+)
+
+var _ p.Prohibitions = &prohibitions{}
+
+// Prohibitions are stored as (:Prohibition) nodes. The name is kept under "id" so that prohibitions never match the
+// graph's (n{name:...}) lookups when both share a database. Container conditions may reference nodes that are not in
+// the graph, so they are stored as a JSON map of container name to complement flag.
+const (
+	prohibition_label  = "Prohibition"
+	prohibition_return = "p.id, p.subject, p.operations, p.intersection, p.containers"
+)
+
+type prohibitions struct {
+	config *config.Config
+	driver neo4j.Driver
+}
+
+// Accepts the config file's location for Neo4j
+func New(cfg string) (p.Prohibitions, error) {
+	conf, err := config.LoadConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(prohibitions)
+	ret.config = conf
+	ret.driver = nil
+	return ret, nil
+}
+
+func (np *prohibitions) Start() (err error) {
+	if np.driver == nil {
+		np.driver, err = neo4j.NewDriver(np.config.Uri, neo4j.BasicAuth(np.config.Username, np.config.Password, ""))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (np *prohibitions) Close() (err error) {
+	return np.driver.Close()
+}
+
+func (np *prohibitions) Add(prohibition *p.Prohibition) {
+	if prohibition == nil {
+		panic("a nil prohibition was received when creating a prohibition")
+	}
+
+	if len(prohibition.Name) == 0 {
+		panic("a nil or empty name was provided when creating a prohibition")
+	}
+
+	params, err := prohibitionParams(prohibition)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	session := np.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: np.config.Database,
+	})
+
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf("CREATE (p:%s { id: $name, subject: $subject, operations: $operations, intersection: $intersection, containers: $containers })", prohibition_label), params)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = result.Consume()
+		return nil, err
+	})
+
+	session.Close()
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (np *prohibitions) All() []*p.Prohibition {
+	return np.query(fmt.Sprintf("MATCH (p:%s) RETURN %s", prohibition_label, prohibition_return), nil)
+}
+
+func (np *prohibitions) Get(prohibitionName string) *p.Prohibition {
+	pros := np.query(fmt.Sprintf("MATCH (p:%s) WHERE toLower(p.id) = toLower($name) RETURN %s LIMIT 1", prohibition_label, prohibition_return), map[string]interface{}{
+		"name": prohibitionName,
+	})
+
+	if len(pros) == 0 {
+		return nil
+	}
+
+	return pros[0]
+}
+
+func (np *prohibitions) ProhibitionsFor(subject string) []*p.Prohibition {
+	return np.query(fmt.Sprintf("MATCH (p:%s {subject: $subject}) RETURN %s", prohibition_label, prohibition_return), map[string]interface{}{
+		"subject": subject,
+	})
+}
+
+func (np *prohibitions) Update(prohibitionName string, prohibition *p.Prohibition) {
+	if prohibition == nil {
+		panic("a null prohibition was provided when updating a prohibition")
+	}
+	prohibition.Name = prohibitionName
+
+	params, err := prohibitionParams(prohibition)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	session := np.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: np.config.Database,
+	})
+
+	// replace the stored prohibition in a single transaction
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf("MATCH (p:%s {id: $name}) DELETE p", prohibition_label), map[string]interface{}{
+			"name": prohibitionName,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err = result.Consume(); err != nil {
+			return nil, err
+		}
+
+		result, err = tx.Run(fmt.Sprintf("CREATE (p:%s { id: $name, subject: $subject, operations: $operations, intersection: $intersection, containers: $containers })", prohibition_label), params)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = result.Consume()
+		return nil, err
+	})
+
+	session.Close()
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (np *prohibitions) Remove(prohibitionName string) {
+	session := np.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: np.config.Database,
+	})
+
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf("MATCH (p:%s {id: $name}) DELETE p", prohibition_label), map[string]interface{}{
+			"name": prohibitionName,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = result.Consume()
+		return nil, err
+	})
+
+	session.Close()
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (np *prohibitions) query(cypher string, params map[string]interface{}) []*p.Prohibition {
+	session := np.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeRead,
+		DatabaseName: np.config.Database,
+	})
+
+	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		records, err := tx.Run(cypher, params)
+		if err != nil {
+			return nil, err
+		}
+
+		pros := make([]*p.Prohibition, 0)
+		for records.Next() {
+			pro, err := toProhibition(records.Record().Values)
+			if err != nil {
+				return nil, err
+			}
+			pros = append(pros, pro)
+		}
+
+		if err = records.Err(); err != nil {
+			return nil, err
+		}
+
+		return pros, nil
+	})
+
+	session.Close()
+	if err != nil {
+		log.Println(err.Error())
+		return make([]*p.Prohibition, 0)
+	}
+
+	return result.([]*p.Prohibition)
+}
+
+func prohibitionParams(prohibition *p.Prohibition) (map[string]interface{}, error) {
+	containers, err := json.Marshal(prohibition.Containers())
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]string, 0)
+	if prohibition.Operations != nil {
+		for op := range prohibition.Operations.Iter() {
+			ops = append(ops, op.(string))
+		}
+	}
+
+	return map[string]interface{}{
+		"name":         prohibition.Name,
+		"subject":      prohibition.Subject,
+		"operations":   ops,
+		"intersection": prohibition.Intersection,
+		"containers":   string(containers),
+	}, nil
+}
+
+// toProhibition builds a prohibition from the values returned in the order of prohibition_return.
+func toProhibition(values []interface{}) (*p.Prohibition, error) {
+	name, ok := values[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid prohibition name found")
+	}
+
+	subject, _ := values[1].(string)
+
+	ops := operations.NewOperationSet()
+	if v, ok := values[2].([]interface{}); ok {
+		ops = operations.NewOperationSet(v...)
+	}
+
+	intersection, _ := values[3].(bool)
+
+	containers := make(map[string]bool)
+	if v, ok := values[4].(string); ok && len(v) > 0 {
+		if err := json.Unmarshal([]byte(v), &containers); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.NewProhibition(name, subject, containers, ops, intersection), nil
+}
+
+// testing only
+func (np *prohibitions) reset() error {
+	session := np.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: np.config.Database,
+	})
+	defer session.Close()
+
+	result, err := session.Run(fmt.Sprintf("MATCH (p:%s) DELETE p", prohibition_label), map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+
+	if _, err = result.Consume(); err != nil {
+		return err
+	}
+
+	return nil
+}
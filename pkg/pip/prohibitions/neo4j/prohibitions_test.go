@@ -0,0 +1,179 @@
+package neo4j
+
+import (
+	"github.com/jtejido/ngac/pkg/operations"
+	p "github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"testing"
+)
+
+// testProhibitions connects to the database described in test_config.yaml and clears the stored prohibitions. The
+// test is skipped when no database is reachable.
+func testProhibitions(t *testing.T) *prohibitions {
+	pp, err := New(`test_config.yaml`)
+	if err != nil {
+		t.Fatalf("failed to create prohibitions: %s", err.Error())
+	}
+
+	np := pp.(*prohibitions)
+	if err = np.Start(); err != nil {
+		t.Skipf("neo4j is not available: %s", err.Error())
+	}
+
+	if err = np.driver.VerifyConnectivity(); err != nil {
+		np.Close()
+		t.Skipf("neo4j is not available: %s", err.Error())
+	}
+
+	if err = np.reset(); err != nil {
+		np.Close()
+		t.Fatalf("failed to reset prohibitions: %s", err.Error())
+	}
+
+	t.Cleanup(func() { np.Close() })
+	return np
+}
+
+func TestCreateProhibition(t *testing.T) {
+	prohibs := testProhibitions(t)
+
+	builder := p.NewBuilder("prohibition1", "123", operations.NewOperationSet("read"))
+	builder.AddContainer("1234", true)
+	prohibition := builder.Build()
+	prohibs.Add(prohibition)
+
+	builder = p.NewBuilder("p123", "sub", operations.NewOperationSet("read"))
+	builder.AddContainer("1234", true)
+	builder.AddContainer("4321", false)
+	builder.Intersection = true
+	prohibs.Add(builder.Build())
+
+	p123 := prohibs.Get("p123")
+	if p123 == nil {
+		t.Fatalf("p123 should exist")
+	}
+	if p123.Name != "p123" {
+		t.Errorf("Name do not match")
+	}
+	if p123.Subject != "sub" {
+		t.Errorf("Subject do not match")
+	}
+	if !p123.Intersection {
+		t.Errorf("Intersection should be true")
+	}
+	if !p123.Operations.Contains("read") || p123.Operations.Len() != 1 {
+		t.Errorf("Operations do not match")
+	}
+	if v, ok := p123.Containers()["1234"]; !ok {
+		t.Errorf("\\'1234\\' should be in containers")
+	} else if !v {
+		t.Errorf("\\'1234\\' should be \\'true\\'")
+	}
+	if v, ok := p123.Containers()["4321"]; !ok {
+		t.Errorf("\\'4321\\' should be in containers")
+	} else if v {
+		t.Errorf("\\'4321\\' should be \\'false\\'")
+	}
+}
+
+func TestGetProhibitions(t *testing.T) {
+	prohibs := testProhibitions(t)
+
+	builder := p.NewBuilder("prohibition1", "123", operations.NewOperationSet("read"))
+	builder.AddContainer("1234", true)
+	prohibs.Add(builder.Build())
+
+	prohibitions := prohibs.All()
+	if len(prohibitions) != 1 {
+		t.Errorf("incorrect size")
+	}
+}
+
+func TestGetProhibition(t *testing.T) {
+	prohibs := testProhibitions(t)
+
+	builder := p.NewBuilder("prohibition1", "123", operations.NewOperationSet("read"))
+	builder.AddContainer("1234", true)
+	prohibs.Add(builder.Build())
+
+	prohibition := prohibs.Get("prohibition1")
+	if prohibition == nil {
+		t.Fatalf("prohibition1 should exist")
+	}
+	if prohibition.Name != "prohibition1" {
+		t.Errorf("incorrect name")
+	}
+	if prohibition.Subject != "123" {
+		t.Errorf("incorrect subject")
+	}
+	if prohibition.Intersection {
+		t.Errorf("Intersection should be false")
+	}
+	if v, ok := prohibition.Containers()["1234"]; !ok {
+		t.Errorf("\\'1234\\' should be in containers")
+	} else if !v {
+		t.Errorf("\\'1234\\' should be \\'true\\'")
+	}
+
+	if prohibs.Get("prohibition2") != nil {
+		t.Errorf("prohibition2 should not exist")
+	}
+}
+
+func TestProhibitionsFor(t *testing.T) {
+	prohibs := testProhibitions(t)
+
+	prohibs.Add(p.NewBuilder("prohibition1", "123", operations.NewOperationSet("read")).Build())
+	prohibs.Add(p.NewBuilder("prohibition2", "123", operations.NewOperationSet("write")).Build())
+	prohibs.Add(p.NewBuilder("prohibition3", "321", operations.NewOperationSet("read")).Build())
+
+	if pros := prohibs.ProhibitionsFor("123"); len(pros) != 2 {
+		t.Errorf("expected 2 prohibitions for 123, got %d", len(pros))
+	}
+	if pros := prohibs.ProhibitionsFor("none"); len(pros) != 0 {
+		t.Errorf("expected no prohibitions for none, got %d", len(pros))
+	}
+}
+
+func TestUpdateProhibition(t *testing.T) {
+	prohibs := testProhibitions(t)
+
+	builder := p.NewBuilder("prohibition1", "123", operations.NewOperationSet("read"))
+	builder.AddContainer("1234", true)
+	prohibs.Add(builder.Build())
+
+	builder = p.NewBuilder("ignored", "321", operations.NewOperationSet("read", "write"))
+	builder.AddContainer("4321", false)
+	prohibs.Update("prohibition1", builder.Build())
+
+	if len(prohibs.All()) != 1 {
+		t.Fatalf("update should not add a prohibition")
+	}
+
+	prohibition := prohibs.Get("prohibition1")
+	if prohibition == nil {
+		t.Fatalf("prohibition1 should exist")
+	}
+	if prohibition.Subject != "321" {
+		t.Errorf("subject was not updated")
+	}
+	if !prohibition.Operations.Contains("read", "write") {
+		t.Errorf("operations were not updated")
+	}
+	if _, ok := prohibition.Containers()["1234"]; ok {
+		t.Errorf("\\'1234\\' should not be in containers")
+	}
+	if _, ok := prohibition.Containers()["4321"]; !ok {
+		t.Errorf("\\'4321\\' should be in containers")
+	}
+}
+
+func TestRemoveProhibition(t *testing.T) {
+	prohibs := testProhibitions(t)
+
+	prohibs.Add(p.NewBuilder("prohibition1", "123", operations.NewOperationSet("read")).Build())
+	prohibs.Remove("prohibition1")
+
+	if prohibs.Get("prohibition1") != nil {
+		t.Errorf("prohibition1 should not exist after removing it")
+	}
+}
@@ -0,0 +1,312 @@
+package pip
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jtejido/ngac/internal/set"
+	"github.com/jtejido/ngac/pkg/common"
+	"github.com/jtejido/ngac/pkg/operations"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"sort"
+)
+
+// The version of the policy document written by ToJSON.
+const POLICY_VERSION = 1
+
+// The document written by ToJSON and read by FromJSON.
+type Policy struct {
+	Version      int                `json:"version"`
+	Graph        *JSONGraph         `json:"graph"`
+	Prohibitions []*JSONProhibition `json:"prohibitions"`
+	Obligations  []*JSONObligation  `json:"obligations"`
+}
+
+type JSONGraph struct {
+	Nodes        []*JSONNode        `json:"nodes"`
+	Assignments  [][2]string        `json:"assignments"`
+	Associations []*JSONAssociation `json:"associations"`
+}
+
+type JSONNode struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Properties graph.PropertyMap `json:"properties"`
+}
+
+type JSONAssociation struct {
+	Source     string   `json:"source"`
+	Target     string   `json:"target"`
+	Operations []string `json:"operations"`
+}
+
+type JSONProhibition struct {
+	Name         string          `json:"name"`
+	Subject      string          `json:"subject"`
+	Containers   map[string]bool `json:"containers"`
+	Operations   []string        `json:"operations"`
+	Intersection bool            `json:"intersection"`
+}
+
+type JSONObligation struct {
+	User       string                  `json:"user"`
+	Enabled    bool                    `json:"enabled"`
+	Source     string                  `json:"source"`
+	Obligation *obligations.Obligation `json:"obligation"`
+}
+
+/**
+ * Serialize the graph, prohibitions and obligations of the given policy store to a single JSON document.
+ */
+func ToJSON(ps common.PolicyStore) ([]byte, error) {
+	g, err := graphToJSON(ps.Graph())
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{
+		Version:      POLICY_VERSION,
+		Graph:        g,
+		Prohibitions: make([]*JSONProhibition, 0),
+		Obligations:  make([]*JSONObligation, 0),
+	}
+
+	for _, p := range ps.Prohibitions().All() {
+		policy.Prohibitions = append(policy.Prohibitions, &JSONProhibition{
+			Name:         p.Name,
+			Subject:      p.Subject,
+			Containers:   p.Containers(),
+			Operations:   toStrings(p.Operations),
+			Intersection: p.Intersection,
+		})
+	}
+	sort.Slice(policy.Prohibitions, func(i, j int) bool {
+		return policy.Prohibitions[i].Name < policy.Prohibitions[j].Name
+	})
+
+	for _, o := range ps.Obligations().All() {
+		policy.Obligations = append(policy.Obligations, &JSONObligation{
+			User:       o.User,
+			Enabled:    o.Enabled,
+			Source:     o.Source,
+			Obligation: o,
+		})
+	}
+	sort.Slice(policy.Obligations, func(i, j int) bool {
+		return policy.Obligations[i].Obligation.Label < policy.Obligations[j].Obligation.Label
+	})
+
+	return json.MarshalIndent(policy, "", "  ")
+}
+
+func graphToJSON(g graph.Graph) (*JSONGraph, error) {
+	jg := &JSONGraph{
+		Nodes:        make([]*JSONNode, 0),
+		Assignments:  make([][2]string, 0),
+		Associations: make([]*JSONAssociation, 0),
+	}
+
+	for n := range g.Nodes().Iter() {
+		node := n.(*graph.Node)
+		jg.Nodes = append(jg.Nodes, &JSONNode{node.Name, node.Type.String(), node.Properties})
+	}
+	sort.Slice(jg.Nodes, func(i, j int) bool {
+		return jg.Nodes[i].Name < jg.Nodes[j].Name
+	})
+
+	for _, node := range jg.Nodes {
+		parents := toStrings(g.Parents(node.Name))
+		for _, parent := range parents {
+			jg.Assignments = append(jg.Assignments, [2]string{node.Name, parent})
+		}
+
+		if graph.ToNodeType(node.Type) != graph.UA {
+			continue
+		}
+
+		assocs, err := g.SourceAssociations(node.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		targets := make([]string, 0, len(assocs))
+		for target := range assocs {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			jg.Associations = append(jg.Associations, &JSONAssociation{node.Name, target, toStrings(assocs[target])})
+		}
+	}
+
+	return jg, nil
+}
+
+/**
+ * Load a document created by ToJSON into the given policy store. Everything is loaded in a single transaction, so
+ * nothing is committed if the document references nodes that are not defined. Nodes, prohibitions and obligations that
+ * already exist in the store are left untouched, which allows loading a policy on top of a configured PAP.
+ */
+func FromJSON(ps common.PolicyStore, b []byte) error {
+	policy := new(Policy)
+	if err := json.Unmarshal(b, policy); err != nil {
+		return err
+	}
+
+	if policy.Version != POLICY_VERSION {
+		return fmt.Errorf("unsupported policy version %d, expected %d", policy.Version, POLICY_VERSION)
+	}
+
+	return ps.RunTx(func(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations) error {
+		if policy.Graph != nil {
+			if err := graphFromJSON(g, policy.Graph); err != nil {
+				return err
+			}
+		}
+
+		for _, jp := range policy.Prohibitions {
+			if p.Get(jp.Name) != nil {
+				continue
+			}
+
+			ops := operations.NewOperationSet()
+			for _, op := range jp.Operations {
+				ops.Add(op)
+			}
+
+			p.Add(prohibitions.NewProhibition(jp.Name, jp.Subject, jp.Containers, ops, jp.Intersection))
+		}
+
+		for _, jo := range policy.Obligations {
+			if jo.Obligation == nil {
+				return fmt.Errorf("obligation entry is missing its definition")
+			}
+
+			obligation := jo.Obligation
+			if o.Get(obligation.Label) != nil {
+				continue
+			}
+
+			obligation.User = jo.User
+			obligation.Source = jo.Source
+			obligation.Enabled = jo.Enabled
+			o.Add(obligation, jo.Enabled)
+		}
+
+		return nil
+	})
+}
+
+func graphFromJSON(g graph.Graph, jg *JSONGraph) error {
+	parents := make(map[string][]string)
+	for _, assignment := range jg.Assignments {
+		parents[assignment[0]] = append(parents[assignment[0]], assignment[1])
+	}
+
+	// create the policy classes first, every other node needs an existing parent to be created
+	pending := make([]*JSONNode, 0)
+	for _, node := range jg.Nodes {
+		t := graph.ToNodeType(node.Type)
+		if t == graph.NOOP {
+			return fmt.Errorf("node %s has an unknown type %q", node.Name, node.Type)
+		}
+
+		if g.Exists(node.Name) {
+			continue
+		}
+
+		if t == graph.PC {
+			if _, err := g.CreatePolicyClass(node.Name, node.Properties); err != nil {
+				return err
+			}
+			continue
+		}
+
+		pending = append(pending, node)
+	}
+
+	// create the remaining nodes once one of their parents exists
+	for len(pending) > 0 {
+		remaining := make([]*JSONNode, 0)
+		for _, node := range pending {
+			var parent string
+			for _, p := range parents[node.Name] {
+				if g.Exists(p) {
+					parent = p
+					break
+				}
+			}
+
+			if len(parent) == 0 {
+				remaining = append(remaining, node)
+				continue
+			}
+
+			if _, err := g.CreateNode(node.Name, graph.ToNodeType(node.Type), node.Properties, parent); err != nil {
+				return err
+			}
+		}
+
+		if len(remaining) == len(pending) {
+			return fmt.Errorf("node %s is not assigned to any node that can be created", remaining[0].Name)
+		}
+
+		pending = remaining
+	}
+
+	for _, assignment := range jg.Assignments {
+		if err := checkExists(g, assignment[0], assignment[1]); err != nil {
+			return err
+		}
+
+		if g.IsAssigned(assignment[0], assignment[1]) {
+			continue
+		}
+
+		if err := g.Assign(assignment[0], assignment[1]); err != nil {
+			return err
+		}
+	}
+
+	for _, assoc := range jg.Associations {
+		if err := checkExists(g, assoc.Source, assoc.Target); err != nil {
+			return err
+		}
+
+		ops := operations.NewOperationSet()
+		for _, op := range assoc.Operations {
+			ops.Add(op)
+		}
+
+		if err := g.Associate(assoc.Source, assoc.Target, ops); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkExists makes sure the given nodes exist before a relation between them is queued, the transaction only
+// applies its changes on commit so a missing node has to be caught here for nothing to be committed.
+func checkExists(g graph.Graph, names ...string) error {
+	for _, name := range names {
+		if !g.Exists(name) {
+			return fmt.Errorf("node %s does not exist in the graph", name)
+		}
+	}
+
+	return nil
+}
+
+func toStrings(s set.Set) []string {
+	slice := s.ToSlice()
+	ret := make([]string, len(slice))
+	for i, v := range slice {
+		ret[i] = v.(string)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
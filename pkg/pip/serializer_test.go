@@ -0,0 +1,168 @@
+package pip
+
+import (
+	"encoding/json"
+	"github.com/jtejido/ngac/pkg/operations"
+	"github.com/jtejido/ngac/pkg/pip/graph"
+	gm "github.com/jtejido/ngac/pkg/pip/graph/memory"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	obm "github.com/jtejido/ngac/pkg/pip/obligations/memory"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+	pm "github.com/jtejido/ngac/pkg/pip/prohibitions/memory"
+	"testing"
+)
+
+const testObligation = `{
+  "label": "test",
+  "rules": [{
+    "label": "rule1",
+    "event": {
+      "subject": {"user": "u1"},
+      "operations": ["assign to"],
+      "target": {"policyElements": [{"name": "oa1", "type": "OA"}]}
+    },
+    "response": {
+      "actions": [{
+        "function": {
+          "name": "create_node",
+          "args": ["pc1", "PC", "new", "OA", {"function": {"name": "to_props", "args": ["k=v"]}}]
+        }
+      }]
+    }
+  }]
+}`
+
+func testPIP(t *testing.T) *PIP {
+	p := NewPIP(gm.New(), pm.New(), obm.New())
+	g := p.Graph()
+	if _, err := g.CreatePolicyClass("pc1", graph.PropertyMap{"k": "v"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := g.CreateNode("oa1", graph.OA, nil, "pc1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := g.CreateNode("oa2", graph.OA, nil, "pc1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := g.CreateNode("o1", graph.O, nil, "oa1", "oa2"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := g.CreateNode("ua1", graph.UA, nil, "pc1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := g.CreateNode("u1", graph.U, graph.PropertyMap{"a": "b"}, "ua1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := g.Associate("ua1", "oa1", operations.NewOperationSet("read", "write")); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	p.Prohibitions().Add(prohibitions.NewProhibition("deny1", "u1", map[string]bool{"oa1": false, "oa2": true}, operations.NewOperationSet("write"), true))
+
+	obligation := new(obligations.Obligation)
+	if err := json.Unmarshal([]byte(testObligation), obligation); err != nil {
+		t.Fatalf("%s", err)
+	}
+	obligation.User = "u1"
+	p.Obligations().Add(obligation, false)
+
+	return p
+}
+
+func TestToJSONFromJSON(t *testing.T) {
+	p := testPIP(t)
+	b, err := ToJSON(p)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	loaded := NewPIP(gm.New(), pm.New(), obm.New())
+	if err := FromJSON(loaded, b); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	g := loaded.Graph()
+	if g.Nodes().Len() != 6 {
+		t.Errorf("expected 6 nodes, got %d", g.Nodes().Len())
+	}
+	if !g.PolicyClasses().Contains("pc1") {
+		t.Errorf("pc1 should be a policy class")
+	}
+	if !g.IsAssigned("o1", "oa1") || !g.IsAssigned("o1", "oa2") {
+		t.Errorf("o1 should be assigned to oa1 and oa2")
+	}
+	u1, err := g.Node("u1")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if u1.Properties["a"] != "b" {
+		t.Errorf("expected u1 to keep its properties")
+	}
+	assocs, err := g.SourceAssociations("ua1")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if ops, ok := assocs["oa1"]; !ok || !ops.Contains("read", "write") || ops.Len() != 2 {
+		t.Errorf("expected ua1 to be associated with oa1 with read and write")
+	}
+
+	pro := loaded.Prohibitions().Get("deny1")
+	if pro == nil {
+		t.Fatalf("expected deny1 to be loaded")
+	}
+	if !pro.Intersection || pro.Subject != "u1" || !pro.Operations.Contains("write") {
+		t.Errorf("unexpected prohibition %v", pro)
+	}
+	if c := pro.Containers(); len(c) != 2 || c["oa1"] || !c["oa2"] {
+		t.Errorf("unexpected prohibition containers %v", c)
+	}
+
+	ob := loaded.Obligations().Get("test")
+	if ob == nil {
+		t.Fatalf("expected obligation test to be loaded")
+	}
+	if ob.Enabled {
+		t.Errorf("expected obligation test to stay disabled")
+	}
+	if ob.User != "u1" {
+		t.Errorf("expected obligation user u1, got %s", ob.User)
+	}
+	action, ok := ob.Rules[0].ResponsePattern.Actions[0].(*obligations.FunctionAction)
+	if !ok {
+		t.Fatalf("expected a function action")
+	}
+	if f := action.Function.Args[4].Function; f == nil || f.Name != "to_props" {
+		t.Errorf("expected the nested to_props function to be kept")
+	}
+
+	// exporting the loaded policy should give back the same document
+	b2, err := ToJSON(loaded)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if string(b) != string(b2) {
+		t.Errorf("expected the same document after a round trip\n%s\n%s", b, b2)
+	}
+}
+
+func TestFromJSONRollback(t *testing.T) {
+	doc := `{
+  "version": 1,
+  "graph": {
+    "nodes": [{"name": "pc1", "type": "PC"}, {"name": "oa1", "type": "OA"}, {"name": "o1", "type": "O"}],
+    "assignments": [["oa1", "pc1"], ["o1", "oa1"], ["o1", "missing"]]
+  }
+}`
+
+	p := NewPIP(gm.New(), pm.New(), obm.New())
+	if err := FromJSON(p, []byte(doc)); err == nil {
+		t.Fatalf("expected an error loading an assignment to a missing node")
+	}
+	if p.Graph().Nodes().Len() != 0 {
+		t.Errorf("expected nothing to be committed, got %d nodes", p.Graph().Nodes().Len())
+	}
+
+	if err := FromJSON(p, []byte(`{"version": 2}`)); err == nil {
+		t.Errorf("expected an error for an unsupported version")
+	}
+}
@@ -26,6 +26,19 @@ func (c *txGraphCommitterImpl) Id() string {
     return c.id
 }
 
+type txGraphAssignCommitter struct {
+    c             Committer
+    parent, child string
+}
+
+func (c *txGraphAssignCommitter) Committer() Committer {
+    return c.c
+}
+
+func (c *txGraphAssignCommitter) Id() string {
+    return "assign"
+}
+
 type txGraphDeassignCommitter struct {
     c             Committer
     parent, child string
@@ -108,6 +121,9 @@ func (tx *TxGraph) CreateNode(name string, t graph.NodeType, properties graph.Pr
         }
     }
 
+    // record the initial assignments so they are visible to the rest of the tx
+    tx.assignments[name] = parents.Clone()
+
     tx.cmds = append(tx.cmds, &txGraphCommitterImpl{
         c: func() error {
             it := parents.Iterator()
@@ -138,10 +154,13 @@ func (tx *TxGraph) UpdateNode(name string, properties graph.PropertyMap) (err er
     if v, found := tx.nodes[name]; found {
         node = v
     } else if tx.targetGraph.Exists(name) {
-        node, err = tx.targetGraph.Node(name)
+        var target *graph.Node
+        target, err = tx.targetGraph.Node(name)
         if err != nil {
             return
         }
+        // the target graph keeps its node untouched until the commit
+        node = graph.NewNodeWithFields(target.Name, target.Type, target.Properties)
     } else {
         return fmt.Errorf("node %s does not exist", name)
     }
@@ -263,6 +282,37 @@ func (tx *TxGraph) Search(t graph.NodeType, properties graph.PropertyMap) set.Se
     return s
 }
 
+// Query runs the query on the target graph and the nodes of the tx, the nodes of the tx taking precedence.
+func (tx *TxGraph) Query(q *graph.Query) ([]*graph.Node, error) {
+    match, err := q.Matcher()
+    if err != nil {
+        return nil, err
+    }
+
+    // the page can only be taken once the nodes of the tx are merged in
+    unpaged := *q
+    unpaged.Offset, unpaged.Limit = 0, 0
+    search, err := graph.Find(tx.targetGraph, &unpaged)
+    if err != nil {
+        return nil, err
+    }
+
+    results := make([]*graph.Node, 0, len(search))
+    for _, node := range tx.nodes {
+        if match(node) {
+            results = append(results, graph.NewNodeFromNode(node))
+        }
+    }
+    for _, node := range search {
+        if _, found := tx.nodes[node.Name]; !found {
+            results = append(results, graph.NewNodeFromNode(node))
+        }
+    }
+    q.Sort(results)
+
+    return q.Page(results), nil
+}
+
 func (tx *TxGraph) txSearch(t graph.NodeType, properties graph.PropertyMap) map[string]*graph.Node {
     if properties == nil {
         properties = graph.NewPropertyMap()
@@ -341,7 +391,7 @@ func (tx *TxGraph) Parents(name string) set.Set {
                 continue
             }
 
-            parents.Remove(txCmd.(*txGraphDeassignCommitter).child)
+            parents.Remove(txCmd.(*txGraphDeassignCommitter).parent)
         }
     }
 
@@ -356,11 +406,12 @@ func (tx *TxGraph) Assign(child, parent string) error {
     parents.Add(parent)
     tx.assignments[child] = parents
 
-    tx.cmds = append(tx.cmds, &txGraphCommitterImpl{
+    tx.cmds = append(tx.cmds, &txGraphAssignCommitter{
         c: func() error {
             return tx.targetGraph.Assign(child, parent)
         },
-        id: "assign",
+        child:  child,
+        parent: parent,
     })
 
     return nil
@@ -500,12 +551,32 @@ func (tx *TxGraph) TargetAssociations(target string) (map[string]operations.Oper
 
 func (tx *TxGraph) Commit() (err error) {
     tx.Lock()
+    defer tx.Unlock()
+    // the assignments are checked against the graph as the tx leaves it, so a cycle fails the commit before any change
+    // is made to the target graph
+    parents := func(name string) []string {
+        if !tx.Exists(name) {
+            return nil
+        }
+        names := make([]string, 0)
+        for parent := range tx.Parents(name).Iter() {
+            names = append(names, parent.(string))
+        }
+        return names
+    }
+    for _, txCmd := range tx.cmds {
+        if assign, ok := txCmd.(*txGraphAssignCommitter); ok && tx.IsAssigned(assign.child, assign.parent) {
+            if err = graph.CheckCycle(assign.child, assign.parent, parents); err != nil {
+                return
+            }
+        }
+    }
+
     for _, txCmd := range tx.cmds {
         f := txCmd.Committer()
         if err = f(); err != nil {
             return
         }
     }
-    tx.Unlock()
     return nil
 }
@@ -1,6 +1,7 @@
 package tx
 
 import (
+    "fmt"
     "github.com/jtejido/ngac/pkg/pip/obligations"
     "sync"
 )
@@ -9,7 +10,8 @@ type TxObligations struct {
     sync.RWMutex
     targetObligations obligations.Obligations
     cmds              []Committer
-    txObligations     map[string]*obligations.Obligation
+    // obligations changed in the transaction, a nil value marks a removed obligation
+    txObligations map[string]*obligations.Obligation
 }
 
 func NewTxObligations(o obligations.Obligations) *TxObligations {
@@ -33,9 +35,9 @@ func (to *TxObligations) Add(o *obligations.Obligation, enable bool) {
 
 func (to *TxObligations) Get(label string) *obligations.Obligation {
     to.RLock()
-    obligation := to.targetObligations.Get(label)
-    if obligation == nil {
-        obligation = to.txObligations[label]
+    obligation, ok := to.txObligations[label]
+    if !ok {
+        obligation = to.targetObligations.Get(label)
     }
     to.RUnlock()
     return obligation
@@ -43,9 +45,16 @@ func (to *TxObligations) Get(label string) *obligations.Obligation {
 
 func (to *TxObligations) All() []*obligations.Obligation {
     to.RLock()
-    all := append([]*obligations.Obligation{}, to.targetObligations.All()...)
+    all := make([]*obligations.Obligation, 0)
+    for _, v := range to.targetObligations.All() {
+        if _, ok := to.txObligations[v.Label]; !ok {
+            all = append(all, v)
+        }
+    }
     for _, v := range to.txObligations {
-        all = append(all, v)
+        if v != nil {
+            all = append(all, v)
+        }
     }
     to.RUnlock()
     return all
@@ -57,7 +66,12 @@ func (to *TxObligations) Update(label string, o *obligations.Obligation) {
         to.targetObligations.Update(label, o)
         return nil
     })
-    to.txObligations[label] = o
+    updatedLabel := label
+    if len(o.Label) > 0 && o.Label != label {
+        to.txObligations[label] = nil
+        updatedLabel = o.Label
+    }
+    to.txObligations[updatedLabel] = o
     to.Unlock()
 }
 
@@ -68,7 +82,7 @@ func (to *TxObligations) Remove(label string) {
         to.targetObligations.Remove(label)
         return nil
     })
-    delete(to.txObligations, label)
+    to.txObligations[label] = nil
     to.Unlock()
 }
 
@@ -83,9 +97,14 @@ func (to *TxObligations) SetEnable(label string, enabled bool) {
 
 func (to *TxObligations) GetEnabled() []*obligations.Obligation {
     to.RLock()
-    enabled := to.targetObligations.GetEnabled()
+    enabled := make([]*obligations.Obligation, 0)
+    for _, o := range to.targetObligations.GetEnabled() {
+        if _, ok := to.txObligations[o.Label]; !ok {
+            enabled = append(enabled, o)
+        }
+    }
     for _, o := range to.txObligations {
-        if o.Enabled {
+        if o != nil && o.Enabled {
             enabled = append(enabled, o)
         }
     }
@@ -93,6 +112,67 @@ func (to *TxObligations) GetEnabled() []*obligations.Obligation {
     return enabled
 }
 
+// updateRules checks the change on the transaction's view of the obligation, the change is made to the target
+// obligations by the command when committing.
+func (to *TxObligations) updateRules(label string, change func(*obligations.Obligation) error, cmd Committer) error {
+    obligation := to.Get(label)
+    if obligation == nil {
+        return fmt.Errorf("obligation %s does not exist", label)
+    }
+
+    updated := obligation.Clone()
+    if err := change(updated); err != nil {
+        return err
+    }
+
+    to.Lock()
+    to.cmds = append(to.cmds, cmd)
+    to.txObligations[label] = updated
+    to.Unlock()
+    return nil
+}
+
+func (to *TxObligations) AddRule(label string, rule *obligations.Rule) error {
+    return to.updateRules(label, func(o *obligations.Obligation) error {
+        return o.AddRule(rule)
+    }, func() error {
+        return to.targetObligations.AddRule(label, rule)
+    })
+}
+
+func (to *TxObligations) RemoveRule(label, ruleLabel string) error {
+    return to.updateRules(label, func(o *obligations.Obligation) error {
+        return o.RemoveRule(ruleLabel)
+    }, func() error {
+        return to.targetObligations.RemoveRule(label, ruleLabel)
+    })
+}
+
+func (to *TxObligations) ReplaceRule(label, ruleLabel string, rule *obligations.Rule) error {
+    return to.updateRules(label, func(o *obligations.Obligation) error {
+        return o.ReplaceRule(ruleLabel, rule)
+    }, func() error {
+        return to.targetObligations.ReplaceRule(label, ruleLabel, rule)
+    })
+}
+
+func (to *TxObligations) SetRuleEnable(label, ruleLabel string, enabled bool) error {
+    return to.updateRules(label, func(o *obligations.Obligation) error {
+        return o.SetRuleEnable(ruleLabel, enabled)
+    }, func() error {
+        return to.targetObligations.SetRuleEnable(label, ruleLabel, enabled)
+    })
+}
+
+func (to *TxObligations) Rules(label string) ([]*obligations.Rule, error) {
+    obligation := to.Get(label)
+    if obligation == nil {
+        return nil, fmt.Errorf("obligation %s does not exist", label)
+    }
+
+    return append([]*obligations.Rule{}, obligation.Rules...), nil
+}
+
 func (to *TxObligations) Commit() (err error) {
     to.RLock()
     defer to.RUnlock()
@@ -0,0 +1,161 @@
+package wal
+
+import (
+	"github.com/jtejido/ngac/pkg/operations"
+	g "github.com/jtejido/ngac/pkg/pip/graph"
+)
+
+var (
+	_ g.Graph       = &graph{}
+	_ g.Snapshotter = &graph{}
+	_ g.Querier     = &graph{}
+)
+
+// graph journals every successful change made to the wrapped memory graph, reads go straight to it.
+type graph struct {
+	g.Graph
+	store *Store
+}
+
+// Snapshot returns a snapshot of the wrapped memory graph, there is nothing to journal on a read-only graph.
+func (jg *graph) Snapshot() g.Graph {
+	return g.Snapshot(jg.Graph)
+}
+
+func (jg *graph) Query(q *g.Query) ([]*g.Node, error) {
+	return g.Find(jg.Graph, q)
+}
+
+func (jg *graph) CreatePolicyClass(name string, properties g.PropertyMap) (*g.Node, error) {
+	jg.store.Lock()
+	defer jg.store.Unlock()
+
+	node, err := jg.Graph.CreatePolicyClass(name, properties)
+	if err != nil {
+		return nil, err
+	}
+
+	return node, jg.store.commit(&record{Op: op_create_policy_class, Name: name, Properties: node.Properties}, func() {
+		jg.Graph.RemoveNode(name)
+	})
+}
+
+func (jg *graph) CreateNode(name string, t g.NodeType, properties g.PropertyMap, initialParent string, additionalParents ...string) (*g.Node, error) {
+	jg.store.Lock()
+	defer jg.store.Unlock()
+
+	existed := jg.Graph.Exists(name)
+	node, err := jg.Graph.CreateNode(name, t, properties, initialParent, additionalParents...)
+	if err != nil {
+		// the node is added before it is assigned, a failed assignment to a later parent leaves it behind
+		if !existed && jg.Graph.Exists(name) {
+			jg.Graph.RemoveNode(name)
+		}
+		return nil, err
+	}
+
+	return node, jg.store.commit(&record{
+		Op:         op_create_node,
+		Name:       name,
+		Type:       t.String(),
+		Properties: node.Properties,
+		Parents:    append([]string{initialParent}, additionalParents...),
+	}, func() {
+		jg.Graph.RemoveNode(name)
+	})
+}
+
+func (jg *graph) UpdateNode(name string, properties g.PropertyMap) error {
+	jg.store.Lock()
+	defer jg.store.Unlock()
+
+	old, err := jg.Graph.Node(name)
+	if err != nil {
+		return err
+	}
+
+	if err := jg.Graph.UpdateNode(name, properties); err != nil {
+		return err
+	}
+
+	return jg.store.commit(&record{Op: op_update_node, Name: name, Properties: properties}, func() {
+		jg.Graph.UpdateNode(name, old.Properties)
+	})
+}
+
+// RemoveNode can't fail once the node exists, so it is journaled first.
+func (jg *graph) RemoveNode(name string) {
+	jg.store.Lock()
+	defer jg.store.Unlock()
+
+	if !jg.Graph.Exists(name) {
+		return
+	}
+
+	jg.store.writeAhead(&record{Op: op_remove_node, Name: name}, func() {
+		jg.Graph.RemoveNode(name)
+	})
+}
+
+func (jg *graph) Assign(child, parent string) error {
+	jg.store.Lock()
+	defer jg.store.Unlock()
+
+	if err := jg.Graph.Assign(child, parent); err != nil {
+		return err
+	}
+
+	return jg.store.commit(&record{Op: op_assign, Name: child, Target: parent}, func() {
+		jg.Graph.Deassign(child, parent)
+	})
+}
+
+func (jg *graph) Deassign(child, parent string) error {
+	jg.store.Lock()
+	defer jg.store.Unlock()
+
+	if err := jg.Graph.Deassign(child, parent); err != nil {
+		return err
+	}
+
+	return jg.store.commit(&record{Op: op_deassign, Name: child, Target: parent}, func() {
+		jg.Graph.Assign(child, parent)
+	})
+}
+
+func (jg *graph) Associate(ua, target string, ops operations.OperationSet) error {
+	jg.store.Lock()
+	defer jg.store.Unlock()
+
+	undo := jg.savedAssociation(ua, target)
+	if err := jg.Graph.Associate(ua, target, ops); err != nil {
+		return err
+	}
+
+	return jg.store.commit(&record{Op: op_associate, Name: ua, Target: target, Operations: toStrings(ops)}, undo)
+}
+
+func (jg *graph) Dissociate(ua, target string) error {
+	jg.store.Lock()
+	defer jg.store.Unlock()
+
+	undo := jg.savedAssociation(ua, target)
+	if err := jg.Graph.Dissociate(ua, target); err != nil {
+		return err
+	}
+
+	return jg.store.commit(&record{Op: op_dissociate, Name: ua, Target: target}, undo)
+}
+
+// savedAssociation returns a function putting back the association between the nodes as it is now.
+func (jg *graph) savedAssociation(ua, target string) func() {
+	assocs, _ := jg.Graph.SourceAssociations(ua)
+	ops, ok := assocs[target]
+	return func() {
+		if ok {
+			jg.Graph.Associate(ua, target, ops)
+		} else {
+			jg.Graph.Dissociate(ua, target)
+		}
+	}
+}
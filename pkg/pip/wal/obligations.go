@@ -0,0 +1,155 @@
+package wal
+
+import (
+	ob "github.com/jtejido/ngac/pkg/pip/obligations"
+)
+
+var (
+	_ ob.Obligations = &obligationsStore{}
+	_ ob.TimerStore  = &obligationsStore{}
+)
+
+// obligationsStore journals every change made to the wrapped memory obligations, reads go straight to it.
+type obligationsStore struct {
+	ob.Obligations
+	store *Store
+}
+
+func (jo *obligationsStore) Add(obligation *ob.Obligation, enable bool) {
+	jo.store.Lock()
+	defer jo.store.Unlock()
+
+	undo := jo.saved(obligation.Label)
+	jo.Obligations.Add(obligation, enable)
+	jo.store.logCommit(&record{Op: op_add_obligation, Enabled: enable, Obligation: toJSONObligation(obligation)}, undo)
+}
+
+func (jo *obligationsStore) Update(label string, obligation *ob.Obligation) {
+	jo.store.Lock()
+	defer jo.store.Unlock()
+
+	// the update may relabel the obligation, replacing the one under the new label
+	undo := jo.saved(label, obligation.Label)
+	jo.Obligations.Update(label, obligation)
+	jo.store.logCommit(&record{Op: op_update_obligation, Name: label, Obligation: toJSONObligation(obligation)}, undo)
+}
+
+func (jo *obligationsStore) Remove(label string) {
+	jo.store.Lock()
+	defer jo.store.Unlock()
+
+	undo := jo.saved(label)
+	jo.Obligations.Remove(label)
+	jo.store.logCommit(&record{Op: op_remove_obligation, Name: label}, undo)
+}
+
+func (jo *obligationsStore) SetEnable(label string, enabled bool) {
+	jo.store.Lock()
+	defer jo.store.Unlock()
+
+	undo := jo.saved(label)
+	jo.Obligations.SetEnable(label, enabled)
+	jo.store.logCommit(&record{Op: op_set_enable, Name: label, Enabled: enabled}, undo)
+}
+
+// The rule changes are journaled as updates of the whole obligation.
+func (jo *obligationsStore) updateRules(label string, change func() error) error {
+	jo.store.Lock()
+	defer jo.store.Unlock()
+
+	undo := jo.saved(label)
+	if err := change(); err != nil {
+		return err
+	}
+	return jo.store.commit(&record{Op: op_update_obligation, Name: label, Obligation: toJSONObligation(jo.Obligations.Get(label))}, undo)
+}
+
+// saved returns a function putting back the obligations with the given labels as they are now.
+func (jo *obligationsStore) saved(labels ...string) func() {
+	prev := make(map[string]*ob.Obligation, len(labels))
+	for _, label := range labels {
+		// a copy, SetEnable changes the stored obligation in place
+		if o := jo.Obligations.Get(label); o != nil {
+			prev[label] = o.Clone()
+		} else {
+			prev[label] = nil
+		}
+	}
+
+	return func() {
+		for label, o := range prev {
+			jo.Obligations.Remove(label)
+			if o != nil {
+				jo.Obligations.Add(o, o.Enabled)
+			}
+		}
+	}
+}
+
+func (jo *obligationsStore) AddRule(label string, rule *ob.Rule) error {
+	return jo.updateRules(label, func() error {
+		return jo.Obligations.AddRule(label, rule)
+	})
+}
+
+func (jo *obligationsStore) RemoveRule(label, ruleLabel string) error {
+	return jo.updateRules(label, func() error {
+		return jo.Obligations.RemoveRule(label, ruleLabel)
+	})
+}
+
+func (jo *obligationsStore) ReplaceRule(label, ruleLabel string, rule *ob.Rule) error {
+	return jo.updateRules(label, func() error {
+		return jo.Obligations.ReplaceRule(label, ruleLabel, rule)
+	})
+}
+
+func (jo *obligationsStore) SetRuleEnable(label, ruleLabel string, enabled bool) error {
+	return jo.updateRules(label, func() error {
+		return jo.Obligations.SetRuleEnable(label, ruleLabel, enabled)
+	})
+}
+
+// timers returns the timer store of the wrapped memory obligations.
+func (jo *obligationsStore) timers() ob.TimerStore {
+	return jo.Obligations.(ob.TimerStore)
+}
+
+func (jo *obligationsStore) AddTimer(timer *ob.Timer) {
+	jo.store.Lock()
+	defer jo.store.Unlock()
+
+	undo := jo.savedTimer(timer.ID)
+	jo.timers().AddTimer(timer)
+	jo.store.logCommit(&record{Op: op_add_timer, Timer: timer}, undo)
+}
+
+func (jo *obligationsStore) RemoveTimer(id string) {
+	jo.store.Lock()
+	defer jo.store.Unlock()
+
+	undo := jo.savedTimer(id)
+	jo.timers().RemoveTimer(id)
+	jo.store.logCommit(&record{Op: op_remove_timer, Name: id}, undo)
+}
+
+// savedTimer returns a function putting back the timer with the given ID as it is now.
+func (jo *obligationsStore) savedTimer(id string) func() {
+	var prev *ob.Timer
+	for _, timer := range jo.timers().PendingTimers() {
+		if timer.ID == id {
+			prev = timer
+		}
+	}
+
+	return func() {
+		jo.timers().RemoveTimer(id)
+		if prev != nil {
+			jo.timers().AddTimer(prev)
+		}
+	}
+}
+
+func (jo *obligationsStore) PendingTimers() []*ob.Timer {
+	return jo.timers().PendingTimers()
+}
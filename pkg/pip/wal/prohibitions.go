@@ -0,0 +1,51 @@
+package wal
+
+import (
+	p "github.com/jtejido/ngac/pkg/pip/prohibitions"
+)
+
+var _ p.Prohibitions = &prohibitionsStore{}
+
+// prohibitionsStore journals every change made to the wrapped memory prohibitions, reads go straight to it.
+type prohibitionsStore struct {
+	p.Prohibitions
+	store *Store
+}
+
+func (jp *prohibitionsStore) Add(prohibition *p.Prohibition) {
+	jp.store.Lock()
+	defer jp.store.Unlock()
+
+	undo := jp.saved(prohibition.Name)
+	jp.Prohibitions.Add(prohibition)
+	jp.store.logCommit(&record{Op: op_add_prohibition, Prohibition: toJSONProhibition(prohibition)}, undo)
+}
+
+func (jp *prohibitionsStore) Update(prohibitionName string, prohibition *p.Prohibition) {
+	jp.store.Lock()
+	defer jp.store.Unlock()
+
+	undo := jp.saved(prohibitionName)
+	jp.Prohibitions.Update(prohibitionName, prohibition)
+	jp.store.logCommit(&record{Op: op_update_prohibition, Name: prohibitionName, Prohibition: toJSONProhibition(prohibition)}, undo)
+}
+
+func (jp *prohibitionsStore) Remove(prohibitionName string) {
+	jp.store.Lock()
+	defer jp.store.Unlock()
+
+	undo := jp.saved(prohibitionName)
+	jp.Prohibitions.Remove(prohibitionName)
+	jp.store.logCommit(&record{Op: op_remove_prohibition, Name: prohibitionName}, undo)
+}
+
+// saved returns a function putting back the prohibition with the given name as it is now.
+func (jp *prohibitionsStore) saved(prohibitionName string) func() {
+	prev := jp.Prohibitions.Get(prohibitionName)
+	return func() {
+		jp.Prohibitions.Remove(prohibitionName)
+		if prev != nil {
+			jp.Prohibitions.Add(prev)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package wal
+
+import (
+	"fmt"
+	"github.com/jtejido/ngac/pkg/operations"
+	"github.com/jtejido/ngac/pkg/pip"
+	g "github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"sort"
+)
+
+const (
+	op_create_policy_class = "create_policy_class"
+	op_create_node         = "create_node"
+	op_update_node         = "update_node"
+	op_remove_node         = "remove_node"
+	op_assign              = "assign"
+	op_deassign            = "deassign"
+	op_associate           = "associate"
+	op_dissociate          = "dissociate"
+
+	op_add_prohibition    = "add_prohibition"
+	op_update_prohibition = "update_prohibition"
+	op_remove_prohibition = "remove_prohibition"
+
+	op_add_obligation    = "add_obligation"
+	op_update_obligation = "update_obligation"
+	op_remove_obligation = "remove_obligation"
+	op_set_enable        = "set_enable"
+
+	op_add_timer    = "add_timer"
+	op_remove_timer = "remove_timer"
+)
+
+// A single journaled change. Only the fields used by the operation are set.
+type record struct {
+	Seq         uint64               `json:"seq"`
+	Op          string               `json:"op"`
+	Name        string               `json:"name,omitempty"`
+	Target      string               `json:"target,omitempty"`
+	Type        string               `json:"type,omitempty"`
+	Properties  g.PropertyMap        `json:"properties,omitempty"`
+	Parents     []string             `json:"parents,omitempty"`
+	Operations  []string             `json:"operations,omitempty"`
+	Enabled     bool                 `json:"enabled,omitempty"`
+	Prohibition *pip.JSONProhibition `json:"prohibition,omitempty"`
+	Obligation  *pip.JSONObligation  `json:"obligation,omitempty"`
+	Timer       *obligations.Timer   `json:"timer,omitempty"`
+}
+
+func toStrings(ops operations.OperationSet) []string {
+	ret := make([]string, 0)
+	if ops == nil {
+		return ret
+	}
+
+	for op := range ops.Iter() {
+		ret = append(ret, op.(string))
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+func toOperationSet(ops []string) operations.OperationSet {
+	set := operations.NewOperationSet()
+	for _, op := range ops {
+		set.Add(op)
+	}
+
+	return set
+}
+
+func toJSONProhibition(p *prohibitions.Prohibition) *pip.JSONProhibition {
+	return &pip.JSONProhibition{
+		Name:         p.Name,
+		Subject:      p.Subject,
+		Containers:   p.Containers(),
+		Operations:   toStrings(p.Operations),
+		Intersection: p.Intersection,
+	}
+}
+
+func fromJSONProhibition(p *pip.JSONProhibition) *prohibitions.Prohibition {
+	return prohibitions.NewProhibition(p.Name, p.Subject, p.Containers, toOperationSet(p.Operations), p.Intersection)
+}
+
+func toJSONObligation(o *obligations.Obligation) *pip.JSONObligation {
+	return &pip.JSONObligation{
+		User:       o.User,
+		Enabled:    o.Enabled,
+		Source:     o.Source,
+		Obligation: o,
+	}
+}
+
+func fromJSONObligation(o *pip.JSONObligation) (*obligations.Obligation, error) {
+	if o == nil || o.Obligation == nil {
+		return nil, fmt.Errorf("obligation is missing its definition")
+	}
+
+	obligation := o.Obligation
+	obligation.User = o.User
+	obligation.Enabled = o.Enabled
+	obligation.Source = o.Source
+
+	return obligation, nil
+}
+
+// apply the change to the given stores.
+func (r *record) apply(ps *pip.PIP) error {
+	graph := ps.Graph()
+	switch r.Op {
+	case op_create_policy_class:
+		_, err := graph.CreatePolicyClass(r.Name, r.Properties)
+		return err
+	case op_create_node:
+		if len(r.Parents) == 0 {
+			return fmt.Errorf("node %s has no parent", r.Name)
+		}
+		_, err := graph.CreateNode(r.Name, g.ToNodeType(r.Type), r.Properties, r.Parents[0], r.Parents[1:]...)
+		return err
+	case op_update_node:
+		return graph.UpdateNode(r.Name, r.Properties)
+	case op_remove_node:
+		graph.RemoveNode(r.Name)
+		return nil
+	case op_assign:
+		return graph.Assign(r.Name, r.Target)
+	case op_deassign:
+		return graph.Deassign(r.Name, r.Target)
+	case op_associate:
+		return graph.Associate(r.Name, r.Target, toOperationSet(r.Operations))
+	case op_dissociate:
+		return graph.Dissociate(r.Name, r.Target)
+	case op_add_prohibition:
+		if r.Prohibition == nil {
+			return fmt.Errorf("prohibition is missing")
+		}
+		ps.Prohibitions().Add(fromJSONProhibition(r.Prohibition))
+		return nil
+	case op_update_prohibition:
+		if r.Prohibition == nil {
+			return fmt.Errorf("prohibition is missing")
+		}
+		ps.Prohibitions().Update(r.Name, fromJSONProhibition(r.Prohibition))
+		return nil
+	case op_remove_prohibition:
+		ps.Prohibitions().Remove(r.Name)
+		return nil
+	case op_add_obligation:
+		obligation, err := fromJSONObligation(r.Obligation)
+		if err != nil {
+			return err
+		}
+		ps.Obligations().Add(obligation, r.Enabled)
+		return nil
+	case op_update_obligation:
+		obligation, err := fromJSONObligation(r.Obligation)
+		if err != nil {
+			return err
+		}
+		ps.Obligations().Update(r.Name, obligation)
+		return nil
+	case op_remove_obligation:
+		ps.Obligations().Remove(r.Name)
+		return nil
+	case op_set_enable:
+		ps.Obligations().SetEnable(r.Name, r.Enabled)
+		return nil
+	case op_add_timer, op_remove_timer:
+		timers, ok := ps.Obligations().(obligations.TimerStore)
+		if !ok {
+			return fmt.Errorf("the obligations store does not keep timers")
+		}
+		if r.Op == op_remove_timer {
+			timers.RemoveTimer(r.Name)
+			return nil
+		}
+		if r.Timer == nil {
+			return fmt.Errorf("timer is missing")
+		}
+		timers.AddTimer(r.Timer)
+		return nil
+	}
+
+	return fmt.Errorf("unknown operation %s", r.Op)
+}
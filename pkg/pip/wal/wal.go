@@ -0,0 +1,313 @@
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jtejido/ngac/pkg/common"
+	"github.com/jtejido/ngac/pkg/pip"
+	gm "github.com/jtejido/ngac/pkg/pip/graph/memory"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	obm "github.com/jtejido/ngac/pkg/pip/obligations/memory"
+	pm "github.com/jtejido/ngac/pkg/pip/prohibitions/memory"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var _ common.PolicyStore = &Store{}
+
+const (
+	journal_file  = "journal.log"
+	snapshot_file = "snapshot.json"
+
+	default_snapshot_every = 1000
+)
+
+type Options struct {
+	// Number of journal records after which a snapshot is taken and the journal truncated. Defaults to 1000, a
+	// negative value disables automatic snapshots.
+	SnapshotEvery int
+	// Skip the fsync after every journal record. Faster, but the last records may be lost if the machine crashes.
+	NoSync bool
+	// Property keys the graph is indexed by, see memory.NewWithIndexes.
+	Indexes []string
+}
+
+// Store keeps the graph, prohibitions and obligations in memory and appends every change to a journal on disk.
+// The journal is replayed on top of the last snapshot when the store is opened.
+type Store struct {
+	*pip.PIP
+	inner *pip.PIP // the memory stores, used for snapshots and replay so nothing is journaled twice
+
+	sync.Mutex
+	dir     string
+	opts    Options
+	journal *os.File
+	seq     uint64 // sequence number of the last journaled record
+	pending int    // records journaled since the last snapshot
+}
+
+// The snapshot file, seq is the last record included in the policy so records replayed from the journal can be
+// skipped if the process stopped between writing the snapshot and truncating the journal.
+type snapshot struct {
+	Seq    uint64               `json:"seq"`
+	Policy json.RawMessage      `json:"policy"`
+	Timers []*obligations.Timer `json:"timers,omitempty"`
+}
+
+// Open the store kept in the given directory, creating it if it doesn't exist.
+func Open(dir string, opts *Options) (*Store, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		inner: pip.NewPIP(gm.NewWithIndexes(opts.Indexes...), pm.New(), obm.New()),
+		dir:   dir,
+		opts:  *opts,
+	}
+
+	if s.opts.SnapshotEvery == 0 {
+		s.opts.SnapshotEvery = default_snapshot_every
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	journal, err := os.OpenFile(filepath.Join(dir, journal_file), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	s.journal = journal
+
+	s.PIP = pip.NewPIP(
+		&graph{s.inner.Graph(), s},
+		&prohibitionsStore{s.inner.Prohibitions(), s},
+		&obligationsStore{s.inner.Obligations(), s},
+	)
+
+	return s, nil
+}
+
+func (s *Store) load() error {
+	b, err := os.ReadFile(filepath.Join(s.dir, snapshot_file))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		snap := new(snapshot)
+		if err := json.Unmarshal(b, snap); err != nil {
+			return fmt.Errorf("invalid snapshot: %s", err)
+		}
+
+		if err := pip.FromJSON(s.inner, snap.Policy); err != nil {
+			return fmt.Errorf("failed to load snapshot: %s", err)
+		}
+
+		timers := s.inner.Obligations().(obligations.TimerStore)
+		for _, timer := range snap.Timers {
+			timers.AddTimer(timer)
+		}
+
+		s.seq = snap.Seq
+	}
+
+	return s.replay()
+}
+
+func (s *Store) replay() error {
+	f, err := os.Open(filepath.Join(s.dir, journal_file))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// a record without its line break was being written when the process stopped, it was never
+			// acknowledged so it is dropped
+			return s.truncateTorn(len(line))
+		} else if err != nil {
+			return err
+		}
+
+		r := new(record)
+		if err := json.Unmarshal(bytes.TrimSpace(line), r); err != nil {
+			return fmt.Errorf("invalid journal record %q: %s", line, err)
+		}
+
+		if r.Seq <= s.seq {
+			continue
+		}
+
+		if err := r.apply(s.inner); err != nil {
+			return fmt.Errorf("failed to replay journal record %d (%s): %s", r.Seq, r.Op, err)
+		}
+
+		s.seq = r.Seq
+		s.pending++
+	}
+}
+
+// truncateTorn removes the given number of trailing bytes from the journal.
+func (s *Store) truncateTorn(n int) error {
+	if n == 0 {
+		return nil
+	}
+
+	path := filepath.Join(s.dir, journal_file)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	return os.Truncate(path, info.Size()-int64(n))
+}
+
+// commit appends the record of a change already applied to the memory stores. It must be called with the store
+// locked. When the record can't be written, undo reverts the change so memory never holds what the journal lost.
+func (s *Store) commit(r *record, undo func()) error {
+	if err := s.write(r); err != nil {
+		undo()
+		return err
+	}
+
+	s.snapshotIfDue()
+	return nil
+}
+
+// logCommit is used by the stores whose methods cannot return an error, a change that can't be journaled is
+// reverted and logged.
+func (s *Store) logCommit(r *record, undo func()) {
+	if err := s.commit(r, undo); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// writeAhead journals the record before applying a change that can't fail, so there is nothing to revert when the
+// record can't be written. It must be called with the store locked.
+func (s *Store) writeAhead(r *record, change func()) {
+	if err := s.write(r); err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	change()
+	s.snapshotIfDue()
+}
+
+// write appends the record to the journal. A partially written record is truncated away, so the journal always ends
+// with the last good record.
+func (s *Store) write(r *record) error {
+	r.Seq = s.seq + 1
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	info, err := s.journal.Stat()
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.journal.Write(append(b, '\n')); err == nil && !s.opts.NoSync {
+		err = s.journal.Sync()
+	}
+	if err != nil {
+		if terr := s.journal.Truncate(info.Size()); terr != nil {
+			log.Println(terr.Error())
+		}
+		return err
+	}
+
+	s.seq = r.Seq
+	s.pending++
+	return nil
+}
+
+// snapshotIfDue takes a snapshot once enough records were journaled. The records are durable at this point, a failed
+// snapshot is retried with the next record.
+func (s *Store) snapshotIfDue() {
+	if s.opts.SnapshotEvery > 0 && s.pending >= s.opts.SnapshotEvery {
+		if err := s.snapshot(); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// Snapshot writes the current state to disk and truncates the journal.
+func (s *Store) Snapshot() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.snapshot()
+}
+
+func (s *Store) snapshot() error {
+	policy, err := pip.ToJSON(s.inner)
+	if err != nil {
+		return err
+	}
+
+	timers := s.inner.Obligations().(obligations.TimerStore).PendingTimers()
+	b, err := json.Marshal(&snapshot{s.seq, policy, timers})
+	if err != nil {
+		return err
+	}
+
+	// write the new snapshot next to the old one and swap them, so there is always a complete snapshot on disk
+	tmp := filepath.Join(s.dir, snapshot_file+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(s.dir, snapshot_file)); err != nil {
+		return err
+	}
+
+	// the records are part of the snapshot now
+	if err := s.journal.Truncate(0); err != nil {
+		return err
+	}
+
+	s.pending = 0
+	return nil
+}
+
+// Close the journal. The store must not be used afterwards.
+func (s *Store) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.journal.Close()
+}
+
+// Seq returns the sequence number of the last journaled change.
+func (s *Store) Seq() uint64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.seq
+}
@@ -0,0 +1,401 @@
+package wal
+
+import (
+	"encoding/json"
+	"github.com/jtejido/ngac/pkg/operations"
+	g "github.com/jtejido/ngac/pkg/pip/graph"
+	"github.com/jtejido/ngac/pkg/pip/obligations"
+	"github.com/jtejido/ngac/pkg/pip/prohibitions"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testObligation = `{
+  "label": "test",
+  "rules": [{
+    "label": "rule1",
+    "event": {
+      "subject": {"user": "u1"},
+      "operations": ["assign to"],
+      "target": {"policyElements": [{"name": "oa1", "type": "OA"}]}
+    },
+    "response": {
+      "actions": [{"function": {"name": "create_node", "args": ["pc1", "PC", "new", "OA"]}}]
+    }
+  }]
+}`
+
+func open(t *testing.T, dir string, opts *Options) *Store {
+	s, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+
+	return s
+}
+
+// populate makes one change of every kind.
+func populate(t *testing.T, s *Store) {
+	graph := s.Graph()
+	if _, err := graph.CreatePolicyClass("pc1", nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := graph.CreateNode("oa1", g.OA, nil, "pc1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := graph.CreateNode("oa2", g.OA, nil, "pc1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := graph.CreateNode("o1", g.O, nil, "oa1", "oa2"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := graph.CreateNode("ua1", g.UA, nil, "pc1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := graph.CreateNode("u1", g.U, nil, "ua1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := graph.CreateNode("tmp", g.OA, nil, "pc1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := graph.UpdateNode("oa1", g.ToProperties(g.PropertyPair{"k", "v"})); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := graph.Deassign("o1", "oa2"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := graph.Assign("oa2", "oa1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := graph.Associate("ua1", "oa1", operations.NewOperationSet("read", "write")); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := graph.Associate("ua1", "oa2", operations.NewOperationSet("read")); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := graph.Dissociate("ua1", "oa2"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	graph.RemoveNode("tmp")
+
+	s.Prohibitions().Add(prohibitions.NewProhibition("deny1", "u1", map[string]bool{"oa1": false}, operations.NewOperationSet("read"), false))
+	s.Prohibitions().Add(prohibitions.NewProhibition("deny2", "u1", nil, operations.NewOperationSet("read"), false))
+	s.Prohibitions().Update("deny1", prohibitions.NewProhibition("deny1", "ua1", map[string]bool{"oa2": true}, operations.NewOperationSet("write"), true))
+	s.Prohibitions().Remove("deny2")
+
+	for _, label := range []string{"test", "test2"} {
+		obligation := obligations.NewObligation("u1")
+		if err := json.Unmarshal([]byte(testObligation), obligation); err != nil {
+			t.Fatalf("%s", err)
+		}
+		obligation.Label = label
+		s.Obligations().Add(obligation, false)
+	}
+	s.Obligations().SetEnable("test", true)
+	s.Obligations().Remove("test2")
+}
+
+// check verifies the state left by populate.
+func check(t *testing.T, ps *Store) {
+	graph := ps.Graph()
+	if graph.Nodes().Len() != 6 {
+		t.Errorf("expected 6 nodes, got %d", graph.Nodes().Len())
+	}
+	if graph.Exists("tmp") {
+		t.Errorf("tmp should have been removed")
+	}
+	if !graph.PolicyClasses().Contains("pc1") {
+		t.Errorf("pc1 should be a policy class")
+	}
+	if n, _ := graph.Node("oa1"); n == nil || n.Properties["k"] != "v" {
+		t.Errorf("oa1 properties were not updated")
+	}
+	if !graph.IsAssigned("o1", "oa1") || graph.IsAssigned("o1", "oa2") || !graph.IsAssigned("oa2", "oa1") {
+		t.Errorf("unexpected assignments")
+	}
+
+	assocs, err := graph.SourceAssociations("ua1")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(assocs) != 1 || !assocs["oa1"].Contains("read", "write") {
+		t.Errorf("unexpected associations %v", assocs)
+	}
+
+	if len(ps.Prohibitions().All()) != 1 {
+		t.Errorf("expected 1 prohibition")
+	}
+	if pro := ps.Prohibitions().Get("deny1"); pro == nil || pro.Subject != "ua1" || !pro.Intersection || !pro.Containers()["oa2"] {
+		t.Errorf("deny1 was not updated")
+	}
+
+	if len(ps.Obligations().All()) != 1 {
+		t.Errorf("expected 1 obligation")
+	}
+	if ob := ps.Obligations().Get("test"); ob == nil || !ob.Enabled || len(ob.Rules) != 1 {
+		t.Errorf("obligation test was not restored")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, &Options{SnapshotEvery: -1})
+	populate(t, s)
+	check(t, s)
+	s.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, snapshot_file)); !os.IsNotExist(err) {
+		t.Fatalf("no snapshot should be written when snapshots are disabled")
+	}
+
+	s = open(t, dir, nil)
+	defer s.Close()
+	check(t, s)
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, &Options{SnapshotEvery: 5})
+	populate(t, s)
+	seq := s.Seq()
+	s.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, snapshot_file)); err != nil {
+		t.Fatalf("a snapshot should have been written: %s", err)
+	}
+
+	s = open(t, dir, nil)
+	check(t, s)
+	if s.Seq() != seq {
+		t.Errorf("expected sequence %d after reopening, got %d", seq, s.Seq())
+	}
+
+	if err := s.Snapshot(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, journal_file)); info.Size() != 0 {
+		t.Errorf("the journal should be empty after a snapshot")
+	}
+	s.Close()
+
+	s = open(t, dir, nil)
+	defer s.Close()
+	check(t, s)
+}
+
+func TestReplaySkipsSnapshotRecords(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, &Options{SnapshotEvery: -1})
+	populate(t, s)
+
+	// simulate a stop between writing the snapshot and truncating the journal
+	journal, err := os.ReadFile(filepath.Join(dir, journal_file))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := s.Snapshot(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	s.Close()
+	if err := os.WriteFile(filepath.Join(dir, journal_file), journal, 0600); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	s = open(t, dir, nil)
+	defer s.Close()
+	check(t, s)
+}
+
+func TestTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, &Options{SnapshotEvery: -1})
+	populate(t, s)
+	s.Close()
+
+	f, err := os.OpenFile(filepath.Join(dir, journal_file), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	f.Write([]byte(`{"seq":1000,"op":"create_node","na`))
+	f.Close()
+
+	s = open(t, dir, nil)
+	check(t, s)
+
+	// the torn record is dropped, so new records are journaled on a clean line
+	if _, err := s.Graph().CreateNode("oa3", g.OA, nil, "pc1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	s.Close()
+
+	s = open(t, dir, nil)
+	defer s.Close()
+	if !s.Graph().Exists("oa3") {
+		t.Errorf("oa3 should exist after reopening")
+	}
+}
+
+func TestFailedChangesAreNotJournaled(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, nil)
+	if _, err := s.Graph().CreatePolicyClass("pc1", nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := s.Graph().CreateNode("oa1", g.OA, nil, "missing"); err == nil {
+		t.Fatalf("expected an error creating a node with a missing parent")
+	}
+	s.Close()
+
+	s = open(t, dir, nil)
+	defer s.Close()
+	if s.Seq() != 1 {
+		t.Errorf("expected a single journaled change, got %d", s.Seq())
+	}
+}
+
+func TestFailedJournalWritesAreRolledBack(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, &Options{SnapshotEvery: -1})
+	populate(t, s)
+
+	// every write fails on a read-only journal
+	journal := s.journal
+	readOnly, err := os.Open(filepath.Join(dir, journal_file))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	s.journal = readOnly
+	seq := s.Seq()
+
+	graph := s.Graph()
+	if _, err := graph.CreatePolicyClass("pc2", nil); err == nil {
+		t.Errorf("expected an error creating a policy class")
+	}
+	if _, err := graph.CreateNode("oa3", g.OA, nil, "pc1"); err == nil {
+		t.Errorf("expected an error creating a node")
+	}
+	if err := graph.UpdateNode("oa1", g.ToProperties(g.PropertyPair{"k", "changed"})); err == nil {
+		t.Errorf("expected an error updating a node")
+	}
+	if err := graph.Assign("o1", "oa2"); err == nil {
+		t.Errorf("expected an error assigning")
+	}
+	if err := graph.Deassign("oa2", "oa1"); err == nil {
+		t.Errorf("expected an error deassigning")
+	}
+	if err := graph.Associate("ua1", "oa1", operations.NewOperationSet("read")); err == nil {
+		t.Errorf("expected an error associating")
+	}
+	if err := graph.Dissociate("ua1", "oa1"); err == nil {
+		t.Errorf("expected an error dissociating")
+	}
+	graph.RemoveNode("o1")
+
+	s.Prohibitions().Add(prohibitions.NewProhibition("deny3", "u1", nil, operations.NewOperationSet("read"), false))
+	s.Prohibitions().Update("deny1", prohibitions.NewProhibition("deny1", "u1", nil, operations.NewOperationSet("read"), false))
+	s.Prohibitions().Remove("deny1")
+
+	obligation := s.Obligations().Get("test").Clone()
+	obligation.Label = "renamed"
+	s.Obligations().Update("test", obligation)
+	s.Obligations().SetEnable("test", false)
+	s.Obligations().Remove("test")
+	if err := s.Obligations().RemoveRule("test", "rule1"); err == nil {
+		t.Errorf("expected an error removing a rule")
+	}
+	timers := s.Obligations().(obligations.TimerStore)
+	timers.AddTimer(&obligations.Timer{ID: "t1", Obligation: "test", Rule: "rule1", Due: time.Now()})
+	if len(timers.PendingTimers()) != 0 {
+		t.Errorf("the timer should have been rolled back")
+	}
+
+	if s.Seq() != seq {
+		t.Errorf("expected nothing to be journaled, the sequence moved from %d to %d", seq, s.Seq())
+	}
+	check(t, s)
+
+	readOnly.Close()
+	s.journal = journal
+	s.Close()
+
+	s = open(t, dir, nil)
+	defer s.Close()
+	check(t, s)
+}
+
+func TestPartiallyCreatedNodeIsRemoved(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, nil)
+	defer s.Close()
+	if _, err := s.Graph().CreatePolicyClass("pc1", nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := s.Graph().CreateNode("oa1", g.OA, nil, "pc1"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// the assignment to the first parent succeeds before the missing one fails
+	if _, err := s.Graph().CreateNode("o1", g.O, nil, "oa1", "missing"); err == nil {
+		t.Fatalf("expected an error creating a node with a missing parent")
+	}
+	if s.Graph().Exists("o1") {
+		t.Errorf("o1 should have been removed")
+	}
+	if s.Graph().Children("oa1").Len() != 0 {
+		t.Errorf("oa1 should have no children, got %v", s.Graph().Children("oa1"))
+	}
+}
+
+func TestRunTx(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, nil)
+	err := s.RunTx(func(graph g.Graph, p prohibitions.Prohibitions, o obligations.Obligations) error {
+		if _, err := graph.CreatePolicyClass("pc1", nil); err != nil {
+			return err
+		}
+		_, err := graph.CreateNode("oa1", g.OA, nil, "pc1")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	s.Close()
+
+	s = open(t, dir, nil)
+	defer s.Close()
+	if !s.Graph().Exists("oa1") {
+		t.Errorf("oa1 should exist after reopening")
+	}
+
+}
+
+func TestTimers(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, &Options{SnapshotEvery: -1})
+	timers := s.Obligations().(obligations.TimerStore)
+	due := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	timers.AddTimer(&obligations.Timer{ID: "t1", Obligation: "test", Rule: "rule1", Due: due, Target: "o1", User: "u1"})
+	timers.AddTimer(&obligations.Timer{ID: "t2", Obligation: "test", Rule: "rule1", Due: due.Add(time.Hour)})
+	timers.RemoveTimer("t2")
+	s.Close()
+
+	check := func(s *Store) {
+		pending := s.Obligations().(obligations.TimerStore).PendingTimers()
+		if len(pending) != 1 || pending[0].ID != "t1" || !pending[0].Due.Equal(due) || pending[0].Target != "o1" {
+			t.Errorf("expected timer t1 to be restored, got %v", pending)
+		}
+	}
+
+	s = open(t, dir, nil)
+	check(s)
+	if err := s.Snapshot(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	s.Close()
+
+	s = open(t, dir, nil)
+	defer s.Close()
+	check(s)
+}
@@ -229,6 +229,7 @@ func (policy *RBAC) checkAndCreateRBACNode(g graph.Graph, name string, t graph.N
 		typeValue, ok := RBAC.Properties["ngac_type"]
 		if !ok {
 			RBAC.Properties["ngac_type"] = "RBAC"
+			err = g.UpdateNode(name, RBAC.Properties)
 		} else if typeValue != "RBAC" {
 			err = fmt.Errorf("Node cannot have property key of ngac_type")
 			return
@@ -0,0 +1,158 @@
+package ngac
+
+import (
+    "errors"
+    "fmt"
+    "github.com/jtejido/ngac/pkg/context"
+    "github.com/jtejido/ngac/pkg/epp"
+    "github.com/jtejido/ngac/pkg/pip/graph"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
+    "github.com/jtejido/ngac/pkg/pip/prohibitions"
+    "testing"
+)
+
+// updateNextExecutor updates the node named by the "next" property of the target, or the target itself when it has
+// none.
+type updateNextExecutor struct{}
+
+func (f *updateNextExecutor) Name() string {
+    return "update_next"
+}
+
+func (f *updateNextExecutor) NumParams() int {
+    return 0
+}
+
+func (f *updateNextExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+    eventCtx epp.EventContext, function *obligations.Function, functionEvaluator *epp.FunctionEvaluator) (interface{}, error) {
+    name := eventCtx.Target().Name
+    if next, ok := eventCtx.Target().Properties["next"]; ok {
+        if next == "" {
+            return nil, nil
+        }
+        name = next
+    }
+
+    node, err := g.Node(name)
+    if err != nil {
+        return nil, err
+    }
+    props := make(graph.PropertyMap)
+    for k, v := range node.Properties {
+        props[k] = v
+    }
+    props["updated"] = fmt.Sprintf("%d", len(eventCtx.Lineage())+1)
+
+    return nil, g.UpdateNode(name, props)
+}
+
+const cascadeObligation = `{
+  "label": "cascade",
+  "rules": [{
+    "label": "update next",
+    "event": {
+      "operations": ["update node"],
+      "target": {"containers": [{"name": "oa1", "type": "OA"}]}
+    },
+    "response": {
+      "actions": [{"function": {"name": "update_next"}}]
+    }
+  }]
+}`
+
+// chain creates the objects c0 <- c1 <- ... <- c(n-1) in oa1, each one naming the next.
+func chain(t *testing.T, tc testContext, n int) graph.Graph {
+    ctx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(ctx)
+    g := wu.Graph()
+    for i := 0; i < n; i++ {
+        next := ""
+        if i < n-1 {
+            next = fmt.Sprintf("c%d", i+1)
+        }
+        if _, err := g.CreateNode(fmt.Sprintf("c%d", i), graph.O, graph.ToProperties(graph.PropertyPair{"next", next}), tc.oa1.Name); err != nil {
+            t.Fatalf("%s", err)
+        }
+    }
+
+    obligation, err := obligations.ParseBytes("super", []byte(cascadeObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+
+    return g
+}
+
+func TestCascade(t *testing.T) {
+    tc := testCtx(t, new(updateNextExecutor))
+    g := chain(t, tc, 5)
+
+    if err := g.UpdateNode("c0", graph.ToProperties(graph.PropertyPair{"next", "c1"})); err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    // each update is caused by the update of the previous node
+    for i := 1; i < 5; i++ {
+        node, err := g.Node(fmt.Sprintf("c%d", i))
+        if err != nil {
+            t.Fatalf("%s", err)
+        }
+        if node.Properties["updated"] != fmt.Sprintf("%d", i) {
+            t.Errorf("expected c%d to be updated at depth %d, got %q", i, i, node.Properties["updated"])
+        }
+    }
+}
+
+func TestCascadeMaxDepth(t *testing.T) {
+    tc := testCtxWithOptions(t, epp.NewEPPOptions(new(updateNextExecutor)).WithMaxCascadeDepth(3))
+    g := chain(t, tc, 6)
+
+    err := g.UpdateNode("c0", graph.ToProperties(graph.PropertyPair{"next", "c1"}))
+    var cascadeErr *epp.CascadeError
+    if !errors.As(err, &cascadeErr) {
+        t.Fatalf("expected a cascade error, got %v", err)
+    }
+    if len(cascadeErr.Chain) != 4 {
+        t.Fatalf("expected a chain of 4 rules, got %s", cascadeErr)
+    }
+    for i, cause := range cascadeErr.Chain {
+        if cause.Obligation != "cascade" || len(cause.Rules) != 1 || cause.Rules[0] != "update next" ||
+            cause.Event != epp.UPDATE_NODE_EVENT || cause.Target != fmt.Sprintf("c%d", i) {
+            t.Errorf("unexpected cause %s at position %d", cause, i)
+        }
+    }
+
+    // the rule exceeding the depth is not applied
+    node, err := g.Node("c4")
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if _, ok := node.Properties["updated"]; ok {
+        t.Errorf("expected c4 not to be updated")
+    }
+}
+
+func TestCascadeLoop(t *testing.T) {
+    tc := testCtx(t, new(updateNextExecutor))
+    g := chain(t, tc, 1)
+
+    // without a next node the rule updates its own target
+    err := g.UpdateNode("c0", graph.ToProperties(graph.PropertyPair{"loop", "true"}))
+    var cascadeErr *epp.CascadeError
+    if !errors.As(err, &cascadeErr) {
+        t.Fatalf("expected a cascade error, got %v", err)
+    }
+    if len(cascadeErr.Chain) != 2 {
+        t.Fatalf("expected a chain of 2 rules, got %s", cascadeErr)
+    }
+    for _, cause := range cascadeErr.Chain {
+        if cause.Target != "c0" {
+            t.Errorf("unexpected cause %s", cause)
+        }
+    }
+    expected := "rule update next of cascade fired again on c0: cascade[update next] on update node c0 -> cascade[update next] on update node c0"
+    if err.Error() != expected {
+        t.Errorf("expected %q, got %q", expected, err.Error())
+    }
+}
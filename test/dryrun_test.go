@@ -0,0 +1,182 @@
+package ngac
+
+import (
+    "github.com/jtejido/ngac/pkg/context"
+    "github.com/jtejido/ngac/pkg/epp"
+    "github.com/jtejido/ngac/pkg/pip/graph"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
+    "testing"
+)
+
+const dryRunObligation = `{
+  "label": "dry run",
+  "rules": [
+    {
+      "label": "assign to oa1",
+      "event": {
+        "operations": ["assign to"],
+        "target": {"policyElements": [{"name": "oa1", "type": "OA"}]}
+      },
+      "response": {
+        "actions": [
+          {
+            "create": [{"what": {"name": "oa2", "type": "OA"}, "where": {"name": "pc1", "type": "PC"}}]
+          },
+          {
+            "condition": [{
+              "function": {
+                "name": "is_node_contained_in",
+                "args": [{"function": {"name": "child_of_assign"}}, {"function": {"name": "get_node", "args": ["oa2", "OA"]}}]
+              }
+            }],
+            "assign": [{"what": {"name": "o1", "type": "O"}, "where": {"name": "oa1", "type": "OA"}}]
+          },
+          {
+            "assign": [{"what": {"function": {"name": "child_of_assign"}}, "where": {"name": "oa2", "type": "OA"}}]
+          },
+          {
+            "grant": {
+              "subject": {"name": "ua1", "type": "UA"},
+              "operations": ["read"],
+              "target": {"name": "oa2", "type": "OA"}
+            }
+          },
+          {
+            "deny": {
+              "label": "deny u1",
+              "subject": {"name": "u1", "type": "U"},
+              "operations": ["write"],
+              "target": {"containers": [{"name": "oa2", "type": "OA"}]}
+            }
+          }
+        ]
+      }
+    },
+    {
+      "label": "delete node",
+      "event": {"operations": ["delete node"]},
+      "response": {"actions": [{"function": {"name": "create_node", "args": ["oa9", "OA", "oa3", "OA"]}}]}
+    }
+  ]
+}`
+
+func TestDryRun(t *testing.T) {
+    tc := testCtx(t)
+    ctx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(ctx)
+
+    obligation, err := obligations.ParseBytes("super", []byte(dryRunObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    o2, err := wu.Graph().CreateNode("o2", graph.O, nil, tc.oa1.Name)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    report, err := tc.pdp.EPP().DryRun(obligation, epp.NewAssignToEvent(ctx, tc.oa1, o2))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    if len(report.Rules) != 2 || len(report.Matched()) != 1 || report.Matched()[0].Label != "assign to oa1" {
+        t.Fatalf("expected only the first rule to match")
+    }
+    rule := report.Rules[0]
+    if !rule.Condition || !rule.NegatedCondition {
+        t.Errorf("expected the response conditions to pass")
+    }
+    applied := []bool{true, false, true, true, true}
+    if len(rule.Actions) != len(applied) {
+        t.Fatalf("expected %d actions, got %d", len(applied), len(rule.Actions))
+    }
+    for i, action := range rule.Actions {
+        if action.Applied != applied[i] {
+            t.Errorf("expected the %s action at %d to be applied: %t", action.Action, i, applied[i])
+        }
+    }
+    if rule.Actions[1].Condition {
+        t.Errorf("expected the condition of the second action to fail")
+    }
+
+    expected := []string{
+        "graph.create_node(oa2, OA, {}, pc1)",
+        "graph.assign(o2, oa2)",
+        "graph.associate(ua1, oa2, {read})",
+        "prohibitions.add(deny u1)",
+    }
+    if len(report.Changes) != len(expected) {
+        t.Fatalf("expected %v, got %v", expected, report.Changes)
+    }
+    for i, change := range report.Changes {
+        if change.String() != expected[i] || change.Rule != "assign to oa1" {
+            t.Errorf("expected %s, got %s from %s", expected[i], change, change.Rule)
+        }
+    }
+
+    // nothing is committed
+    if wu.Graph().Exists("oa2") {
+        t.Errorf("expected oa2 not to be created")
+    }
+    if wu.Prohibitions().Get("deny u1") != nil {
+        t.Errorf("expected deny u1 not to be added")
+    }
+    assocs, err := wu.Graph().SourceAssociations(tc.ua1.Name)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if len(assocs) != 1 {
+        t.Errorf("expected ua1 to keep a single association, got %v", assocs)
+    }
+}
+
+func TestDryRunRule(t *testing.T) {
+    tc := testCtx(t)
+    ctx, _ := context.NewUserContext("super")
+
+    obligation, err := obligations.ParseBytes("super", []byte(dryRunObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    // the parent of the node created by the response does not exist
+    report, err := epp.DryRunRule(tc.pdp.WithUser(ctx), epp.NewFunctionEvaluator(), obligation.Label, obligation.Rules[1],
+        epp.NewDeleteNodeEvent(ctx, tc.o1, nil))
+    if len(report.Matched()) != 1 {
+        t.Fatalf("expected the rule to match")
+    }
+    if err == nil {
+        t.Fatalf("expected the response to fail")
+    }
+    if len(report.Changes) != 0 || report.Rules[0].Actions[0].Applied {
+        t.Errorf("expected no change, got %v", report.Changes)
+    }
+}
+
+func TestDryRunRecordsOnlyChanges(t *testing.T) {
+    tc := testCtx(t)
+    ctx, _ := context.NewUserContext("super")
+
+    obligation, err := obligations.ParseBytes("super", []byte(`{
+  "label": "cleanup",
+  "rules": [{
+    "label": "remove missing",
+    "event": {"operations": ["assign to"]},
+    "response": {"actions": [{"delete": {"prohibitions": ["missing"]}}]}
+  }]
+}`))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    report, err := tc.pdp.EPP().DryRun(obligation, epp.NewAssignToEvent(ctx, tc.oa1, tc.o1))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if len(report.Matched()) != 1 || !report.Rules[0].Actions[0].Applied {
+        t.Fatalf("expected the delete action to be applied")
+    }
+    if len(report.Changes) != 0 {
+        t.Errorf("expected removing a missing prohibition to change nothing, got %v", report.Changes)
+    }
+}
@@ -0,0 +1,290 @@
+package ngac
+
+import (
+    "fmt"
+    "github.com/jtejido/ngac/pkg/context"
+    "github.com/jtejido/ngac/pkg/epp"
+    "github.com/jtejido/ngac/pkg/pip/graph"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
+    "github.com/jtejido/ngac/pkg/pip/prohibitions"
+    "testing"
+    "time"
+)
+
+// flakyExecutor fails the given number of times before it succeeds.
+type flakyExecutor struct {
+    failures int
+    calls    int
+}
+
+func (f *flakyExecutor) Name() string {
+    return "flaky"
+}
+
+func (f *flakyExecutor) NumParams() int {
+    return 0
+}
+
+func (f *flakyExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+    eventCtx epp.EventContext, function *obligations.Function, functionEvaluator *epp.FunctionEvaluator) (interface{}, error) {
+    f.calls++
+    if f.calls <= f.failures {
+        return nil, fmt.Errorf("flaky failed %d times", f.calls)
+    }
+    return nil, nil
+}
+
+// the second action fails as long as the node "missing" doesn't exist
+const failingObligation = `{
+  "label": "failing",
+  %s
+  "rules": [{
+    "label": "update o1",
+    "event": {
+      "operations": ["update node"],
+      "target": {"policyElements": [{"name": "o1", "type": "O"}]}
+    },
+    "response": {
+      "actions": [
+        {"create": [{"what": {"name": "before", "type": "OA"}, "where": {"name": "oa1", "type": "OA"}}]},
+        {"assign": [{"what": {"name": "missing", "type": "O"}, "where": {"name": "oa1", "type": "OA"}}]},
+        {"create": [{"what": {"name": "after", "type": "OA"}, "where": {"name": "oa1", "type": "OA"}}]}
+      ]
+    }
+  }]
+}`
+
+const flakyObligation = `{
+  "label": "flaky",
+  "rules": [{
+    "label": "update o1",
+    "event": {
+      "operations": ["update node"],
+      "target": {"policyElements": [{"name": "o1", "type": "O"}]}
+    },
+    "response": {
+      "actions": [
+        {"create": [{"what": {"name": "made", "type": "OA"}, "where": {"name": "oa1", "type": "OA"}}]},
+        {"function": {"name": "flaky"}}
+      ]
+    },
+    "onError": {"policy": "retry", "retries": 2, "delay": "1ms"}
+  }]
+}`
+
+func failingCtx(t *testing.T, onError string, executors ...epp.FunctionExecutor) (testContext, *obligations.Obligation) {
+    tc := testCtx(t, executors...)
+    superCtx, _ := context.NewUserContext("super")
+    obligation, err := obligations.ParseBytes("super", []byte(fmt.Sprintf(failingObligation, onError)))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    tc.pdp.WithUser(superCtx).Obligations().Add(obligation, true)
+
+    return tc, obligation
+}
+
+func TestAbortPolicy(t *testing.T) {
+    tc, _ := failingCtx(t, "")
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    if err := wu.Graph().UpdateNode(tc.o1.Name, graph.ToProperties(graph.PropertyPair{"k", "v"})); err == nil {
+        t.Fatalf("expected the failing obligation to fail the update")
+    }
+    if wu.Graph().Exists("before") || wu.Graph().Exists("after") {
+        t.Errorf("expected the response to be rolled back")
+    }
+    if letters := tc.pdp.EPP().DeadLetters().All(); len(letters) != 0 {
+        t.Errorf("expected no dead letter, got %v", letters)
+    }
+}
+
+func TestSkipPolicy(t *testing.T) {
+    recorder := new(recordEventExecutor)
+    tc, _ := failingCtx(t, `"onError": {"policy": "skip"},`, recorder)
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    // the other obligations still see the event
+    record, err := obligations.ParseBytes("super", []byte(recordObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(record, true)
+
+    if err := wu.Graph().UpdateNode(tc.o1.Name, graph.ToProperties(graph.PropertyPair{"k", "v"})); err != nil {
+        t.Fatalf("expected the update to succeed, got %s", err)
+    }
+    if !wu.Graph().Exists("before") || !wu.Graph().Exists("after") {
+        t.Errorf("expected the actions around the failing one to be applied")
+    }
+    if len(recorder.events) != 1 {
+        t.Errorf("expected the other obligation to respond, got %d events", len(recorder.events))
+    }
+
+    letters := tc.pdp.EPP().DeadLetters().All()
+    if len(letters) != 1 {
+        t.Fatalf("expected a dead letter, got %v", letters)
+    }
+    letter := letters[0]
+    if letter.Obligation != "failing" || letter.Rule != "update o1" || letter.Action != 1 || letter.Attempts != 1 || letter.Event.Event() != epp.UPDATE_NODE_EVENT {
+        t.Errorf("unexpected dead letter %s", letter)
+    }
+
+    // replaying fails until the cause is fixed, then only the skipped action is applied
+    if err := tc.pdp.EPP().Replay(letter.ID); err == nil {
+        t.Fatalf("expected the replay to fail")
+    }
+    if letter = tc.pdp.EPP().DeadLetters().Get(letter.ID); letter == nil || letter.Attempts != 2 {
+        t.Fatalf("expected the dead letter to record the failed replay, got %v", letter)
+    }
+    if _, err := wu.Graph().CreateNode("oa2", graph.OA, nil, tc.pc1.Name); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if _, err := wu.Graph().CreateNode("missing", graph.O, nil, "oa2"); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if err := tc.pdp.EPP().Replay(letter.ID); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !wu.Graph().Parents("missing").Contains(tc.oa1.Name) {
+        t.Errorf("expected the skipped assignment to be replayed")
+    }
+    if len(tc.pdp.EPP().DeadLetters().All()) != 0 {
+        t.Errorf("expected the replayed dead letter to be removed")
+    }
+    if err := tc.pdp.EPP().Replay(letter.ID); err == nil {
+        t.Errorf("expected a removed dead letter not to be replayed")
+    }
+}
+
+func TestRetryPolicy(t *testing.T) {
+    flaky := &flakyExecutor{failures: 2}
+    tc := testCtx(t, flaky)
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    obligation, err := obligations.ParseBytes("super", []byte(flakyObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if err := wu.ValidateObligation(obligation); err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+
+    if err := wu.Graph().UpdateNode(tc.o1.Name, graph.ToProperties(graph.PropertyPair{"k", "v1"})); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if flaky.calls != 3 || !wu.Graph().Exists("made") {
+        t.Errorf("expected the response to succeed on the third attempt, got %d calls", flaky.calls)
+    }
+    if letters := tc.pdp.EPP().DeadLetters().All(); len(letters) != 0 {
+        t.Errorf("expected no dead letter, got %v", letters)
+    }
+
+    // the failed attempts are rolled back, the response is kept as a dead letter once the retries are exhausted
+    flaky.calls, flaky.failures = 0, 5
+    wu.Graph().RemoveNode("made")
+    if err := wu.Graph().UpdateNode(tc.o1.Name, graph.ToProperties(graph.PropertyPair{"k", "v2"})); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if flaky.calls != 3 || wu.Graph().Exists("made") {
+        t.Errorf("expected 3 rolled back attempts, got %d calls", flaky.calls)
+    }
+    letters := tc.pdp.EPP().DeadLetters().All()
+    if len(letters) != 1 || letters[0].Attempts != 3 || letters[0].Action != -1 || letters[0].Err.Error() != "flaky failed 3 times" {
+        t.Fatalf("expected a dead letter after 3 attempts, got %v", letters)
+    }
+
+    flaky.failures = 0
+    if err := tc.pdp.EPP().Replay(letters[0].ID); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !wu.Graph().Exists("made") {
+        t.Errorf("expected the replayed response to be applied")
+    }
+}
+
+const clockObligation = `{
+  "label": "clock",
+  "rules": [{
+    "label": "update o1",
+    "event": {
+      "operations": ["update node"],
+      "target": {"policyElements": [{"name": "o1", "type": "O"}]}
+    },
+    "response": {
+      "actions": [
+        {
+          "condition": [{"function": {"name": "equals", "args": [{"function": {"name": "current_time", "args": ["15:04"]}}, "10:00"]}}],
+          "create": [{"what": {"name": "at ten", "type": "OA"}, "where": {"name": "oa1", "type": "OA"}}]
+        },
+        {"function": {"name": "flaky"}}
+      ]
+    },
+    "onError": {"policy": "retry", "retries": 2, "delay": "1m"}
+  }]
+}`
+
+func TestErrorPolicyClock(t *testing.T) {
+    start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+    clock := epp.NewManualClock(start)
+    flaky := &flakyExecutor{failures: 5}
+    tc := testCtxWithOptions(t, epp.NewEPPOptions(flaky).WithClock(clock))
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    obligation, err := obligations.ParseBytes("super", []byte(clockObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+
+    // the retries wait 1m then 2m on the clock, without sleeping
+    began := time.Now()
+    if err := wu.Graph().UpdateNode(tc.o1.Name, graph.ToProperties(graph.PropertyPair{"k", "v"})); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if time.Since(began) > time.Minute {
+        t.Errorf("expected the retries not to sleep")
+    }
+    if !clock.Now().Equal(start.Add(3 * time.Minute)) {
+        t.Errorf("expected the retries to advance the clock by 3m, it is %s", clock.Now())
+    }
+
+    letters := tc.pdp.EPP().DeadLetters().All()
+    if len(letters) != 1 || !letters[0].Time.Equal(clock.Now()) {
+        t.Fatalf("expected a dead letter timed by the clock, got %v", letters)
+    }
+
+    // current_time reads the clock as well
+    flaky.failures = 0
+    clock.Set(start.Add(time.Hour))
+    if err := tc.pdp.EPP().Replay(letters[0].ID); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !wu.Graph().Exists("at ten") {
+        t.Errorf("expected current_time to read the clock")
+    }
+}
+
+func TestValidateErrorPolicy(t *testing.T) {
+    tc := testCtx(t)
+    superCtx, _ := context.NewUserContext("super")
+    obligation, err := obligations.ParseBytes("super", []byte(fmt.Sprintf(failingObligation, `"onError": {"policy": "retry", "delay": "a while"},`)))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    err = tc.pdp.WithUser(superCtx).ValidateObligation(obligation)
+    validationErr, ok := err.(*obligations.ValidationError)
+    if !ok || validationErr.Violations[len(validationErr.Violations)-1].Pointer != "/onError/delay" {
+        t.Errorf("expected the delay to be rejected, got %v", err)
+    }
+
+    if _, err := obligations.ParseBytes("super", []byte(fmt.Sprintf(failingObligation, `"onError": {"policy": "ignore"},`))); err == nil {
+        t.Errorf("expected an unknown policy to be rejected by the schema")
+    }
+}
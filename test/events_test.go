@@ -0,0 +1,363 @@
+package ngac
+
+import (
+    "github.com/jtejido/ngac/internal/set"
+    "github.com/jtejido/ngac/pkg/context"
+    "github.com/jtejido/ngac/pkg/epp"
+    "github.com/jtejido/ngac/pkg/pip/graph"
+    gm "github.com/jtejido/ngac/pkg/pip/graph/memory"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
+    "github.com/jtejido/ngac/pkg/pip/prohibitions"
+    "sync"
+    "testing"
+)
+
+// multiPCGraph builds
+//   pc1 <- oa1
+//   pc2 <- oa2 <- oa12 -> pc1
+//   oa12 <- o1
+//   pc3
+func multiPCGraph(t *testing.T) graph.Graph {
+    g := gm.New()
+    for _, pc := range []string{"pc1", "pc2", "pc3"} {
+        if _, err := g.CreatePolicyClass(pc, nil); err != nil {
+            t.Fatalf("%s", err)
+        }
+    }
+    if _, err := g.CreateNode("oa1", graph.OA, nil, "pc1"); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if _, err := g.CreateNode("oa2", graph.OA, nil, "pc2"); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if _, err := g.CreateNode("oa12", graph.OA, nil, "oa2", "pc1"); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if _, err := g.CreateNode("o1", graph.O, nil, "oa12"); err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    return g
+}
+
+func TestPolicyClassMatches(t *testing.T) {
+    g := multiPCGraph(t)
+    userCtx, _ := context.NewUserContext("super")
+
+    tests := []struct {
+        target   string
+        pc       *obligations.PolicyClass
+        expected bool
+    }{
+        {"o1", nil, true},
+        {"o1", obligations.NewPolicyClass(), true},
+        {"o1", &obligations.PolicyClass{AnyOf: []string{"pc2"}}, true},
+        {"o1", &obligations.PolicyClass{AnyOf: []string{"pc3"}}, false},
+        {"o1", &obligations.PolicyClass{AnyOf: []string{"pc3", "pc1"}}, true},
+        {"o1", &obligations.PolicyClass{EachOf: []string{"pc1", "pc2"}}, true},
+        {"o1", &obligations.PolicyClass{EachOf: []string{"pc1", "pc2", "pc3"}}, false},
+        {"oa1", &obligations.PolicyClass{AnyOf: []string{"pc1", "pc3"}}, true},
+        {"oa1", &obligations.PolicyClass{AnyOf: []string{"pc2"}}, false},
+        {"oa1", &obligations.PolicyClass{EachOf: []string{"pc1", "pc2"}}, false},
+        {"oa2", &obligations.PolicyClass{EachOf: []string{"pc2"}}, true},
+        {"pc1", &obligations.PolicyClass{AnyOf: []string{"pc1"}}, true},
+        {"pc3", &obligations.PolicyClass{AnyOf: []string{"pc1", "pc2"}}, false},
+    }
+
+    for _, test := range tests {
+        target, err := g.Node(test.target)
+        if err != nil {
+            t.Fatalf("%s", err)
+        }
+
+        event := epp.NewObjectAccessEvent(userCtx, "read", target)
+        pattern := &obligations.EventPattern{PolicyClass: test.pc}
+        if matches := event.MatchesPattern(pattern, g); matches != test.expected {
+            t.Errorf("%s with %+v: expected %t, got %t", test.target, test.pc, test.expected, matches)
+        }
+    }
+}
+
+func TestPolicyClassMatchesDeletedNode(t *testing.T) {
+    g := multiPCGraph(t)
+    userCtx, _ := context.NewUserContext("super")
+
+    o1, err := g.Node("o1")
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    parents := g.Parents("o1")
+    g.RemoveNode("o1")
+
+    // the deleted node is matched using the parents it had
+    event := epp.NewDeleteNodeEvent(userCtx, o1, parents)
+    if !event.MatchesPattern(&obligations.EventPattern{PolicyClass: &obligations.PolicyClass{EachOf: []string{"pc1", "pc2"}}}, g) {
+        t.Errorf("expected the deleted node to match pc1 and pc2")
+    }
+    if event.MatchesPattern(&obligations.EventPattern{PolicyClass: &obligations.PolicyClass{AnyOf: []string{"pc3"}}}, g) {
+        t.Errorf("expected the deleted node not to match pc3")
+    }
+
+    target := &obligations.Target{Containers: []*obligations.EvrNode{obligations.NewEvrNode("oa2", "OA", nil)}}
+    if !event.MatchesPattern(&obligations.EventPattern{Target: target}, g) {
+        t.Errorf("expected the deleted node to be contained in oa2")
+    }
+
+    event = epp.NewDeleteNodeEvent(userCtx, o1, set.NewSet())
+    if event.MatchesPattern(&obligations.EventPattern{PolicyClass: &obligations.PolicyClass{AnyOf: []string{"pc1"}}}, g) {
+        t.Errorf("a node without parents is not in any policy class")
+    }
+}
+
+const pcObligation = `{
+  "label": "pc2 only",
+  "rules": [{
+    "label": "assign to in pc2",
+    "event": {
+      "policyClass": {"anyOf": ["pc2"]},
+      "operations": ["assign to"]
+    },
+    "response": {
+      "actions": [{
+        "create": [{
+          "what": {"name": "fired", "type": "OA"},
+          "where": {"name": "pc2", "type": "PC"}
+        }]
+      }]
+    }
+  }]
+}`
+
+func TestObligationPolicyClass(t *testing.T) {
+    tc := testCtx(t)
+    ctx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(ctx)
+    g := wu.Graph()
+
+    if _, err := g.CreatePolicyClass("pc2", nil); err != nil {
+        t.Fatalf("%s", err)
+    }
+    oa2, err := g.CreateNode("oa2", graph.OA, nil, "pc2")
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    o2, err := g.CreateNode("o2", graph.O, nil, tc.oa1.Name)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    obligation, err := obligations.ParseBytes("super", []byte(pcObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+
+    // oa3 is only in pc1
+    oa3, err := g.CreateNode("oa3", graph.OA, nil, tc.pc1.Name)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if err := g.Assign(o2.Name, oa3.Name); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if g.Exists("fired") {
+        t.Fatalf("the rule should not fire for events in pc1")
+    }
+
+    if err := g.Assign(o2.Name, oa2.Name); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !g.Exists("fired") {
+        t.Errorf("the rule should fire for events in pc2")
+    }
+}
+
+// recordEventExecutor records the events it is called for.
+type recordEventExecutor struct {
+    events []epp.EventContext
+}
+
+func (f *recordEventExecutor) Name() string {
+    return "record_event"
+}
+
+func (f *recordEventExecutor) NumParams() int {
+    return 0
+}
+
+func (f *recordEventExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+    eventCtx epp.EventContext, function *obligations.Function, functionEvaluator *epp.FunctionEvaluator) (interface{}, error) {
+    f.events = append(f.events, eventCtx)
+    return nil, nil
+}
+
+const recordObligation = `{
+  "label": "record",
+  "rules": [{
+    "label": "record o1",
+    "event": {
+      "operations": ["update node", "read", "access denied"],
+      "target": {"policyElements": [{"name": "o1", "type": "O"}]}
+    },
+    "response": {
+      "actions": [{"function": {"name": "record_event"}}]
+    }
+  }]
+}`
+
+func TestUpdateNodeAndAccessEvents(t *testing.T) {
+    recorder := new(recordEventExecutor)
+    tc := testCtx(t, recorder)
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    obligation, err := obligations.ParseBytes("super", []byte(recordObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+
+    if err := wu.Graph().UpdateNode(tc.o1.Name, graph.ToProperties(graph.PropertyPair{"k", "v"})); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if len(recorder.events) != 1 {
+        t.Fatalf("expected an update node event, got %d events", len(recorder.events))
+    }
+    update, ok := recorder.events[0].(*epp.UpdateNodeEvent)
+    if !ok {
+        t.Fatalf("expected an update node event, got %T", recorder.events[0])
+    }
+    if len(update.OldProperties) != 0 || update.NewProperties["k"] != "v" || update.Target().Properties["k"] != "v" {
+        t.Errorf("unexpected properties old=%v new=%v", update.OldProperties, update.NewProperties)
+    }
+
+    userCtx, _ := context.NewUserContext(tc.u1.Name)
+    user := tc.pdp.WithUser(userCtx)
+
+    allowed, err := user.Access(tc.o1.Name, "read")
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !allowed {
+        t.Errorf("u1 should be allowed to read o1")
+    }
+    if len(recorder.events) != 2 {
+        t.Fatalf("expected an object access event, got %d events", len(recorder.events))
+    }
+    if access, ok := recorder.events[1].(*epp.ObjectAccessEvent); !ok || access.Event() != "read" || access.Target().Name != tc.o1.Name {
+        t.Errorf("unexpected event %+v", recorder.events[1])
+    }
+
+    allowed, err = user.Access(tc.o1.Name, "read", "execute")
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if allowed {
+        t.Errorf("u1 should not be allowed to execute o1")
+    }
+    if len(recorder.events) != 3 {
+        t.Fatalf("expected an access denied event, got %d events", len(recorder.events))
+    }
+    denied, ok := recorder.events[2].(*epp.AccessDeniedEvent)
+    if !ok {
+        t.Fatalf("expected an access denied event, got %T", recorder.events[2])
+    }
+    if denied.Event() != epp.ACCESS_DENIED_EVENT || len(denied.Operations) != 2 || denied.Operations[1] != "execute" {
+        t.Errorf("unexpected access denied event %+v", denied)
+    }
+
+    // write is allowed, but the rule doesn't match it
+    if allowed, err := user.Access(tc.o1.Name, "write"); err != nil || !allowed {
+        t.Errorf("u1 should be allowed to write o1")
+    }
+    if len(recorder.events) != 3 {
+        t.Errorf("the rule should not match write")
+    }
+
+    if _, err := user.Access("missing", "read"); err == nil {
+        t.Errorf("expected an error accessing a missing node")
+    }
+    if _, err := user.Access(tc.o1.Name); err == nil {
+        t.Errorf("expected an error when no operations are given")
+    }
+}
+
+func TestAsynchronousEPP(t *testing.T) {
+    recorder := new(recordEventExecutor)
+    tc := testCtxWithOptions(t, epp.NewEPPOptions(recorder).WithBus(&epp.BusOptions{Workers: 2}))
+    defer tc.pdp.EPP().Close()
+
+    ctx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(ctx)
+    g := wu.Graph()
+
+    oa2, err := g.CreateNode("oa2", graph.OA, nil, tc.pc1.Name)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    names := []string{"o2", "o3", "o4"}
+    for _, name := range names {
+        if _, err := g.CreateNode(name, graph.O, nil, oa2.Name); err != nil {
+            t.Fatalf("%s", err)
+        }
+    }
+    tc.pdp.EPP().Wait()
+
+    var mu sync.Mutex
+    var events []string
+    unsubscribe := tc.pdp.EPP().Subscribe("test", func(eventCtx epp.EventContext) error {
+        mu.Lock()
+        events = append(events, eventCtx.Event())
+        mu.Unlock()
+        return nil
+    })
+    defer unsubscribe()
+
+    obligation, err := obligations.ParseBytes("super", []byte(`{
+      "label": "async",
+      "rules": [{
+        "label": "assign to oa1",
+        "event": {
+          "operations": ["assign to"],
+          "target": {"policyElements": [{"name": "oa1", "type": "OA"}]}
+        },
+        "response": {
+          "actions": [{"function": {"name": "record_event"}}]
+        }
+      }]
+    }`))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+
+    for _, name := range names {
+        if err := g.Assign(name, tc.oa1.Name); err != nil {
+            t.Fatalf("%s", err)
+        }
+    }
+    tc.pdp.EPP().Wait()
+
+    // the events of the obligation are processed in order
+    if len(recorder.events) != len(names) {
+        t.Fatalf("expected %d events, got %d", len(names), len(recorder.events))
+    }
+    for i, name := range names {
+        event, ok := recorder.events[i].(*epp.AssignToEvent)
+        if !ok || event.ChildNode.Name != name {
+            t.Errorf("expected the assignment of %s at position %d", name, i)
+        }
+    }
+
+    // subscribers receive both events of each assignment
+    mu.Lock()
+    defer mu.Unlock()
+    if len(events) != 2*len(names) {
+        t.Fatalf("expected %d events, got %v", 2*len(names), events)
+    }
+    for i := 0; i < len(events); i += 2 {
+        if events[i] != epp.ASSIGN_EVENT || events[i+1] != epp.ASSIGN_TO_EVENT {
+            t.Errorf("unexpected events %v", events)
+        }
+    }
+}
@@ -16,6 +16,7 @@ import (
     obm "github.com/jtejido/ngac/pkg/pip/obligations/memory"
     pm "github.com/jtejido/ngac/pkg/pip/prohibitions/memory"
     "testing"
+    "time"
 )
 
 type testContext struct {
@@ -23,7 +24,11 @@ type testContext struct {
     u1, ua1, o1, oa1, pc1 *graph.Node
 }
 
-func testCtx(t *testing.T) testContext {
+func testCtx(t *testing.T, executors ...epp.FunctionExecutor) testContext {
+    return testCtxWithOptions(t, epp.NewEPPOptions(executors...))
+}
+
+func testCtxWithOptions(t *testing.T, eppOptions *epp.EPPOptions) testContext {
     ops := operations.NewOperationSet("read", "write", "execute")
     functionalEntity := pip.NewPIP(gm.New(), pm.New(), obm.New())
     p, err := pap.NewPAP(functionalEntity)
@@ -32,7 +37,7 @@ func testCtx(t *testing.T) testContext {
     }
     pdp := NewPDP(
         p,
-        epp.NewEPPOptions(),
+        eppOptions,
         decider.NewPReviewDeciderWithProhibitions(functionalEntity.Graph(), functionalEntity.Prohibitions(), ops),
         audit.NewPReviewAuditor(functionalEntity.Graph(), ops),
     )
@@ -379,3 +384,234 @@ func TestToPropertiesExecutor(t *testing.T) {
         t.Errorf("k2 should be present and should equals v2")
     }
 }
+
+// exec runs the executor as the super user.
+func exec(t *testing.T, tctx testContext, executor epp.FunctionExecutor, eventContext epp.EventContext, args ...*obligations.Arg) (interface{}, error) {
+    superUser, _ := context.NewUserContext("super")
+    wu := tctx.pdp.WithUser(superUser)
+    return executor.Exec(wu.Graph(), wu.Prohibitions(), wu.Obligations(), eventContext,
+        obligations.NewFunction(executor.Name(), args), epp.NewFunctionEvaluator())
+}
+
+func fn(name string, args ...*obligations.Arg) *obligations.Arg {
+    return obligations.NewArgFromFunction(obligations.NewFunction(name, args))
+}
+
+func arg(value string) *obligations.Arg {
+    return obligations.NewArg(value)
+}
+
+func TestConcatExecutor(t *testing.T) {
+    tctx := testCtx(t)
+    ctx, _ := context.NewUserContext(tctx.u1.Name)
+    eventContext := epp.NewAssignToEvent(ctx, tctx.oa1, tctx.o1)
+
+    result, err := exec(t, tctx, new(epp.ConcatExecutor), eventContext, fn("child_of_assign"), arg(" in "), fn("current_target"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if result.(string) != "o1 in oa1" {
+        t.Errorf("result should be \"o1 in oa1\", got %q", result)
+    }
+
+    if _, err := exec(t, tctx, new(epp.ConcatExecutor), eventContext); err == nil {
+        t.Errorf("concat should expect at least one argument")
+    }
+}
+
+func TestFormatExecutor(t *testing.T) {
+    tctx := testCtx(t)
+    ctx, _ := context.NewUserContext(tctx.u1.Name)
+    eventContext := epp.NewAssignToEvent(ctx, tctx.oa1, tctx.o1)
+
+    result, err := exec(t, tctx, new(epp.FormatExecutor), eventContext, arg("%s home"), fn("current_user"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if result.(string) != "u1 home" {
+        t.Errorf("result should be \"u1 home\", got %q", result)
+    }
+}
+
+func TestPropertyExecutors(t *testing.T) {
+    tctx := testCtx(t)
+    superUser, _ := context.NewUserContext("super")
+    if err := tctx.pdp.WithUser(superUser).Graph().UpdateNode("o1", graph.ToProperties(graph.PropertyPair{"k", "v"})); err != nil {
+        t.Fatalf("%s", err)
+    }
+    var eventContext epp.EventContext
+
+    value, err := exec(t, tctx, new(epp.GetPropertyExecutor), eventContext, arg("o1"), arg("k"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if value.(string) != "v" {
+        t.Errorf("value should be v")
+    }
+    value, err = exec(t, tctx, new(epp.GetPropertyExecutor), eventContext, fn("get_node", arg("o1"), arg("O")), arg("k2"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if value.(string) != "" {
+        t.Errorf("value of a missing property should be empty")
+    }
+
+    has, err := exec(t, tctx, new(epp.HasPropertyExecutor), eventContext, arg("o1"), arg("k"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !has.(bool) {
+        t.Errorf("o1 should have k")
+    }
+    has, err = exec(t, tctx, new(epp.HasPropertyExecutor), eventContext, arg("o1"), arg("k2"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if has.(bool) {
+        t.Errorf("o1 should not have k2")
+    }
+
+    equals, err := exec(t, tctx, new(epp.PropertyEqualsExecutor), eventContext, arg("o1"), arg("k"), arg("v"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !equals.(bool) {
+        t.Errorf("k should equal v")
+    }
+    equals, err = exec(t, tctx, new(epp.PropertyEqualsExecutor), eventContext, arg("o1"), arg("k"), arg("v2"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if equals.(bool) {
+        t.Errorf("k should not equal v2")
+    }
+
+    if _, err := exec(t, tctx, new(epp.GetPropertyExecutor), eventContext, arg("o2"), arg("k")); err == nil {
+        t.Errorf("o2 should not exist")
+    }
+}
+
+func TestBooleanExecutors(t *testing.T) {
+    tctx := testCtx(t)
+    ctx, _ := context.NewUserContext(tctx.u1.Name)
+    eventContext := epp.NewAssignToEvent(ctx, tctx.oa1, tctx.o1)
+
+    tests := []struct {
+        executor epp.FunctionExecutor
+        args     []*obligations.Arg
+        expected bool
+    }{
+        {new(epp.EqualsExecutor), []*obligations.Arg{fn("current_target"), arg("oa1")}, true},
+        {new(epp.EqualsExecutor), []*obligations.Arg{fn("child_of_assign"), arg("oa1")}, false},
+        {new(epp.AndExecutor), []*obligations.Arg{arg("true"), fn("equals", arg("a"), arg("a"))}, true},
+        {new(epp.AndExecutor), []*obligations.Arg{arg("true"), arg("false")}, false},
+        {new(epp.OrExecutor), []*obligations.Arg{arg("false"), fn("equals", arg("a"), arg("a"))}, true},
+        {new(epp.OrExecutor), []*obligations.Arg{arg("false"), arg("false")}, false},
+        {new(epp.NotExecutor), []*obligations.Arg{arg("false")}, true},
+        {new(epp.NotExecutor), []*obligations.Arg{fn("is_node_type", fn("current_target"), arg("OA"))}, false},
+        {new(epp.IsNodeTypeExecutor), []*obligations.Arg{fn("child_of_assign"), arg("O")}, true},
+        {new(epp.IsNodeTypeExecutor), []*obligations.Arg{arg("u1"), arg("UA")}, false},
+    }
+    for _, test := range tests {
+        result, err := exec(t, tctx, test.executor, eventContext, test.args...)
+        if err != nil {
+            t.Fatalf("%s", err)
+        }
+        if result.(bool) != test.expected {
+            t.Errorf("%s should be %t", test.executor.Name(), test.expected)
+        }
+    }
+
+    if _, err := exec(t, tctx, new(epp.NotExecutor), eventContext, arg("maybe")); err == nil {
+        t.Errorf("not should expect a boolean")
+    }
+}
+
+func TestContainsExecutor(t *testing.T) {
+    tctx := testCtx(t)
+    ctx, _ := context.NewUserContext(tctx.u1.Name)
+    eventContext := epp.NewAssignToEvent(ctx, tctx.oa1, tctx.o1)
+
+    contains, err := exec(t, tctx, new(epp.ContainsExecutor), eventContext, fn("get_children", arg("oa1"), arg("OA")), fn("child_of_assign"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !contains.(bool) {
+        t.Errorf("the children of oa1 should contain o1")
+    }
+
+    contains, err = exec(t, tctx, new(epp.ContainsExecutor), eventContext, fn("get_ancestors", arg("o1")), arg("ua1"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if contains.(bool) {
+        t.Errorf("the ancestors of o1 should not contain ua1")
+    }
+}
+
+func TestGetAncestorsAndDescendantsExecutors(t *testing.T) {
+    tctx := testCtx(t)
+    var eventContext epp.EventContext
+
+    ancestors, err := exec(t, tctx, new(epp.GetAncestorsExecutor), eventContext, arg("o1"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !ancestors.(set.Set).Contains("oa1", "pc1") || ancestors.(set.Set).Contains("o1") || ancestors.(set.Set).Contains("ua1") {
+        t.Errorf("the ancestors of o1 should contain oa1 and pc1 only, got %v", ancestors.(set.Set).ToSlice())
+    }
+
+    descendants, err := exec(t, tctx, new(epp.GetDescendantsExecutor), eventContext, fn("get_node", arg("pc1"), arg("PC")))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !descendants.(set.Set).Contains("oa1", "o1", "ua1", "u1") || descendants.(set.Set).Contains("pc1") {
+        t.Errorf("the descendants of pc1 should contain oa1, o1, ua1 and u1, got %v", descendants.(set.Set).ToSlice())
+    }
+
+    descendants, err = exec(t, tctx, new(epp.GetDescendantsExecutor), eventContext, arg("oa1"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !descendants.(set.Set).Equal(set.NewSet("o1")) {
+        t.Errorf("the descendants of oa1 should be o1, got %v", descendants.(set.Set).ToSlice())
+    }
+}
+
+func TestGetPolicyClassesExecutor(t *testing.T) {
+    tctx := testCtx(t)
+    ctx, _ := context.NewUserContext(tctx.u1.Name)
+    eventContext := epp.NewAssignToEvent(ctx, tctx.oa1, tctx.o1)
+
+    pcs, err := exec(t, tctx, new(epp.GetPolicyClassesExecutor), eventContext, fn("current_user"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !pcs.(set.Set).Equal(set.NewSet("pc1")) {
+        t.Errorf("the policy classes of u1 should be pc1, got %v", pcs.(set.Set).ToSlice())
+    }
+}
+
+func TestCurrentTimeExecutor(t *testing.T) {
+    tctx := testCtx(t)
+    var eventContext epp.EventContext
+    executor := &epp.CurrentTimeExecutor{Now: func() time.Time {
+        return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+    }}
+
+    now, err := exec(t, tctx, executor, eventContext)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if now.(string) != "2021-03-04T05:06:07Z" {
+        t.Errorf("now should be 2021-03-04T05:06:07Z, got %s", now)
+    }
+
+    now, err = exec(t, tctx, executor, eventContext, arg("2006-01-02"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if now.(string) != "2021-03-04" {
+        t.Errorf("now should be 2021-03-04, got %s", now)
+    }
+}
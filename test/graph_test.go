@@ -10,7 +10,9 @@ import (
     "github.com/jtejido/ngac/pkg/pip"
     "github.com/jtejido/ngac/pkg/pip/graph"
     gm "github.com/jtejido/ngac/pkg/pip/graph/memory"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
     obm "github.com/jtejido/ngac/pkg/pip/obligations/memory"
+    "github.com/jtejido/ngac/pkg/pip/prohibitions"
     pm "github.com/jtejido/ngac/pkg/pip/prohibitions/memory"
     "testing"
 )
@@ -60,3 +62,115 @@ func TestPolicyClassReps(t *testing.T) {
         t.Errorf("%s should exist", graph.REP_PROPERTY)
     }
 }
+
+func TestGraphToJSONFromJSON(t *testing.T) {
+    tc := testCtx(t)
+    super, _ := context.NewUserContext("super")
+    b, err := tc.pdp.WithUser(super).ToJSON()
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    // loading the policy on top of itself leaves it untouched
+    if err := tc.pdp.WithUser(super).FromJSON(b); err != nil {
+        t.Fatalf("%s", err)
+    }
+    b2, err := tc.pdp.WithUser(super).ToJSON()
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if string(b) != string(b2) {
+        t.Errorf("expected the policy to be unchanged after loading it again")
+    }
+
+    u1, _ := context.NewUserContext(tc.u1.Name)
+    if _, err := tc.pdp.WithUser(u1).ToJSON(); err == nil {
+        t.Errorf("expected u1 to not be able to serialize the policy")
+    }
+    if err := tc.pdp.WithUser(u1).FromJSON(b); err == nil {
+        t.Errorf("expected u1 to not be able to load a policy")
+    }
+}
+
+func TestTxRejectsCycle(t *testing.T) {
+    tc := testCtx(t)
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    err := wu.RunTx(func(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations) error {
+        if _, err := g.CreateNode("oa2", graph.OA, nil, tc.oa1.Name); err != nil {
+            return err
+        }
+        return g.Assign(tc.oa1.Name, "oa2")
+    })
+
+    cycleErr, ok := err.(*graph.CycleError)
+    if !ok {
+        t.Fatalf("expected a cycle error, got %v", err)
+    }
+    if cycleErr.Error() != "assigning oa1 to oa2 would create the cycle oa1 -> oa2 -> oa1" {
+        t.Errorf("unexpected error %s", cycleErr)
+    }
+    if wu.Graph().Exists("oa2") {
+        t.Errorf("expected nothing of the tx to be committed")
+    }
+}
+
+func TestQueryFiltersByPermissions(t *testing.T) {
+    tc := testCtx(t)
+    superCtx, _ := context.NewUserContext("super")
+    g := tc.pdp.WithUser(superCtx).Graph()
+    if _, err := g.CreateNode("o2", graph.O, graph.ToProperties(graph.PropertyPair{"kind", "doc"}), tc.oa1.Name); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if _, err := g.CreateNode("oa2", graph.OA, nil, tc.pc1.Name); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if _, err := g.CreateNode("o3", graph.O, graph.ToProperties(graph.PropertyPair{"kind", "doc"}), "oa2"); err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    q := &graph.Query{Types: []graph.NodeType{graph.O}, Where: graph.Equals("kind", "doc")}
+    nodes, err := graph.Find(g, q)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if len(nodes) != 2 {
+        t.Errorf("expected the super user to find o2 and o3, got %d nodes", len(nodes))
+    }
+
+    // u1 can only see the objects of oa1, the page is taken from those
+    u1Ctx, _ := context.NewUserContext(tc.u1.Name)
+    q = &graph.Query{Types: []graph.NodeType{graph.O}, Desc: true, Limit: 1}
+    nodes, err = graph.Find(tc.pdp.WithUser(u1Ctx).Graph(), q)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if len(nodes) != 1 || nodes[0].Name != "o2" {
+        t.Errorf("expected u1 to find o2, got %v", nodes)
+    }
+}
+
+func TestQueryInTx(t *testing.T) {
+    tc := testCtx(t)
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    err := wu.RunTx(func(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations) error {
+        if _, err := g.CreateNode("o2", graph.O, graph.ToProperties(graph.PropertyPair{"kind", "doc"}), tc.oa1.Name); err != nil {
+            return err
+        }
+
+        nodes, err := graph.Find(g, &graph.Query{Types: []graph.NodeType{graph.O}})
+        if err != nil {
+            return err
+        }
+        if len(nodes) != 2 || nodes[0].Name != "o1" || nodes[1].Name != "o2" {
+            t.Errorf("expected the tx to find o1 and o2, got %v", nodes)
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+}
@@ -0,0 +1,105 @@
+package ngac
+
+import (
+    "encoding/json"
+    "github.com/jtejido/ngac/pkg/context"
+    "github.com/jtejido/ngac/pkg/operations"
+    . "github.com/jtejido/ngac/pkg/pdp"
+    "github.com/jtejido/ngac/pkg/pip"
+    "github.com/jtejido/ngac/pkg/pip/graph"
+    gm "github.com/jtejido/ngac/pkg/pip/graph/memory"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
+    obm "github.com/jtejido/ngac/pkg/pip/obligations/memory"
+    "github.com/jtejido/ngac/pkg/pip/prohibitions"
+    pm "github.com/jtejido/ngac/pkg/pip/prohibitions/memory"
+    "testing"
+)
+
+func TestCheckIntegrityOfCleanPolicy(t *testing.T) {
+    tc := testCtx(t)
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    obligation, err := obligations.ParseBytes("super", []byte(recordObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+    wu.Prohibitions().Add(prohibitions.NewProhibition("deny", tc.ua1.Name, map[string]bool{tc.oa1.Name: false}, operations.NewOperationSet("read"), false))
+
+    if report := CheckIntegrity(wu); len(report.Problems) != 0 {
+        t.Errorf("expected no problem, got\n%s", report)
+    }
+}
+
+func TestCheckIntegrity(t *testing.T) {
+    g := gm.New()
+    g.CreatePolicyClass("pc1", graph.ToProperties(graph.PropertyPair{graph.REP_PROPERTY, "pc1_rep"}))
+    g.CreateNode("oa1", graph.OA, nil, "pc1")
+    g.CreateNode("ua1", graph.UA, nil, "pc1")
+    g.CreatePolicyClass("pc2", nil)
+    g.CreateNode("oa2", graph.OA, nil, "pc2")
+    g.CreateNode("o1", graph.O, nil, "oa2")
+    g.Associate("ua1", "oa1", operations.NewOperationSet("read"))
+    // an import leaving oa2 and o1 out of any policy class
+    g.RemoveNode("pc2")
+
+    p := pm.New()
+    p.Add(prohibitions.NewProhibition("deny", "gone", map[string]bool{"oa1": false, "oa9": true}, operations.NewOperationSet("read"), false))
+
+    o := obm.New()
+    obligation, err := obligations.ParseBytes("super", []byte(`{
+      "label": "move",
+      "rules": [{
+        "label": "r",
+        "event": {"operations": ["assign"], "target": {"policyElements": [{"name": "o9", "type": "O"}]}},
+        "response": {
+          "actions": [
+            {"create": [{"what": {"name": "oa3", "type": "OA"}, "where": {"name": "oa1", "type": "OA"}}]},
+            {"assign": [{"what": {"name": "o1", "type": "O"}, "where": {"name": "oa3", "type": "OA"}}]}
+          ]
+        }
+      }]
+    }`))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    o.Add(obligation, true)
+
+    report := CheckIntegrity(pip.NewPIP(g, p, o))
+    expected := []struct {
+        kind, element, node string
+    }{
+        {MISSING_OBLIGATION_NODE, "move", "o9"},
+        {graph.MISSING_POLICY_CLASS_PROPERTY, "pc1", ""},
+        {graph.MISSING_POLICY_CLASS_PROPERTY, "pc1", ""},
+        {MISSING_PROHIBITION_CONTAINER, "deny", "oa9"},
+        {MISSING_PROHIBITION_SUBJECT, "deny", "gone"},
+        {graph.UNREACHABLE_NODE, "o1", ""},
+        {graph.UNREACHABLE_NODE, "oa2", ""},
+    }
+    if len(report.Problems) != len(expected) {
+        t.Fatalf("expected %d problems, got\n%s", len(expected), report)
+    }
+    for i, e := range expected {
+        problem := report.Problems[i]
+        if problem.Kind != e.kind || problem.Element != e.element || (e.node != "" && problem.Nodes[0] != e.node) {
+            t.Errorf("%d: expected %s on %s, got %s", i, e.kind, e.element, problem)
+        }
+    }
+    if report.Problems[0].Pointer != "/rules/0/event/target/policyElements/0" {
+        t.Errorf("unexpected pointer %s", report.Problems[0].Pointer)
+    }
+    if report.OK() {
+        t.Errorf("expected the report to have errors")
+    }
+
+    b, err := json.Marshal(report)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    var decoded graph.Report
+    if err := json.Unmarshal(b, &decoded); err != nil || len(decoded.Problems) != len(expected) || decoded.Problems[4].Severity != graph.SEVERITY_WARNING {
+        t.Errorf("expected the report to round trip through JSON, got %s", b)
+    }
+}
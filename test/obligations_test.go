@@ -0,0 +1,241 @@
+package ngac
+
+import (
+    "bytes"
+    "encoding/json"
+    "github.com/jtejido/ngac/pkg/context"
+    "github.com/jtejido/ngac/pkg/operations"
+    "github.com/jtejido/ngac/pkg/pip/graph"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
+    "github.com/jtejido/ngac/pkg/pip/prohibitions"
+    "os"
+    "testing"
+)
+
+func loadObligation(t *testing.T, file string) *obligations.Obligation {
+    b, err := os.ReadFile(file)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    obligation, err := obligations.ParseBytes("super", b)
+    if err != nil {
+        t.Fatalf("failed to parse %s: %s", file, err)
+    }
+
+    return obligation
+}
+
+func TestParseActions(t *testing.T) {
+    obligation := loadObligation(t, "test_actions.json")
+    if len(obligation.Rules) != 2 {
+        t.Fatalf("expected 2 rules, got %d", len(obligation.Rules))
+    }
+
+    response := obligation.Rules[0].ResponsePattern
+    if response.NegatedCondition == nil || len(response.NegatedCondition.Condition) != 1 {
+        t.Errorf("expected the negated response condition to be read")
+    }
+    if len(response.Actions) != 6 {
+        t.Fatalf("expected 6 actions, got %d", len(response.Actions))
+    }
+
+    assign, ok := response.Actions[0].(*obligations.AssignAction)
+    if !ok {
+        t.Fatalf("expected an assign action")
+    }
+    if assign.Condition() == nil || len(assign.Condition().Condition) != 1 {
+        t.Errorf("expected the assign condition to be read")
+    }
+    if len(assign.Assignments) != 1 || assign.Assignments[0].What.Name != "o1" || assign.Assignments[0].Where.Name != "oa2" {
+        t.Errorf("unexpected assignments")
+    }
+
+    grant, ok := response.Actions[1].(*obligations.GrantAction)
+    if !ok {
+        t.Fatalf("expected a grant action")
+    }
+    if grant.Subject.Name != "ua1" || grant.Target.Name != "oa2" || len(grant.Operations) != 1 || grant.Operations[0] != "read" {
+        t.Errorf("unexpected grant %+v", grant)
+    }
+
+    deny, ok := response.Actions[2].(*obligations.DenyAction)
+    if !ok {
+        t.Fatalf("expected a deny action")
+    }
+    if deny.Label != "deny u1" || deny.Subject.Name != "u1" || deny.Subject.Type != "U" {
+        t.Errorf("unexpected deny %+v", deny)
+    }
+    if !deny.Target.Intersection || deny.Target.Complement || len(deny.Target.Containers) != 2 {
+        t.Fatalf("unexpected deny target %+v", deny.Target)
+    }
+    if deny.Target.Containers[0].Complement || !deny.Target.Containers[1].Complement || deny.Target.Containers[1].Name != "oa2" {
+        t.Errorf("unexpected deny containers")
+    }
+
+    deny = response.Actions[3].(*obligations.DenyAction)
+    if deny.Subject.Function == nil || deny.Subject.Function.Name != "current_user" {
+        t.Errorf("expected the deny subject to be a function")
+    }
+
+    del, ok := response.Actions[4].(*obligations.DeleteAction)
+    if !ok {
+        t.Fatalf("expected a delete action")
+    }
+    if len(del.Nodes) != 1 || del.Nodes[0].Name != "tmp" {
+        t.Errorf("unexpected nodes to delete")
+    }
+    if len(del.Associations) != 1 || del.Associations[0].Subject.Name != "ua1" || del.Associations[0].Target.Name != "oa1" {
+        t.Errorf("unexpected associations to delete")
+    }
+    if len(del.Prohibitions) != 2 || del.Prohibitions[0] != "old deny" || del.Prohibitions[1] != "older deny" {
+        t.Errorf("unexpected prohibitions to delete %v", del.Prohibitions)
+    }
+    if len(del.Rules) != 1 || del.Rules[0] != "never fired" {
+        t.Errorf("unexpected rules to delete %v", del.Rules)
+    }
+
+    create, ok := response.Actions[5].(*obligations.CreateAction)
+    if !ok {
+        t.Fatalf("expected a create action")
+    }
+    if len(create.CreateNodesList) != 1 || create.CreateNodesList[0].What.Properties["k"] != "v" {
+        t.Errorf("unexpected nodes to create")
+    }
+    if len(create.Rules) != 1 || create.Rules[0].Label != "created rule" {
+        t.Fatalf("expected the nested rule to be read")
+    }
+    nested := create.Rules[0].ResponsePattern.Actions[0].(*obligations.DeleteAction)
+    if nested.Assignments == nil || len(nested.Assignments.Assignments) != 1 || len(nested.Rules) != 1 {
+        t.Errorf("unexpected nested delete action")
+    }
+
+    rule := obligation.Rules[1]
+    if rule.EventPattern.Subject.Process == nil || rule.EventPattern.Subject.Process.Value != "12" {
+        t.Errorf("expected the process subject to be read")
+    }
+    deny = rule.ResponsePattern.Actions[0].(*obligations.DenyAction)
+    if deny.NegatedCondition() == nil || deny.Subject.Process == nil || deny.Subject.Process.Value != "12" {
+        t.Errorf("unexpected process deny %+v", deny)
+    }
+    if !deny.Target.Complement || deny.Target.Containers[0].Function == nil || !deny.Target.Containers[0].Complement {
+        t.Errorf("unexpected function deny target")
+    }
+    del = rule.ResponsePattern.Actions[1].(*obligations.DeleteAction)
+    if len(del.Nodes) != 2 || del.Nodes[0].Name != "o1" || del.Nodes[1].Properties["k"] != "v" {
+        t.Errorf("unexpected nodes to delete")
+    }
+}
+
+func TestParseInvalidActions(t *testing.T) {
+    invalid := []string{
+        `{"label": "l", "rules": [{"label": "r", "event": {}, "response": {"actions": [{"unknown": {}}]}}]}`,
+        `{"label": "l", "rules": [{"label": "r", "event": {}, "response": {"actions": [{"assign": [{"what": {"name": "o1", "type": "O"}}]}]}}]}`,
+        `{"label": "l", "rules": [{"label": "r", "event": {}, "response": {"actions": [{"grant": {"subject": {"name": "ua1", "type": "UA"}, "target": {"name": "oa1", "type": "OA"}}}]}}]}`,
+        `{"label": "l", "rules": [{"label": "r", "event": {}, "response": {"actions": [{"deny": {"subject": {"name": "u1", "type": "U"}, "operations": ["read"]}}]}}]}`,
+        `{"label": "l", "rules": [{"label": "r", "event": {}, "response": {"actions": [{"delete": {"rules": [{}]}}]}}]}`,
+        `{"label": "l", "rules": [{"label": "r", "event": {}, "response": {"actions": [{"create": [{"what": {"name": "n", "type": "OA", "properties": ["k"]}, "where": {"name": "oa1", "type": "OA"}}]}]}}]}`,
+    }
+
+    for _, doc := range invalid {
+        if err := json.Unmarshal([]byte(doc), obligations.NewObligation("super")); err == nil {
+            t.Errorf("expected an error parsing %s", doc)
+        }
+    }
+}
+
+func TestObligationRoundTrip(t *testing.T) {
+    for _, file := range []string{"test_event.json", "test_actions.json"} {
+        obligation := loadObligation(t, file)
+
+        b1, err := json.Marshal(obligation)
+        if err != nil {
+            t.Fatalf("%s", err)
+        }
+        parsed, err := obligations.ParseBytes("super", b1)
+        if err != nil {
+            t.Fatalf("failed to parse marshaled %s: %s", file, err)
+        }
+
+        b2, err := json.Marshal(parsed)
+        if err != nil {
+            t.Fatalf("%s", err)
+        }
+        if !bytes.Equal(b1, b2) {
+            t.Errorf("%s changed after a round trip\n%s\n%s", file, b1, b2)
+        }
+    }
+}
+
+func TestApplyActions(t *testing.T) {
+    tc := testCtx(t)
+    ctx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(ctx)
+    g := wu.Graph()
+
+    oa2, err := g.CreateNode("oa2", graph.OA, nil, tc.pc1.Name)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    o2, err := g.CreateNode("o2", graph.O, nil, oa2.Name)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if _, err := g.CreateNode("tmp", graph.OA, nil, tc.pc1.Name); err != nil {
+        t.Fatalf("%s", err)
+    }
+    for _, label := range []string{"old deny", "older deny"} {
+        wu.Prohibitions().Add(prohibitions.NewProhibition(label, tc.u1.Name, map[string]bool{tc.oa1.Name: false}, operations.NewOperationSet("read"), false))
+    }
+
+    wu.Obligations().Add(loadObligation(t, "test_actions.json"), true)
+
+    // fires the "assign to" event on oa1
+    if err := g.Assign(o2.Name, tc.oa1.Name); err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    if !g.IsAssigned(tc.o1.Name, oa2.Name) {
+        t.Errorf("o1 should have been assigned to oa2")
+    }
+
+    assocs, err := g.SourceAssociations(tc.ua1.Name)
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if _, ok := assocs[tc.oa1.Name]; ok {
+        t.Errorf("the association between ua1 and oa1 should have been deleted")
+    }
+    if ops, ok := assocs[oa2.Name]; !ok || !ops.Contains("read") {
+        t.Errorf("ua1 should have been granted read on oa2")
+    }
+
+    pro := wu.Prohibitions().Get("deny u1")
+    if pro == nil {
+        t.Fatalf("deny u1 should have been created")
+    }
+    if pro.Subject != tc.u1.Name || !pro.Intersection || !pro.Operations.Contains("write") {
+        t.Errorf("unexpected prohibition %+v", pro)
+    }
+    if containers := pro.Containers(); len(containers) != 2 || containers[tc.oa1.Name] || !containers[oa2.Name] {
+        t.Errorf("unexpected prohibition containers %v", containers)
+    }
+    if pro := wu.Prohibitions().Get("deny current user"); pro == nil || pro.Subject != "super" {
+        t.Errorf("the current user should have been denied")
+    }
+    if wu.Prohibitions().Get("old deny") != nil || wu.Prohibitions().Get("older deny") != nil {
+        t.Errorf("old prohibitions should have been deleted")
+    }
+
+    if g.Exists("tmp") {
+        t.Errorf("tmp should have been deleted")
+    }
+    if n, err := g.Node("created"); err != nil || n.Properties["k"] != "v" || !g.IsAssigned("created", oa2.Name) {
+        t.Errorf("created should have been created in oa2")
+    }
+
+    rules := wu.Obligations().Get("actions test").Rules
+    if len(rules) != 2 || rules[0].Label != "assign to oa1" || rules[1].Label != "created rule" {
+        t.Errorf("expected the rule to be replaced by the created rule")
+    }
+}
@@ -0,0 +1,118 @@
+package ngac
+
+import (
+    "github.com/jtejido/ngac/pkg/context"
+    "github.com/jtejido/ngac/pkg/pip/graph"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
+    "testing"
+)
+
+func TestObligationRules(t *testing.T) {
+    recorder := new(recordEventExecutor)
+    tc := testCtx(t, recorder)
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    obligation, err := obligations.ParseBytes("super", []byte(recordObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+
+    update := func(value string) {
+        if err := wu.Graph().UpdateNode(tc.o1.Name, graph.ToProperties(graph.PropertyPair{"k", value})); err != nil {
+            t.Fatalf("%s", err)
+        }
+    }
+
+    // a disabled rule doesn't fire, the obligation stays enabled
+    if err := wu.Obligations().SetRuleEnable("record", "record o1", false); err != nil {
+        t.Fatalf("%s", err)
+    }
+    update("v1")
+    if len(recorder.events) != 0 {
+        t.Fatalf("expected the disabled rule not to fire, got %d events", len(recorder.events))
+    }
+    if err := wu.Obligations().SetRuleEnable("record", "record o1", true); err != nil {
+        t.Fatalf("%s", err)
+    }
+    update("v2")
+    if len(recorder.events) != 1 {
+        t.Fatalf("expected the enabled rule to fire, got %d events", len(recorder.events))
+    }
+
+    // the replacing rule records the updates of oa1 instead
+    response := obligation.Rules[0].ResponsePattern
+    replacement := obligations.NewRule()
+    replacement.Label = "record oa1"
+    replacement.EventPattern = &obligations.EventPattern{
+        Operations: []string{"update node"},
+        Target:     &obligations.Target{PolicyElements: []*obligations.EvrNode{obligations.NewEvrNode(tc.oa1.Name, "OA", nil)}},
+    }
+    replacement.ResponsePattern = response
+    if err := wu.Obligations().ReplaceRule("record", "record o1", replacement); err != nil {
+        t.Fatalf("%s", err)
+    }
+    update("v3")
+    if len(recorder.events) != 1 {
+        t.Fatalf("expected the replaced rule not to fire, got %d events", len(recorder.events))
+    }
+
+    if err := wu.Obligations().AddRule("record", obligation.Rules[0]); err != nil {
+        t.Fatalf("%s", err)
+    }
+    rules, err := wu.Obligations().Rules("record")
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if len(rules) != 2 || rules[0].Label != "record oa1" || rules[1].Label != "record o1" {
+        t.Fatalf("expected rules record oa1 and record o1, got %v", rules)
+    }
+    update("v4")
+    if len(recorder.events) != 2 {
+        t.Fatalf("expected the added rule to fire, got %d events", len(recorder.events))
+    }
+
+    if err := wu.Obligations().RemoveRule("record", "record o1"); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if err := wu.Obligations().RemoveRule("record", "record o1"); err == nil {
+        t.Errorf("expected removing a missing rule to fail")
+    }
+    update("v5")
+    if len(recorder.events) != 2 {
+        t.Fatalf("expected the removed rule not to fire, got %d events", len(recorder.events))
+    }
+}
+
+func TestObligationRulesGuard(t *testing.T) {
+    tc := testCtx(t)
+    superCtx, _ := context.NewUserContext("super")
+    obligation, err := obligations.ParseBytes("super", []byte(recordObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    tc.pdp.WithUser(superCtx).Obligations().Add(obligation, true)
+
+    userCtx, _ := context.NewUserContext(tc.u1.Name)
+    o := tc.pdp.WithUser(userCtx).Obligations()
+
+    if err := o.AddRule("record", obligations.NewRule()); err == nil || err.Error() != "unauthorized permissions to add an obligation rule" {
+        t.Errorf("expected u1 not to be allowed to add a rule, got %v", err)
+    }
+    if err := o.RemoveRule("record", "record o1"); err == nil {
+        t.Errorf("expected u1 not to be allowed to remove a rule")
+    }
+    if err := o.ReplaceRule("record", "record o1", obligations.NewRule()); err == nil {
+        t.Errorf("expected u1 not to be allowed to replace a rule")
+    }
+    if err := o.SetRuleEnable("record", "record o1", false); err == nil {
+        t.Errorf("expected u1 not to be allowed to disable a rule")
+    }
+    if _, err := o.Rules("record"); err == nil {
+        t.Errorf("expected u1 not to be allowed to get the rules")
+    }
+    if rules, _ := tc.pdp.WithUser(superCtx).Obligations().Rules("record"); len(rules) != 1 || rules[0].Disabled {
+        t.Errorf("expected the rules to be unchanged")
+    }
+}
@@ -0,0 +1,246 @@
+package ngac
+
+import (
+    "github.com/jtejido/ngac/pkg/context"
+    "github.com/jtejido/ngac/pkg/epp"
+    "github.com/jtejido/ngac/pkg/operations"
+    "github.com/jtejido/ngac/pkg/pip/graph"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
+    "testing"
+    "time"
+)
+
+const expireObligation = `{
+  "label": "contractors",
+  "rules": [{
+    "label": "expire",
+    "event": {
+      "operations": ["assign"],
+      "target": {"containers": [{"name": "contractors", "type": "UA"}]},
+      "schedule": {"after": "24h"}
+    },
+    "response": {
+      "actions": [{
+        "delete": {
+          "assignments": [{"what": {"function": {"name": "current_target"}}, "where": {"name": "contractors", "type": "UA"}}]
+        }
+      }]
+    }
+  }]
+}`
+
+const revokeObligation = `{
+  "label": "revoke",
+  "rules": [{
+    "label": "nightly",
+    "event": {"schedule": {"cron": "0 2 * * *"}},
+    "response": {
+      "actions": [{
+        "delete": {
+          "associations": [{"subject": {"name": "ua1", "type": "UA"}, "target": {"name": "oa1", "type": "OA"}}]
+        }
+      }]
+    }
+  }]
+}`
+
+func TestExpireAfterEvent(t *testing.T) {
+    clock := epp.NewManualClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+    tc := testCtxWithOptions(t, epp.NewEPPOptions().WithClock(clock))
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    if _, err := wu.Graph().CreateNode("contractors", graph.UA, nil, tc.pc1.Name); err != nil {
+        t.Fatalf("%s", err)
+    }
+    obligation, err := obligations.ParseBytes("super", []byte(expireObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if err := wu.ValidateObligation(obligation); err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+
+    if err := wu.Graph().Assign(tc.u1.Name, "contractors"); err != nil {
+        t.Fatalf("%s", err)
+    }
+    scheduler := tc.pdp.EPP().Scheduler()
+    pending := scheduler.Pending()
+    if len(pending) != 1 || pending[0].Target != tc.u1.Name || !pending[0].Due.Equal(clock.Now().Add(24*time.Hour)) {
+        t.Fatalf("expected a timer on u1 due in 24h, got %v", pending)
+    }
+
+    assigned := func() bool {
+        return wu.Graph().Children("contractors").Contains(tc.u1.Name)
+    }
+
+    clock.Advance(23 * time.Hour)
+    if err := scheduler.Tick(); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !assigned() {
+        t.Fatalf("expected u1 to be a contractor until the timer is due")
+    }
+
+    clock.Advance(time.Hour)
+    if err := scheduler.Tick(); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if assigned() {
+        t.Errorf("expected the assignment of u1 to expire")
+    }
+    if pending := scheduler.Pending(); len(pending) != 0 {
+        t.Errorf("expected no pending timer, got %v", pending)
+    }
+}
+
+func TestInvalidAfterFailsBeforeCommit(t *testing.T) {
+    clock := epp.NewManualClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+    tc := testCtxWithOptions(t, epp.NewEPPOptions().WithClock(clock))
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    if _, err := wu.Graph().CreateNode("contractors", graph.UA, nil, tc.pc1.Name); err != nil {
+        t.Fatalf("%s", err)
+    }
+    obligation, err := obligations.ParseBytes("super", []byte(expireObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    // an obligation built in code skips the schema
+    obligation.Rules[0].EventPattern.Schedule.After = "10 minutes"
+    wu.Obligations().Add(obligation, true)
+
+    if err := wu.Graph().Assign(tc.u1.Name, "contractors"); err == nil {
+        t.Errorf("expected the invalid schedule to fail the obligation")
+    }
+    if pending := tc.pdp.EPP().Scheduler().Pending(); len(pending) != 0 {
+        t.Errorf("expected no timer, got %v", pending)
+    }
+}
+
+func TestCancelTimerOfDisabledRule(t *testing.T) {
+    clock := epp.NewManualClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+    tc := testCtxWithOptions(t, epp.NewEPPOptions().WithClock(clock))
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    if _, err := wu.Graph().CreateNode("contractors", graph.UA, nil, tc.pc1.Name); err != nil {
+        t.Fatalf("%s", err)
+    }
+    obligation, err := obligations.ParseBytes("super", []byte(expireObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+    if err := wu.Graph().Assign(tc.u1.Name, "contractors"); err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    if err := wu.Obligations().SetRuleEnable("contractors", "expire", false); err != nil {
+        t.Fatalf("%s", err)
+    }
+    clock.Advance(25 * time.Hour)
+    if err := tc.pdp.EPP().Scheduler().Tick(); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if children := wu.Graph().Children("contractors"); !children.Contains(tc.u1.Name) {
+        t.Errorf("expected the disabled rule not to fire")
+    }
+    if pending := tc.pdp.EPP().Scheduler().Pending(); len(pending) != 0 {
+        t.Errorf("expected the timer to be dropped, got %v", pending)
+    }
+}
+
+func TestCronSchedule(t *testing.T) {
+    clock := epp.NewManualClock(time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC))
+    tc := testCtxWithOptions(t, epp.NewEPPOptions().WithClock(clock))
+    superCtx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(superCtx)
+
+    obligation, err := obligations.ParseBytes("super", []byte(revokeObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if err := wu.ValidateObligation(obligation); err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+
+    granted := func() bool {
+        associations, _ := wu.Graph().SourceAssociations(tc.ua1.Name)
+        _, ok := associations[tc.oa1.Name]
+        return ok
+    }
+
+    scheduler := tc.pdp.EPP().Scheduler()
+    if err := scheduler.Tick(); err != nil {
+        t.Fatalf("%s", err)
+    }
+    pending := scheduler.Pending()
+    if len(pending) != 1 || !pending[0].Due.Equal(time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC)) {
+        t.Fatalf("expected a timer due at 02:00, got %v", pending)
+    }
+    if !granted() {
+        t.Fatalf("expected the grant to stay until 02:00")
+    }
+
+    clock.Set(time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC))
+    if err := scheduler.Tick(); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if granted() {
+        t.Errorf("expected the grant to be revoked at 02:00")
+    }
+    pending = scheduler.Pending()
+    if len(pending) != 1 || !pending[0].Due.Equal(time.Date(2026, 1, 2, 2, 0, 0, 0, time.UTC)) {
+        t.Errorf("expected the timer to move to the next day, got %v", pending)
+    }
+
+    // the grant made again is revoked the next night
+    if err := wu.Graph().Associate(tc.ua1.Name, tc.oa1.Name, operations.NewOperationSet("read")); err != nil {
+        t.Fatalf("%s", err)
+    }
+    clock.Advance(24 * time.Hour)
+    if err := scheduler.Tick(); err != nil {
+        t.Fatalf("%s", err)
+    }
+    if granted() {
+        t.Errorf("expected the grant to be revoked the next night")
+    }
+}
+
+func TestValidateSchedule(t *testing.T) {
+    tc := testCtx(t)
+    superCtx, _ := context.NewUserContext("super")
+
+    tests := []struct {
+        schedule string
+        pointer  string
+    }{
+        {`"schedule": {"cron": "0 25 * * *"}`, "/rules/0/event/schedule/cron"},
+        {`"schedule": {"after": "0s"}`, "/rules/0/event/schedule/after"},
+        {`"operations": ["assign"], "schedule": {"cron": "@daily"}`, "/rules/0/event/schedule/cron"},
+    }
+    for _, test := range tests {
+        doc := `{"label": "l", "rules": [{"label": "r", "event": {` + test.schedule + `}, "response": {"actions": []}}]}`
+        obligation, err := obligations.ParseBytes("super", []byte(doc))
+        if err != nil {
+            t.Fatalf("%s", err)
+        }
+        err = tc.pdp.WithUser(superCtx).ValidateObligation(obligation)
+        validationErr, ok := err.(*obligations.ValidationError)
+        if !ok || len(validationErr.Violations) != 1 || validationErr.Violations[0].Pointer != test.pointer {
+            t.Errorf("%s: expected a violation at %s, got %v", test.schedule, test.pointer, err)
+        }
+    }
+
+    // malformed schedules are rejected when parsing
+    for _, schedule := range []string{`{"after": "10 minutes"}`, `{"after": "soon"}`, `{"cron": "every day"}`} {
+        doc := `{"label": "l", "rules": [{"label": "r", "event": {"schedule": ` + schedule + `}, "response": {"actions": []}}]}`
+        if _, err := obligations.ParseBytes("super", []byte(doc)); err == nil {
+            t.Errorf("%s: expected the schedule to be rejected by the schema", schedule)
+        }
+    }
+}
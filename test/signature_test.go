@@ -0,0 +1,185 @@
+package ngac
+
+import (
+    "errors"
+    "github.com/jtejido/ngac/pkg/context"
+    "github.com/jtejido/ngac/pkg/epp"
+    "github.com/jtejido/ngac/pkg/pip/graph"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
+    "github.com/jtejido/ngac/pkg/pip/prohibitions"
+    "testing"
+)
+
+// maybeExecutor returns a string without declaring a signature.
+type maybeExecutor struct {
+    result string
+}
+
+func (f *maybeExecutor) Name() string {
+    return "maybe"
+}
+
+func (f *maybeExecutor) NumParams() int {
+    return 0
+}
+
+func (f *maybeExecutor) Exec(g graph.Graph, p prohibitions.Prohibitions, o obligations.Obligations,
+    eventCtx epp.EventContext, function *obligations.Function, functionEvaluator *epp.FunctionEvaluator) (interface{}, error) {
+    return f.result, nil
+}
+
+type typedMaybeExecutor struct {
+    maybeExecutor
+}
+
+func (f *typedMaybeExecutor) Signature() *epp.Signature {
+    return &epp.Signature{Returns: epp.BOOLEAN}
+}
+
+func TestEvalChecksSignature(t *testing.T) {
+    tctx := testCtx(t)
+    superUser, _ := context.NewUserContext("super")
+    wu := tctx.pdp.WithUser(superUser)
+    eventContext := epp.NewAssignToEvent(superUser, tctx.oa1, tctx.o1)
+    fe := epp.NewFunctionEvaluator()
+
+    eval := func(name string, args ...*obligations.Arg) (interface{}, error) {
+        return fe.Eval(wu.Graph(), wu.Prohibitions(), wu.Obligations(), eventContext, obligations.NewFunction(name, args))
+    }
+
+    tests := []struct {
+        name string
+        args []*obligations.Arg
+        err  string
+    }{
+        {"unknown", nil, "unknown is not a recognized function"},
+        {"get_node", []*obligations.Arg{arg("o1")}, "get_node expects 2 to 3 arguments but got 1"},
+        {"and", []*obligations.Arg{arg("true"), arg("maybe")}, `and expects argument 2 to be a boolean but got "maybe"`},
+        {"get_node_name", []*obligations.Arg{fn("get_children", arg("oa1"), arg("OA"))}, "get_node_name expects argument 1 to be a node but get_children returns a set"},
+        {"contains", []*obligations.Arg{arg("o1"), arg("o1")}, `contains expects argument 1 to be a set but got "o1"`},
+    }
+    for _, test := range tests {
+        if _, err := eval(test.name, test.args...); err == nil || err.Error() != test.err {
+            t.Errorf("%s: expected %q, got %v", test.name, test.err, err)
+        }
+    }
+
+    // a node is given by its name where a string is expected
+    name, err := eval("get_node_name", fn("get_node", arg("o1"), arg("O")))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if name.(string) != "o1" {
+        t.Errorf("expected o1, got %v", name)
+    }
+    contained, err := eval("is_node_contained_in", arg("o1"), arg("oa1"))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !contained.(bool) {
+        t.Errorf("expected o1 to be contained in oa1")
+    }
+
+    // the result is coerced to the declared type
+    fe.Add(&typedMaybeExecutor{maybeExecutor{result: "true"}})
+    b, err := fe.EvalBool(wu.Graph(), wu.Prohibitions(), wu.Obligations(), eventContext, obligations.NewFunction("maybe", nil))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if !b {
+        t.Errorf("expected maybe to return true")
+    }
+    fe.Add(&typedMaybeExecutor{maybeExecutor{result: "perhaps"}})
+    if _, err := eval("maybe"); err == nil || err.Error() != "maybe: expected a boolean but got perhaps" {
+        t.Errorf("expected the result not to be a boolean, got %v", err)
+    }
+}
+
+func TestCheckFunction(t *testing.T) {
+    fe := epp.NewFunctionEvaluator()
+
+    nested := obligations.NewFunction("not", []*obligations.Arg{fn("is_node_contained_in", fn("current_user"), fn("to_props"))})
+    if err := fe.Check(nested, epp.BOOLEAN); err == nil || err.Error() != "to_props returns a properties but a node is expected" {
+        t.Errorf("expected the nested function to be checked, got %v", err)
+    }
+    if err := fe.Check(obligations.NewFunction("get_node", []*obligations.Arg{arg("o1"), arg("O")}), epp.BOOLEAN); err == nil {
+        t.Errorf("expected get_node not to return a boolean")
+    }
+    if err := fe.Check(obligations.NewFunction("current_user", nil), epp.STRING); err != nil {
+        t.Errorf("expected a node to be given by its name, got %v", err)
+    }
+}
+
+const maybeObligation = `{
+  "label": "maybe",
+  "rules": [{
+    "label": "maybe",
+    "event": {"operations": ["assign to"]},
+    "response": {
+      "condition": [{"function": {"name": "maybe"}}],
+      "actions": [{"create": [{"what": {"name": "oa2", "type": "OA"}, "where": {"name": "pc1", "type": "PC"}}]}]
+    }
+  }]
+}`
+
+func TestConditionNotBoolean(t *testing.T) {
+    tctx := testCtx(t)
+    superUser, _ := context.NewUserContext("super")
+    fe := epp.NewFunctionEvaluator()
+    fe.Add(&maybeExecutor{result: "maybe"})
+
+    obligation, err := obligations.ParseBytes("super", []byte(maybeObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    _, err = epp.DryRun(tctx.pdp.WithUser(superUser), fe, obligation, epp.NewAssignToEvent(superUser, tctx.oa1, tctx.o1))
+    if err == nil || err.Error() != "maybe: expected a boolean but got maybe" {
+        t.Errorf("expected the condition to fail, got %v", err)
+    }
+}
+
+const mistypedObligation = `{
+  "label": "mistyped",
+  "rules": [{
+    "label": "rule1",
+    "event": {"operations": ["assign to"]},
+    "response": {
+      "condition": [{"function": {"name": "get_node_name", "args": [{"function": {"name": "current_user"}}]}}],
+      "actions": [
+        {"assign": [{"what": {"function": {"name": "get_children", "args": ["oa1", "OA"]}}, "where": {"name": "oa1", "type": "OA"}}]},
+        {"function": {"name": "contains", "args": [{"function": {"name": "get_ancestors", "args": ["o1"]}}, {"function": {"name": "not", "args": ["no"]}}]}}
+      ]
+    }
+  }]
+}`
+
+func TestValidateFunctionTypes(t *testing.T) {
+    tctx := testCtx(t)
+    ctx, _ := context.NewUserContext("super")
+
+    obligation, err := obligations.ParseBytes("super", []byte(mistypedObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    err = tctx.pdp.WithUser(ctx).ValidateObligation(obligation)
+    var validationErr *obligations.ValidationError
+    if !errors.As(err, &validationErr) {
+        t.Fatalf("expected a validation error, got %v", err)
+    }
+
+    expected := []obligations.Violation{
+        {Pointer: "/rules/0/response/condition/0/function/name", Description: "get_node_name returns a string but a boolean is expected"},
+        {Pointer: "/rules/0/response/actions/0/assign/0/what/function/name", Description: "get_children returns a set but a node is expected"},
+        {Pointer: "/rules/0/response/actions/1/function/args/1/function/args/0", Description: `not expects argument 1 to be a boolean but got "no"`},
+    }
+    if len(validationErr.Violations) != len(expected) {
+        t.Fatalf("expected %d violations, got %s", len(expected), validationErr)
+    }
+    for i, v := range validationErr.Violations {
+        if v != expected[i] {
+            t.Errorf("expected %s, got %s", expected[i], v)
+        }
+    }
+}
@@ -0,0 +1,181 @@
+package ngac
+
+import (
+    "errors"
+    "github.com/jtejido/ngac/pkg/context"
+    "github.com/jtejido/ngac/pkg/epp"
+    "github.com/jtejido/ngac/pkg/pip/obligations"
+    "strings"
+    "testing"
+)
+
+const invalidObligation = `{
+  "label": "invalid",
+  "rules": [
+    {
+      "label": "rule1",
+      "event": {
+        "subject": {"user": "u2"},
+        "policyClass": {"anyOf": ["oa1"]},
+        "operations": ["assign to"],
+        "target": {"policyElements": [{"name": "oa1", "type": "OA"}, {"name": "oa3", "type": "OA"}]}
+      },
+      "response": {
+        "condition": [{"function": {"name": "is_node_contained_in", "args": [{"function": {"name": "child_of_assign"}}]}}],
+        "actions": [
+          {"function": {"name": "unknown_function"}},
+          {"create": [
+            {"what": {"name": "new_oa", "type": "OA"}, "where": {"name": "pc1", "type": "PC"}},
+            {"what": {"name": "new_o", "type": "O"}, "where": {"name": "new_oa", "type": "OA"}},
+            {"what": {"name": "bad_o", "type": "O"}, "where": {"name": "pc1", "type": "PC"}},
+            {"what": {"name": "o1", "type": "O"}, "where": {"name": "oa1", "type": "OA"}}
+          ]},
+          {"assign": [{"what": {"name": "new_o", "type": "O"}, "where": {"name": "oa2", "type": "OA"}}]},
+          {"grant": {"subject": {"name": "ua1", "type": "UA"}, "operations": ["read"], "target": {"function": {"name": "get_node", "args": ["oa1"]}}}}
+        ]
+      }
+    },
+    {
+      "label": "rule1",
+      "event": {"operations": ["assign"]},
+      "response": {"actions": [{"function": {"name": "create_node", "args": ["pc1", "PC", "oa4", "OA", {"function": {"name": "to_props", "args": ["k=v", "k2=v2"]}}]}}]}
+    }
+  ]
+}`
+
+func TestValidateObligation(t *testing.T) {
+    tc := testCtx(t)
+    ctx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(ctx)
+
+    obligation, err := obligations.ParseBytes("super", []byte(invalidObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    // the schema only allows valid types
+    obligation.Rules[0].ResponsePattern.Actions[2].(*obligations.AssignAction).Assignments[0].Where.Type = "XX"
+
+    err = wu.ValidateObligation(obligation)
+    var validationErr *obligations.ValidationError
+    if !errors.As(err, &validationErr) {
+        t.Fatalf("expected a validation error, got %v", err)
+    }
+
+    expected := []obligations.Violation{
+        {Pointer: "/rules/0/event/subject/user", Description: "node u2 does not exist"},
+        {Pointer: "/rules/0/event/policyClass/anyOf/0", Description: "node oa1 is a OA not a PC"},
+        {Pointer: "/rules/0/event/target/policyElements/1", Description: "node oa3 does not exist"},
+        {Pointer: "/rules/0/response/condition/0/function/args", Description: "is_node_contained_in expects 2 arguments but got 1"},
+        {Pointer: "/rules/0/response/actions/0/function/name", Description: "unknown_function is not a recognized function"},
+        {Pointer: "/rules/0/response/actions/1/create/2/what/type", Description: `invalid assignment: "O" to "PC"`},
+        {Pointer: "/rules/0/response/actions/1/create/3/what/name", Description: "node o1 already exists"},
+        {Pointer: "/rules/0/response/actions/2/assign/0/where/type", Description: "invalid node type XX"},
+        {Pointer: "/rules/0/response/actions/3/grant/target/function/args", Description: "get_node expects 2 to 3 arguments but got 1"},
+        {Pointer: "/rules/1/label", Description: "duplicate rule label rule1"},
+    }
+    if len(validationErr.Violations) != len(expected) {
+        t.Fatalf("expected %d violations, got %s", len(expected), validationErr)
+    }
+    for i, v := range validationErr.Violations {
+        if v != expected[i] {
+            t.Errorf("expected %s, got %s", expected[i], v)
+        }
+    }
+}
+
+func TestValidateValidObligation(t *testing.T) {
+    tc := testCtx(t)
+    ctx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(ctx)
+
+    // the negated condition of the fixture's deny action is a process, not a boolean
+    mistyped := obligations.Violation{
+        Pointer:     "/rules/1/response/actions/0/condition!/0/function/name",
+        Description: "current_process returns a string but a boolean is expected",
+    }
+    for _, file := range []string{"test_event.json", "test_actions.json"} {
+        obligation, err := obligations.Parse("super", file)
+        if err != nil {
+            t.Fatalf("%s", err)
+        }
+        // the fixtures name nodes of other tests, only the functions and types are checked
+        err = wu.ValidateObligation(obligation)
+        var validationErr *obligations.ValidationError
+        if errors.As(err, &validationErr) {
+            for _, v := range validationErr.Violations {
+                if !isMissingNode(v) && !(file == "test_actions.json" && v == mistyped) {
+                    t.Errorf("%s: unexpected violation %s", file, v)
+                }
+            }
+        } else if err != nil {
+            t.Fatalf("%s", err)
+        }
+    }
+}
+
+func isMissingNode(v obligations.Violation) bool {
+    return strings.HasSuffix(v.Description, " does not exist")
+}
+
+func TestRejectInvalidObligation(t *testing.T) {
+    tc := testCtxWithOptions(t, epp.NewEPPOptions(new(recordEventExecutor)).WithObligationValidation())
+    ctx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(ctx)
+
+    obligation, err := obligations.ParseBytes("super", []byte(invalidObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if err := wu.ValidateObligation(obligation); err == nil {
+        t.Fatalf("expected the obligation to be invalid")
+    }
+    wu.Obligations().Add(obligation, true)
+    if wu.Obligations().Get("invalid") != nil {
+        t.Fatalf("expected the invalid obligation to be rejected")
+    }
+
+    obligation, err = obligations.ParseBytes("super", []byte(recordObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    wu.Obligations().Add(obligation, true)
+    if wu.Obligations().Get("record") == nil {
+        t.Fatalf("expected the valid obligation to be added")
+    }
+}
+
+func TestAddInvalidObligationReturnsViolations(t *testing.T) {
+    tc := testCtxWithOptions(t, epp.NewEPPOptions(new(recordEventExecutor)).WithObligationValidation())
+    ctx, _ := context.NewUserContext("super")
+    wu := tc.pdp.WithUser(ctx)
+
+    invalid, err := obligations.ParseBytes("super", []byte(invalidObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    err = wu.AddObligation(invalid, true)
+    var validationErr *obligations.ValidationError
+    if !errors.As(err, &validationErr) || len(validationErr.Violations) == 0 {
+        t.Fatalf("expected a validation error, got %v", err)
+    }
+    if wu.Obligations().Get("invalid") != nil {
+        t.Fatalf("expected the invalid obligation to be rejected")
+    }
+
+    valid, err := obligations.ParseBytes("super", []byte(recordObligation))
+    if err != nil {
+        t.Fatalf("%s", err)
+    }
+    if err := wu.AddObligation(valid, true); err != nil {
+        t.Fatalf("%s", err)
+    }
+
+    err = wu.UpdateObligation("record", invalid)
+    if !errors.As(err, &validationErr) {
+        t.Fatalf("expected a validation error, got %v", err)
+    }
+    if wu.Obligations().Get("record") == nil || wu.Obligations().Get("invalid") != nil {
+        t.Fatalf("expected the invalid update to be rejected")
+    }
+}